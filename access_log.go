@@ -3,18 +3,91 @@ package mond
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// AccessLogVersion is the schema version of the logs recorded now. Version 2 added Method,
+// Protocol, Bytes, Referrer, UserAgent and Latency, the stores fill them in for older logs from
+// their raw line when they load them and write them back, so that happens once.
+const AccessLogVersion = 2
+
+// AccessLog is a request read from a log line, its Latency is in milliseconds. Lines forwarded by
+// mond-client carry their LogSource.
 type AccessLog struct {
-	Timestamp int64  `json:"timestamp"`
-	Unix      int64  `json:"unix"`
-	Ip        string `json:"ip"`
-	Path      string `json:"path"`
-	RemoteIp  string `json:"remoteIp"`
-	Status    string `json:"status"`
-	Raw       string `json:"raw"`
+	Timestamp int64   `json:"timestamp"`
+	Unix      int64   `json:"unix"`
+	Ip        string  `json:"ip"`
+	Path      string  `json:"path"`
+	RemoteIp  string  `json:"remoteIp"`
+	Status    string  `json:"status"`
+	Raw       string  `json:"raw"`
+	Method    string  `json:"method,omitempty"`
+	Protocol  string  `json:"protocol,omitempty"`
+	Bytes     int64   `json:"bytes,omitempty"`
+	Referrer  string  `json:"referrer,omitempty"`
+	UserAgent string  `json:"userAgent,omitempty"`
+	Latency   float64 `json:"latency,omitempty"`
+	Version   int     `json:"version,omitempty"`
+	LogSource
+}
+
+// currentLogs returns a copy of logs at the current schema version. Logs are recorded with all the
+// fields of the version, whether their parser filled them or not, so they never need an upgrade.
+func currentLogs(logs AccessLogs) AccessLogs {
+	current := make(AccessLogs, len(logs))
+	for i, log := range logs {
+		log.Version = AccessLogVersion
+		current[i] = log
+	}
+	return current
+}
+
+// upgradeLogs brings the logs of an older schema version up to date and reports whether there
+// were any.
+func (a *App) upgradeLogs() bool {
+	upgraded := false
+	for i := range a.Logs {
+		if a.Logs[i].Version < AccessLogVersion {
+			a.Logs[i].upgrade()
+			upgraded = true
+		}
+	}
+	return upgraded
+}
+
+// upgrade fills the fields added since the version of the log from its raw line.
+func (log *AccessLog) upgrade() {
+	log.Version = AccessLogVersion
+	if log.Raw == "" {
+		return
+	}
+	if parsed, ok := AutoLogParser.Parse(log.Raw); ok {
+		log.fillRequest(parsed)
+	}
+}
+
+// fillRequest copies the details of the request which log does not have yet from other.
+func (log *AccessLog) fillRequest(other AccessLog) {
+	if log.Method == "" {
+		log.Method = other.Method
+	}
+	if log.Protocol == "" {
+		log.Protocol = other.Protocol
+	}
+	if log.Bytes == 0 {
+		log.Bytes = other.Bytes
+	}
+	if log.Referrer == "" {
+		log.Referrer = other.Referrer
+	}
+	if log.UserAgent == "" {
+		log.UserAgent = other.UserAgent
+	}
+	if log.Latency == 0 {
+		log.Latency = other.Latency
+	}
 }
 
 type AccessLogs []AccessLog
@@ -30,6 +103,30 @@ func ReportRawLog(url string, content string) error {
 	return nil
 }
 
+// ReportError is returned if the apiserver answered a report with an unexpected status.
+type ReportError struct {
+	StatusCode int
+}
+
+func (e *ReportError) Error() string {
+	return fmt.Sprintf("got wrong response code, got %d want 202", e.StatusCode)
+}
+
+// Temporary reports whether sending the same report again may succeed.
+func (e *ReportError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests
+}
+
+// ReportRawLogs sends a batch of raw log lines to url.
+func ReportRawLogs(url string, lines []string) error {
+	return reportBatch(url, textContentType, strings.Join(lines, "\n"))
+}
+
+// ReportAccessLogs sends a batch of AccessLog objects, each encoded as a JSON line, to url.
+func ReportAccessLogs(url string, lines []string) error {
+	return reportBatch(url, ndjsonContentType, strings.Join(lines, "\n"))
+}
+
 func (log *AccessLog) GetUnixFormatted() string {
 	return time.Unix(log.Unix, 0).Format("02.01.2006 15:04:05")
 }
@@ -38,6 +135,22 @@ func (log *AccessLog) GetTimestampFormatted() string {
 	return time.Unix(log.Timestamp, 0).Format("02.01.2006 15:04:05")
 }
 
+// size approximates the bytes a log takes up in a store.
+func (log *AccessLog) size() int64 {
+	const encodingOverhead = 80
+	return int64(len(log.Ip) + len(log.Path) + len(log.RemoteIp) + len(log.Status) + len(log.Raw) +
+		len(log.Method) + len(log.Protocol) + len(log.Referrer) + len(log.UserAgent) +
+		len(log.Stream) + len(log.Host) + len(log.Instance) + encodingOverhead)
+}
+
+// GetLatencyFormatted returns the latency like "12.5 ms", or nothing if it is unknown.
+func (log *AccessLog) GetLatencyFormatted() string {
+	if log.Latency == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(log.Latency, 'f', -1, 64) + " ms"
+}
+
 func (log *AccessLog) GetRawIfNotAnalysed() string {
 	if log.Ip != "" {
 		return ""
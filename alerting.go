@@ -0,0 +1,287 @@
+package mond
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultAlertingInterval = Duration(30 * time.Second)
+
+// MaxResolvedAlerts is how many resolved alerts are kept to be shown.
+const MaxResolvedAlerts = 100
+
+// Types of AlertRule.
+const (
+	AlertHealth    = "health"
+	AlertErrorRate = "errorRate"
+	AlertNoLogs    = "noLogs"
+)
+
+// States of an Alert.
+const (
+	AlertPending  = "pending"
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// AlertingConfig holds the rules evaluated every Interval.
+type AlertingConfig struct {
+	Interval Duration    `json:"interval,omitempty"`
+	Rules    []AlertRule `json:"rules,omitempty"`
+}
+
+func (c AlertingConfig) IsEnabled() bool {
+	return len(c.Rules) > 0
+}
+
+// Validate reports the first rule which cannot be evaluated.
+func (c AlertingConfig) Validate() error {
+	names := map[string]bool{}
+	for i, r := range c.Rules {
+		if r.Name == "" {
+			return fmt.Errorf("alert rule %d has no name", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("alert rule %s is declared twice", r.Name)
+		}
+		names[r.Name] = true
+		switch r.Type {
+		case AlertHealth:
+		case AlertErrorRate:
+			if r.Window <= 0 || r.Threshold <= 0 {
+				return fmt.Errorf("alert rule %s needs a window and a threshold", r.Name)
+			}
+		case AlertNoLogs:
+			if r.Window <= 0 {
+				return fmt.Errorf("alert rule %s needs a window", r.Name)
+			}
+		default:
+			return fmt.Errorf("alert rule %s has unknown type %q, want %s, %s or %s", r.Name, r.Type, AlertHealth, AlertErrorRate, AlertNoLogs)
+		}
+	}
+	return nil
+}
+
+// AlertRule describes a condition of an app, or of every app if App is empty:
+//
+//	health     the health is one of Statuses, DOWN by default
+//	errorRate  more than Threshold percent of the logs within Window have one of Statuses, 5xx by default
+//	noLogs     the last of the logs arrived more than Window ago, apps which never sent logs are left out
+//
+// The alert is pending while the condition holds for less than For and firing afterwards.
+type AlertRule struct {
+	Name      string   `json:"name"`
+	App       string   `json:"app,omitempty"`
+	Type      string   `json:"type"`
+	Statuses  []string `json:"statuses,omitempty"`
+	Threshold float64  `json:"threshold,omitempty"`
+	Window    Duration `json:"window,omitempty"`
+	For       Duration `json:"for,omitempty"`
+}
+
+// Alert is the state of a rule for one app. Since is when its condition started to hold.
+type Alert struct {
+	Rule       string `json:"rule"`
+	App        string `json:"app"`
+	State      string `json:"state"`
+	Message    string `json:"message"`
+	Since      int64  `json:"since"`
+	FiredAt    int64  `json:"firedAt,omitempty"`
+	ResolvedAt int64  `json:"resolvedAt,omitempty"`
+}
+
+func (a Alert) key() string {
+	return a.Rule + "/" + a.App
+}
+
+func (a Alert) GetSinceFormatted() string {
+	return time.Unix(a.Since, 0).Format("02.01.2006 15:04:05")
+}
+
+// GetStateClass returns the bootstrap class the dashboard shows the alert with.
+func (a Alert) GetStateClass() string {
+	switch a.State {
+	case AlertFiring:
+		return "bg-danger"
+	case AlertPending:
+		return "bg-warning"
+	}
+	return "bg-success"
+}
+
+// appliesTo reports whether the rule is evaluated for the app. Rules for every app leave out the
+// apiserver itself.
+func (r AlertRule) appliesTo(name string) bool {
+	if r.App != "" {
+		return strings.EqualFold(r.App, name)
+	}
+	return name != MondAppName
+}
+
+// check reports whether the condition of the rule holds for app and describes it.
+func (r AlertRule) check(app *App, now time.Time) (bool, string) {
+	switch r.Type {
+	case AlertHealth:
+		statuses := r.Statuses
+		if len(statuses) == 0 {
+			statuses = []string{"DOWN"}
+		}
+		status := app.CurrentHealth().Status
+		return containsString(statuses, status), fmt.Sprintf("%s is %s", app.Name, status)
+	case AlertErrorRate:
+		statuses := r.Statuses
+		if len(statuses) == 0 {
+			statuses = []string{"5xx"}
+		}
+		start := now.Add(-time.Duration(r.Window)).Unix()
+		total, matched := 0, 0
+		for i := len(app.Logs) - 1; i >= 0 && app.Logs[i].Unix >= start; i-- {
+			total++
+			if matchesStatus(app.Logs[i].Status, statuses) {
+				matched++
+			}
+		}
+		if total == 0 {
+			return false, ""
+		}
+		rate := float64(matched) * 100 / float64(total)
+		return rate > r.Threshold, fmt.Sprintf("%.1f%% of %d requests to %s within %s had status %s", rate, total, app.Name, r.Window, strings.Join(statuses, ","))
+	case AlertNoLogs:
+		if len(app.Logs) == 0 {
+			return false, ""
+		}
+		last := app.Logs[len(app.Logs)-1].Unix
+		return now.Unix()-last > int64(time.Duration(r.Window)/time.Second), fmt.Sprintf("no logs from %s since %s", app.Name, time.Unix(last, 0).Format("02.01.2006 15:04:05"))
+	}
+	return false, ""
+}
+
+// Alerter evaluates rules and keeps a single alert per rule and app, however often its
+// condition is found to hold.
+type Alerter struct {
+	mu       sync.Mutex
+	rules    []AlertRule
+	active   map[string]Alert
+	resolved []Alert
+}
+
+func NewAlerter(rules []AlertRule) *Alerter {
+	return &Alerter{rules: rules, active: map[string]Alert{}}
+}
+
+// Evaluate checks all rules against the apps and returns the alerts which started firing or got
+// resolved. Alerts of apps which are gone get resolved.
+func (a *Alerter) Evaluate(apps Apps, now time.Time) []Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var changed []Alert
+	seen := map[string]bool{}
+	for _, rule := range a.rules {
+		for i := range apps {
+			app := &apps[i]
+			if !rule.appliesTo(app.Name) {
+				continue
+			}
+			holds, message := rule.check(app, now)
+			if !holds {
+				continue
+			}
+			alert, ok := a.active[Alert{Rule: rule.Name, App: app.Name}.key()]
+			if !ok {
+				alert = Alert{Rule: rule.Name, App: app.Name, State: AlertPending, Since: now.Unix()}
+			}
+			alert.Message = message
+			if alert.State == AlertPending && now.Unix()-alert.Since >= int64(time.Duration(rule.For)/time.Second) {
+				alert.State = AlertFiring
+				alert.FiredAt = now.Unix()
+				changed = append(changed, alert)
+			}
+			a.active[alert.key()] = alert
+			seen[alert.key()] = true
+		}
+	}
+
+	for key, alert := range a.active {
+		if seen[key] {
+			continue
+		}
+		delete(a.active, key)
+		if alert.State != AlertFiring {
+			continue
+		}
+		alert.State = AlertResolved
+		alert.ResolvedAt = now.Unix()
+		changed = append(changed, alert)
+		a.resolved = append(a.resolved, alert)
+	}
+	if len(a.resolved) > MaxResolvedAlerts {
+		a.resolved = append([]Alert{}, a.resolved[len(a.resolved)-MaxResolvedAlerts:]...)
+	}
+	sortAlerts(changed)
+	return changed
+}
+
+// Active returns the pending and firing alerts, the oldest first.
+func (a *Alerter) Active() []Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	alerts := []Alert{}
+	for _, alert := range a.active {
+		alerts = append(alerts, alert)
+	}
+	sortAlerts(alerts)
+	return alerts
+}
+
+// Resolved returns the latest resolved alerts, the most recent last.
+func (a *Alerter) Resolved() []Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Alert{}, a.resolved...)
+}
+
+func sortAlerts(alerts []Alert) {
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Since != alerts[j].Since {
+			return alerts[i].Since < alerts[j].Since
+		}
+		return alerts[i].key() < alerts[j].key()
+	})
+}
+
+// StartAlerting evaluates the rules against the apps of the store every interval until the
+// returned func is called. notify, if not nil, gets the alerts which started firing or got resolved.
+func StartAlerting(store AccessLogStore, alerter *Alerter, interval Duration, notify func(Alert)) func() {
+	if interval <= 0 {
+		interval = DefaultAlertingInterval
+	}
+	ticker := time.NewTicker(time.Duration(interval))
+	quit := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case now := <-ticker.C:
+				for _, alert := range alerter.Evaluate(store.GetApps(), now) {
+					if alert.State == AlertFiring {
+						fmt.Printf("WARN: alert %s firing, %s\n", alert.Rule, alert.Message)
+					} else {
+						fmt.Printf("alert %s of %s resolved\n", alert.Rule, alert.App)
+					}
+					if notify != nil {
+						notify(alert)
+					}
+				}
+			case <-quit:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+	}
+}
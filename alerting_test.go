@@ -0,0 +1,157 @@
+package mond
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlerter(t *testing.T) {
+	t.Run("goes from pending to firing to resolved", func(t *testing.T) {
+		alerter := NewAlerter([]AlertRule{{Name: "down", App: "app", Type: AlertHealth, For: Duration(2 * time.Minute)}})
+		apps := Apps{{Name: "app", Health: HealthCheck{Status: "DOWN"}}}
+
+		assertAlertChanges(t, alerter.Evaluate(apps, time.Unix(1000, 0)), nil)
+		assertActiveAlerts(t, alerter, Alert{Rule: "down", App: "app", State: AlertPending, Message: "app is DOWN", Since: 1000})
+
+		assertAlertChanges(t, alerter.Evaluate(apps, time.Unix(1060, 0)), nil)
+		firing := Alert{Rule: "down", App: "app", State: AlertFiring, Message: "app is DOWN", Since: 1000, FiredAt: 1120}
+		assertAlertChanges(t, alerter.Evaluate(apps, time.Unix(1120, 0)), []Alert{firing})
+		assertAlertChanges(t, alerter.Evaluate(apps, time.Unix(1180, 0)), nil)
+		assertActiveAlerts(t, alerter, firing)
+
+		apps[0].Health.Status = "UP"
+		resolved := firing
+		resolved.State = AlertResolved
+		resolved.ResolvedAt = 1240
+		assertAlertChanges(t, alerter.Evaluate(apps, time.Unix(1240, 0)), []Alert{resolved})
+		assertActiveAlerts(t, alerter)
+		if got := alerter.Resolved(); !reflect.DeepEqual(got, []Alert{resolved}) {
+			t.Errorf("got resolved %v want %v", got, resolved)
+		}
+	})
+
+	t.Run("drops alerts which recover while pending", func(t *testing.T) {
+		alerter := NewAlerter([]AlertRule{{Name: "down", Type: AlertHealth, For: Duration(time.Minute)}})
+		alerter.Evaluate(Apps{{Name: "app", Health: HealthCheck{Status: "DOWN"}}}, time.Unix(1000, 0))
+
+		assertAlertChanges(t, alerter.Evaluate(Apps{{Name: "app", Health: HealthCheck{Status: "UP"}}}, time.Unix(1030, 0)), nil)
+		assertActiveAlerts(t, alerter)
+		if got := alerter.Resolved(); len(got) != 0 {
+			t.Errorf("got resolved %v want none", got)
+		}
+	})
+
+	t.Run("keeps one alert per rule and app", func(t *testing.T) {
+		alerter := NewAlerter([]AlertRule{{Name: "down", Type: AlertHealth, Statuses: []string{"DOWN", HealthStale}}})
+		apps := Apps{
+			{Name: "a", Health: HealthCheck{Status: "DOWN"}},
+			{Name: "b", Stale: true},
+			{Name: "c", Health: HealthCheck{Status: "UP"}},
+			{Name: MondAppName, Health: HealthCheck{Status: "DOWN"}},
+		}
+
+		changed := alerter.Evaluate(apps, time.Unix(1000, 0))
+		alerter.Evaluate(apps, time.Unix(1030, 0))
+
+		if len(changed) != 2 || changed[0].App != "a" || changed[1].App != "b" {
+			t.Errorf("got %v want alerts for a and b", changed)
+		}
+		if active := alerter.Active(); len(active) != 2 || active[1].Message != "b is STALE" {
+			t.Errorf("got %v want one alert each for a and b", active)
+		}
+	})
+
+	t.Run("resolves alerts of apps which are gone", func(t *testing.T) {
+		alerter := NewAlerter([]AlertRule{{Name: "down", Type: AlertHealth}})
+		alerter.Evaluate(Apps{{Name: "app", Health: HealthCheck{Status: "DOWN"}}}, time.Unix(1000, 0))
+
+		changed := alerter.Evaluate(Apps{}, time.Unix(1030, 0))
+
+		if len(changed) != 1 || changed[0].State != AlertResolved {
+			t.Errorf("got %v want the alert resolved", changed)
+		}
+	})
+}
+
+func TestAlertRules(t *testing.T) {
+	now := time.Unix(10000, 0)
+	logs := func(statuses ...string) AccessLogs {
+		var logs AccessLogs
+		for i, s := range statuses {
+			logs = append(logs, AccessLog{Unix: now.Unix() - int64(len(statuses)-i)*60, Status: s})
+		}
+		return logs
+	}
+
+	cases := []struct {
+		name    string
+		rule    AlertRule
+		app     App
+		holds   bool
+		message string
+	}{
+		{"health matches", AlertRule{Type: AlertHealth}, App{Name: "a", Health: HealthCheck{Status: "DOWN"}}, true, "a is DOWN"},
+		{"health differs", AlertRule{Type: AlertHealth}, App{Name: "a", Health: HealthCheck{Status: HealthDegraded}}, false, ""},
+		{"error rate above threshold", AlertRule{Type: AlertErrorRate, Threshold: 5, Window: Duration(10 * time.Minute)},
+			App{Name: "a", Logs: logs("200", "500", "200", "503")}, true, "50.0% of 4 requests to a within 10m0s had status 5xx"},
+		{"error rate only within window", AlertRule{Type: AlertErrorRate, Threshold: 5, Window: Duration(2 * time.Minute)},
+			App{Name: "a", Logs: logs("500", "500", "200", "200")}, false, ""},
+		{"error rate of other statuses", AlertRule{Type: AlertErrorRate, Threshold: 30, Window: Duration(time.Hour), Statuses: []string{"404"}},
+			App{Name: "a", Logs: logs("404", "200", "200")}, true, "33.3%"},
+		{"error rate without logs", AlertRule{Type: AlertErrorRate, Threshold: 5, Window: Duration(time.Minute)}, App{Name: "a"}, false, ""},
+		{"no logs for too long", AlertRule{Type: AlertNoLogs, Window: Duration(time.Minute)}, App{Name: "a", Logs: logs("200", "200")}, false, ""},
+		{"no logs within window", AlertRule{Type: AlertNoLogs, Window: Duration(30 * time.Second)}, App{Name: "a", Logs: logs("200")}, true, "no logs from a since"},
+		{"no logs ever", AlertRule{Type: AlertNoLogs, Window: Duration(time.Minute)}, App{Name: "a"}, false, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			holds, message := c.rule.check(&c.app, now)
+			if holds != c.holds || (c.holds && !strings.Contains(message, c.message)) {
+				t.Errorf("got %v %q want %v %q", holds, message, c.holds, c.message)
+			}
+		})
+	}
+}
+
+func TestAlertingConfig(t *testing.T) {
+	cases := []struct {
+		rules []AlertRule
+		err   string
+	}{
+		{[]AlertRule{{Name: "down", Type: AlertHealth}, {Name: "errors", Type: AlertErrorRate, Threshold: 5, Window: Duration(time.Minute)}}, ""},
+		{[]AlertRule{{Type: AlertHealth}}, "no name"},
+		{[]AlertRule{{Name: "down", Type: AlertHealth}, {Name: "down", Type: AlertHealth}}, "declared twice"},
+		{[]AlertRule{{Name: "errors", Type: AlertErrorRate, Window: Duration(time.Minute)}}, "needs a window and a threshold"},
+		{[]AlertRule{{Name: "quiet", Type: AlertNoLogs}}, "needs a window"},
+		{[]AlertRule{{Name: "x", Type: "cpu"}}, "unknown type"},
+	}
+	for _, c := range cases {
+		err := AlertingConfig{Rules: c.rules}.Validate()
+		if c.err == "" && err != nil {
+			t.Errorf("got error %v for %v", err, c.rules)
+		}
+		if c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("got error %v want %q", err, c.err)
+		}
+	}
+}
+
+func assertAlertChanges(t testing.TB, got, want []Alert) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got changes %v want %v", got, want)
+	}
+}
+
+func assertActiveAlerts(t testing.TB, alerter *Alerter, want ...Alert) {
+	t.Helper()
+	got := alerter.Active()
+	if want == nil {
+		want = []Alert{}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got active %v want %v", got, want)
+	}
+}
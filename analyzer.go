@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -11,21 +12,35 @@ import (
 var systemTZ = os.Getenv("TZ")
 
 func ParseRawLog(raw string) AccessLog {
+	accessLog := findAccessLog(raw)
+	accessLog.RemoteIp = TrustedProxies(nil).Client(accessLog.Ip, accessLog.RemoteIp)
+	accessLog.Unix = time.Now().Unix()
+	accessLog.Raw = raw
+	accessLog.Version = AccessLogVersion
+	return accessLog
+}
+
+// findAccessLog searches raw for the parts of a log, RemoteIp being the forwarded header.
+func findAccessLog(raw string) AccessLog {
 	accessLog := new(AccessLog)
 	accessLog.Ip = findIp(raw)
 	accessLog.Timestamp = findTimeAndParse(raw)
-	accessLog.Unix = time.Now().Unix()
 	accessLog.Status = findStatus(raw)
-	path, _, _ := findPathMethodHttp(raw)
+	path, method, http := findPathMethodHttp(raw)
 	accessLog.Path = path
+	accessLog.Method = method
+	accessLog.Protocol = http
+	accessLog.Bytes, accessLog.Referrer, accessLog.UserAgent = findBytesRefererAgent(raw)
 	accessLog.RemoteIp = findxForwardedFor(raw)
-	accessLog.Raw = raw
 	return *accessLog
 }
 
 func findIp(raw string) string {
-	remoteReg := regexp.MustCompile(`^\d{1,3}\.\d{1,3}.\d{1,3}.\d{1,3}`)
-	return remoteReg.FindString(raw)
+	fields := strings.Fields(raw)
+	if len(fields) == 0 {
+		return ""
+	}
+	return ipString(ParseIp(fields[0]))
 }
 
 func findTimeAndParse(raw string) int64 {
@@ -87,8 +102,42 @@ func findPathMethodHttp(raw string) (string, string, string) {
 	return path, method, http
 }
 
+func findBytesRefererAgent(raw string) (int64, string, string) {
+	bytesReg := regexp.MustCompile(`"\s\d{3}\s(\d+|-)(?:\s"([^"]*)"\s"([^"]*)")?`)
+	m := bytesReg.FindStringSubmatch(raw)
+	if m == nil {
+		return 0, "", ""
+	}
+	bytes, _ := strconv.ParseInt(m[1], 10, 64)
+	return bytes, dashless(m[2]), dashless(m[3])
+}
+
+func dashless(value string) string {
+	if value == "-" {
+		return ""
+	}
+	return value
+}
+
+// findxForwardedFor returns the last quoted value of raw which is a list of ips or a Forwarded
+// header.
 func findxForwardedFor(raw string) string {
-	xIpReg := regexp.MustCompile(`["]\d{1,3}\.\d{1,3}.\d{1,3}.\d{1,3}["]`)
-	xIp := xIpReg.FindString(raw)
-	return strings.Trim(xIp, "\"")
+	quotedReg := regexp.MustCompile(`"([^"]*)"`)
+	quoted := quotedReg.FindAllStringSubmatch(raw, -1)
+	for i := len(quoted) - 1; i >= 0; i-- {
+		if isIpChain(quoted[i][1]) {
+			return quoted[i][1]
+		}
+	}
+	return ""
+}
+
+func isIpChain(header string) bool {
+	chain := ForwardedChain(header)
+	for _, entry := range chain {
+		if ParseIp(entry) == nil {
+			return false
+		}
+	}
+	return len(chain) > 0
 }
@@ -16,15 +16,46 @@ func TestAnalyzeLogStatement(t *testing.T) {
 			Unix:      0,
 			Status:    "200",
 			Path:      "/futures",
-			//method: "GET",
-			//http: "HTTP/1.1",
-			RemoteIp: "92.104.237.155",
-			Raw:      rawLog,
+			Method:    "GET",
+			Protocol:  "HTTP/1.1",
+			Bytes:     7280,
+			UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
+			RemoteIp:  "92.104.237.155",
+			Raw:       rawLog,
 		}
 
 		assertAccessLogEquals(t, got, want)
 	})
 
+	t.Run("parse ipv6 log with a chain of proxies", func(t *testing.T) {
+		rawLog := `2001:db8::5 - - [02/Jul/2021:22:50:59 +0200] "GET /futures HTTP/2.0" 200 7280 "-" "curl/7.68.0" "2001:db8:cafe::17, 10.0.0.2"`
+
+		got := ParseRawLog(rawLog)
+		want := AccessLog{
+			Ip:        "2001:db8::5",
+			Timestamp: 1625259059,
+			Status:    "200",
+			Path:      "/futures",
+			Method:    "GET",
+			Protocol:  "HTTP/2.0",
+			Bytes:     7280,
+			UserAgent: "curl/7.68.0",
+			RemoteIp:  "2001:db8:cafe::17",
+			Raw:       rawLog,
+		}
+
+		assertAccessLogEquals(t, got, want)
+	})
+
+	t.Run("ignore an invalid ip", func(t *testing.T) {
+		rawLog := `999.1.2.3 - - [02/Jul/2021:22:50:59 +0200] "GET / HTTP/1.1" 200 1 "-" "curl" "1.2.3"`
+
+		got := ParseRawLog(rawLog)
+		if got.Ip != "" || got.RemoteIp != "" {
+			t.Errorf("got ip %q and remote ip %q want none", got.Ip, got.RemoteIp)
+		}
+	})
+
 	t.Run("parse unusual log", func(t *testing.T) {
 		rawLog := "SampleLog"
 
@@ -79,4 +110,19 @@ func assertAccessLogEquals(t testing.TB, got, want AccessLog) {
 	if got.Raw != want.Raw {
 		t.Errorf("got raw %v want %v", got.Raw, want.Raw)
 	}
+	if got.Method != want.Method || got.Protocol != want.Protocol {
+		t.Errorf("got request %v %v want %v %v", got.Method, got.Protocol, want.Method, want.Protocol)
+	}
+	if got.Bytes != want.Bytes {
+		t.Errorf("got bytes %v want %v", got.Bytes, want.Bytes)
+	}
+	if got.Referrer != want.Referrer {
+		t.Errorf("got referrer %v want %v", got.Referrer, want.Referrer)
+	}
+	if got.UserAgent != want.UserAgent {
+		t.Errorf("got user agent %v want %v", got.UserAgent, want.UserAgent)
+	}
+	if got.Latency != want.Latency {
+		t.Errorf("got latency %v want %v", got.Latency, want.Latency)
+	}
 }
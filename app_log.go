@@ -0,0 +1,281 @@
+package mond
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Levels of an AppLog, from the least to the most severe.
+const (
+	LevelTrace = "trace"
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+	LevelFatal = "fatal"
+)
+
+var appLogLevels = []string{LevelTrace, LevelDebug, LevelInfo, LevelWarn, LevelError, LevelFatal}
+
+// levelNames maps the level names of common logging libraries to the levels of an AppLog.
+var levelNames = map[string]string{
+	"trace": LevelTrace, "trc": LevelTrace, "finest": LevelTrace, "finer": LevelTrace, "verbose": LevelTrace,
+	"debug": LevelDebug, "dbg": LevelDebug, "fine": LevelDebug,
+	"info": LevelInfo, "inf": LevelInfo, "information": LevelInfo, "notice": LevelInfo,
+	"warn": LevelWarn, "warning": LevelWarn, "wrn": LevelWarn,
+	"error": LevelError, "err": LevelError, "eror": LevelError, "severe": LevelError,
+	"fatal": LevelFatal, "crit": LevelFatal, "critical": LevelFatal, "panic": LevelFatal, "dpanic": LevelFatal,
+	"alert": LevelFatal, "emerg": LevelFatal,
+}
+
+// MaxAppLogs is the number of application logs kept per app.
+const MaxAppLogs = 1000
+
+// AppLog is an entry an app writes about itself, as opposed to the access logs of the requests it
+// served. Raw holds all lines of the entry, a stack trace following the first one included.
+type AppLog struct {
+	Unix      int64             `json:"unix"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Level     string            `json:"level,omitempty"`
+	Logger    string            `json:"logger,omitempty"`
+	Message   string            `json:"message,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	Raw       string            `json:"raw"`
+	LogSource
+}
+
+var (
+	appLogLevelKeys   = []string{"level", "severity", "lvl", "log.level", "@l"}
+	appLogLoggerKeys  = []string{"logger", "logger_name", "loggerName", "log.logger", "name"}
+	appLogMessageKeys = []string{"msg", "message", "@m", "@mt"}
+	appLogTimeKeys    = []string{"time", "ts", "timestamp", "@timestamp", "@t"}
+)
+
+var (
+	appLogTimeReg   = regexp.MustCompile(`^\[?(\d{4}-\d\d-\d\d[T ]\d\d:\d\d:\d\d)`)
+	appLogLevelReg  = regexp.MustCompile(`(?i)\b(trace|debug|info|notice|warn|warning|error|err|severe|fatal|crit|critical|panic)\b`)
+	appLogLoggerReg = regexp.MustCompile(`\s\[?([A-Za-z_][\w$]*(?:\.[\w$]+)+)\]?\s+[-:]\s+`)
+	appLogCrashReg  = regexp.MustCompile(`^(panic: |fatal error: |Exception in thread |Traceback \(most recent call last\)|[\w.$]+(Exception|Error)(: |$))`)
+)
+
+// ParseAppLog reads level, logger, message, time and fields from the first line of raw, which is
+// JSON, logfmt or text. Lines starting a Go panic, a Java exception or a Python traceback are
+// errors even without a level.
+func ParseAppLog(raw string) AppLog {
+	log := AppLog{Raw: raw}
+	first := raw
+	if i := strings.IndexByte(raw, '\n'); i >= 0 {
+		first = raw[:i]
+	}
+	first = strings.TrimRight(first, "\r")
+
+	var doc map[string]interface{}
+	if strings.HasPrefix(strings.TrimSpace(first), "{") {
+		json.Unmarshal([]byte(first), &doc)
+	} else if pairs := parseLogfmt(first); pairs != nil && (lookupAppLogKey(pairs, appLogLevelKeys) != "" || lookupAppLogKey(pairs, appLogMessageKeys) != "") {
+		doc = pairs
+	}
+	if doc != nil {
+		log.readFields(doc)
+		return log
+	}
+
+	log.Message = first
+	if m := appLogTimeReg.FindStringSubmatch(first); m != nil {
+		log.Timestamp = parseLogTime(strings.Replace(m[1], "T", " ", 1))
+	}
+	if appLogCrashReg.MatchString(first) {
+		log.Level = LevelError
+		if strings.HasPrefix(first, "panic: ") || strings.HasPrefix(first, "fatal error: ") {
+			log.Level = LevelFatal
+		}
+		return log
+	}
+	if loc := appLogLevelReg.FindStringSubmatchIndex(first); loc != nil {
+		log.Level = NormalizeLevel(first[loc[2]:loc[3]])
+		rest := first[loc[1]:]
+		if m := appLogLoggerReg.FindStringSubmatchIndex(rest); m != nil {
+			log.Logger = rest[m[2]:m[3]]
+			rest = rest[m[1]:]
+		}
+		if message := strings.TrimLeft(rest, " \t:-]"); message != "" {
+			log.Message = message
+		}
+	}
+	return log
+}
+
+// readFields takes the well known keys of doc as level, logger, message and time and keeps all
+// other keys as fields.
+func (log *AppLog) readFields(doc map[string]interface{}) {
+	log.Level = NormalizeLevel(lookupAppLogKey(doc, appLogLevelKeys))
+	log.Logger = lookupAppLogKey(doc, appLogLoggerKeys)
+	log.Message = lookupAppLogKey(doc, appLogMessageKeys)
+	log.Timestamp = parseLogTime(lookupAppLogKey(doc, appLogTimeKeys))
+
+	known := map[string]bool{}
+	for _, keys := range [][]string{appLogLevelKeys, appLogLoggerKeys, appLogMessageKeys, appLogTimeKeys} {
+		for _, key := range keys {
+			known[key] = true
+		}
+	}
+	for key, value := range doc {
+		if known[key] || value == nil {
+			continue
+		}
+		if log.Fields == nil {
+			log.Fields = map[string]string{}
+		}
+		log.Fields[key] = jsonValueString(value)
+	}
+}
+
+func lookupAppLogKey(doc map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		value, ok := doc[key]
+		if !ok {
+			value, ok = lookupJsonPath(doc, key)
+		}
+		if ok && value != nil {
+			if s := jsonValueString(value); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// NormalizeLevel returns the level called name by a logging library, empty if it is unknown.
+func NormalizeLevel(name string) string {
+	return levelNames[strings.ToLower(strings.TrimSpace(name))]
+}
+
+// levelRank orders the levels, unknown levels rank below trace.
+func levelRank(level string) int {
+	for i, l := range appLogLevels {
+		if l == level {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// fill completes a log sent by a client with what can be read from its raw lines.
+func (log *AppLog) fill() {
+	parsed := ParseAppLog(log.Raw)
+	if level := NormalizeLevel(log.Level); level != "" {
+		log.Level = level
+	} else {
+		log.Level = parsed.Level
+	}
+	if log.Logger == "" {
+		log.Logger = parsed.Logger
+	}
+	if log.Message == "" {
+		log.Message = parsed.Message
+	}
+	if log.Timestamp == 0 {
+		log.Timestamp = parsed.Timestamp
+	}
+	if log.Fields == nil {
+		log.Fields = parsed.Fields
+	}
+}
+
+// ParseAppLogBatch reads the application logs of a batch. NDJSON lines are AppLog objects, any
+// other body has a single line entry per line. Empty entries are skipped.
+func ParseAppLogBatch(body []byte, contentType string) ([]AppLog, BatchResult, error) {
+	var logs []AppLog
+	var result BatchResult
+	now := time.Now().Unix()
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for i, line := range bytes.Split(body, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var log AppLog
+		if mediaType == ndjsonContentType {
+			if err := json.Unmarshal(line, &log); err != nil {
+				result.reject(i+1, fmt.Errorf("invalid AppLog, %v", err))
+				continue
+			}
+		} else {
+			log.Raw = strings.TrimSuffix(string(line), "\r")
+		}
+		if strings.TrimSpace(log.Raw) == "" {
+			result.reject(i+1, fmt.Errorf("AppLog without raw lines"))
+			continue
+		}
+		if len(log.Raw) > MaxRawLogLength {
+			result.reject(i+1, fmt.Errorf("log longer than %d bytes", MaxRawLogLength))
+			continue
+		}
+		log.fill()
+		if log.Unix == 0 {
+			log.Unix = now
+		}
+		logs = append(logs, log)
+	}
+	result.Accepted = len(logs)
+	return logs, result, nil
+}
+
+// ReportAppLogs sends a batch of AppLog objects, each encoded as a JSON line, to url.
+func ReportAppLogs(url string, lines []string) error {
+	return reportBatch(url, ndjsonContentType, strings.Join(lines, "\n"))
+}
+
+func reportBatch(url, contentType, body string) error {
+	resp, err := http.Post(url, contentType, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not report: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode != http.StatusAccepted {
+		return &ReportError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+func (log *AppLog) GetUnixFormatted() string {
+	return time.Unix(log.Unix, 0).Format("02.01.2006 15:04:05")
+}
+
+// GetLevelClass colors the level on the dashboard.
+func (log *AppLog) GetLevelClass() string {
+	switch log.Level {
+	case LevelFatal, LevelError:
+		return "bg-danger"
+	case LevelWarn:
+		return "bg-warning"
+	case LevelInfo:
+		return "bg-success"
+	}
+	return "bg-secondary"
+}
+
+// Trace returns the lines following the first one, like a stack trace.
+func (log *AppLog) Trace() string {
+	if i := strings.IndexByte(log.Raw, '\n'); i >= 0 {
+		return log.Raw[i+1:]
+	}
+	return ""
+}
+
+// addAppLogs appends logs, keeping only the latest MaxAppLogs.
+func (a *App) addAppLogs(logs ...AppLog) {
+	a.AppLogs = append(a.AppLogs, logs...)
+	if len(a.AppLogs) > MaxAppLogs {
+		a.AppLogs = a.AppLogs[len(a.AppLogs)-MaxAppLogs:]
+	}
+}
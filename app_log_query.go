@@ -0,0 +1,117 @@
+package mond
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const DefaultDashboardAppLogsLimit = 200
+
+// AppLogQuery filters and sorts the application logs of an app. Its zero value matches everything
+// in the order the logs were recorded.
+type AppLogQuery struct {
+	From     int64
+	To       int64
+	MinLevel string
+	Logger   string
+	Search   string
+	Desc     bool
+	Limit    int
+	SourceFilter
+}
+
+// ParseAppLogQuery reads an AppLogQuery from request parameters:
+//
+//	from, to  unix seconds, RFC 3339 or 2006-01-02 of the system time, both inclusive
+//	level     least level like warn, which matches warn, error and fatal
+//	logger    logger prefix
+//	stream    stdout or stderr
+//	pid       process id
+//	host      host of the client
+//	instance  instance id of the client
+//	q         case insensitive text in the raw lines
+//	sort      asc (default) or desc, the order logs were recorded in
+//	limit     number of logs
+func ParseAppLogQuery(values url.Values) (AppLogQuery, error) {
+	var q AppLogQuery
+	var err error
+
+	if q.From, err = parseQueryTime(values.Get("from")); err != nil {
+		return q, fmt.Errorf("invalid from, %v", err)
+	}
+	if q.To, err = parseQueryTime(values.Get("to")); err != nil {
+		return q, fmt.Errorf("invalid to, %v", err)
+	}
+	if level := values.Get("level"); level != "" {
+		if q.MinLevel = NormalizeLevel(level); q.MinLevel == "" {
+			return q, fmt.Errorf("invalid level %q, want one of %s", level, strings.Join(appLogLevels, ", "))
+		}
+	}
+	q.Logger = values.Get("logger")
+	if q.SourceFilter, err = parseSourceFilter(values); err != nil {
+		return q, err
+	}
+	q.Search = strings.ToLower(values.Get("q"))
+
+	switch values.Get("sort") {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, fmt.Errorf("invalid sort %q, want asc or desc", values.Get("sort"))
+	}
+	if limit := values.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit < 0 {
+			return q, fmt.Errorf("invalid limit %q", limit)
+		}
+	}
+	return q, nil
+}
+
+// Matches reports whether log passes all filters of the query.
+func (q AppLogQuery) Matches(log AppLog) bool {
+	if q.From != 0 && log.Unix < q.From {
+		return false
+	}
+	if q.To != 0 && log.Unix > q.To {
+		return false
+	}
+	if q.MinLevel != "" && levelRank(log.Level) < levelRank(q.MinLevel) {
+		return false
+	}
+	if q.Logger != "" && !strings.HasPrefix(log.Logger, q.Logger) {
+		return false
+	}
+	if !q.MatchesSource(log.LogSource) {
+		return false
+	}
+	if q.Search != "" && !strings.Contains(strings.ToLower(log.Raw), q.Search) {
+		return false
+	}
+	return true
+}
+
+// Apply returns the matching application logs of app, which may be nil.
+func (q AppLogQuery) Apply(app *App) []AppLog {
+	logs := []AppLog{}
+	if app == nil {
+		return logs
+	}
+	n := len(app.AppLogs)
+	for i := 0; i < n; i++ {
+		log := app.AppLogs[i]
+		if q.Desc {
+			log = app.AppLogs[n-1-i]
+		}
+		if !q.Matches(log) {
+			continue
+		}
+		logs = append(logs, log)
+		if q.Limit > 0 && len(logs) >= q.Limit {
+			break
+		}
+	}
+	return logs
+}
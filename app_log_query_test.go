@@ -0,0 +1,65 @@
+package mond
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestAppLogQuery(t *testing.T) {
+	app := &App{AppLogs: []AppLog{
+		{Unix: 10, Level: LevelInfo, Logger: "com.example.Web", Raw: "INFO started", LogSource: LogSource{Stream: StreamStdout, Pid: 7, Host: "web-1", Instance: "a1"}},
+		{Unix: 20, Level: LevelError, Logger: "com.example.Db", Raw: "ERROR Connection refused", LogSource: LogSource{Stream: StreamStderr}},
+		{Unix: 30, Raw: "no level", LogSource: LogSource{Stream: StreamStderr}},
+		{Unix: 40, Level: LevelFatal, Raw: "panic: boom", LogSource: LogSource{Stream: StreamStderr}},
+	}}
+
+	cases := []struct {
+		query string
+		want  []int64
+	}{
+		{"", []int64{10, 20, 30, 40}},
+		{"level=warning", []int64{20, 40}},
+		{"level=info&stream=stdout", []int64{10}},
+		{"logger=com.example.", []int64{10, 20}},
+		{"q=connection", []int64{20}},
+		{"host=WEB-1&pid=7&instance=a1", []int64{10}},
+		{"instance=b2", nil},
+		{"from=15&to=35", []int64{20, 30}},
+		{"sort=desc&limit=2", []int64{40, 30}},
+	}
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			values, _ := url.ParseQuery(c.query)
+			query, err := ParseAppLogQuery(values)
+			assertNoError(t, err)
+
+			var got []int64
+			for _, log := range query.Apply(app) {
+				got = append(got, log.Unix)
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("got %v want %v", got, c.want)
+			}
+			for i := range got {
+				if got[i] != c.want[i] {
+					t.Fatalf("got %v want %v", got, c.want)
+				}
+			}
+		})
+	}
+
+	t.Run("rejects invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"level=loud", "sort=up", "limit=-1", "from=yesterday", "pid=none"} {
+			values, _ := url.ParseQuery(query)
+			if _, err := ParseAppLogQuery(values); err == nil {
+				t.Errorf("expected an error for %q", query)
+			}
+		}
+	})
+
+	t.Run("matches nothing without an app", func(t *testing.T) {
+		if logs := (AppLogQuery{}).Apply(nil); len(logs) != 0 {
+			t.Errorf("got %v want nothing", logs)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package mond
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAppLog(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  string
+		want AppLog
+	}{
+		{
+			name: "json",
+			raw:  `{"level":"WARNING","ts":"2021-07-02T20:50:59Z","logger":"db","msg":"slow query","table":"users","rows":12}`,
+			want: AppLog{Level: LevelWarn, Timestamp: 1625259059, Logger: "db", Message: "slow query", Fields: map[string]string{"table": "users", "rows": "12"}},
+		},
+		{
+			name: "logfmt",
+			raw:  `time=2021-07-02T20:50:59Z level=error msg="cannot connect" host=db1`,
+			want: AppLog{Level: LevelError, Timestamp: 1625259059, Message: "cannot connect", Fields: map[string]string{"host": "db1"}},
+		},
+		{
+			name: "logback with stack trace",
+			raw:  "2021-07-02 22:50:59.123 ERROR [main] com.example.App - request failed\njava.lang.IllegalStateException: boom\n\tat com.example.App.main(App.java:5)",
+			want: AppLog{Level: LevelError, Timestamp: time.Date(2021, 7, 2, 22, 50, 59, 0, time.Local).Unix(), Logger: "com.example.App", Message: "request failed"},
+		},
+		{
+			name: "go panic",
+			raw:  "panic: runtime error: index out of range [3] with length 2\n\ngoroutine 1 [running]:\nmain.main()",
+			want: AppLog{Level: LevelFatal, Message: "panic: runtime error: index out of range [3] with length 2"},
+		},
+		{
+			name: "python traceback",
+			raw:  "Traceback (most recent call last):\n  File \"app.py\", line 1, in <module>\nValueError: bad",
+			want: AppLog{Level: LevelError, Message: "Traceback (most recent call last):"},
+		},
+		{
+			name: "text without a level",
+			raw:  "Listening on :8080",
+			want: AppLog{Message: "Listening on :8080"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := ParseAppLog(c.raw)
+			c.want.Raw = c.raw
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %+v want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseAppLogBatch(t *testing.T) {
+	t.Run("keeps what the client sent", func(t *testing.T) {
+		body := `{"unix":5,"stream":"stderr","level":"SEVERE","raw":"INFO starting"}
+{"raw":""}
+not json`
+		logs, result, err := ParseAppLogBatch([]byte(body), ndjsonContentType)
+		assertNoError(t, err)
+		assertBatchResult(t, result, 1, 2)
+
+		want := AppLog{Unix: 5, Level: LevelError, Message: "starting", Raw: "INFO starting", LogSource: LogSource{Stream: StreamStderr}}
+		if len(logs) != 1 || !reflect.DeepEqual(logs[0], want) {
+			t.Errorf("got %+v want %+v", logs, want)
+		}
+	})
+
+	t.Run("reads a line per entry from text", func(t *testing.T) {
+		logs, result, err := ParseAppLogBatch([]byte("WARN a\r\n\nDEBUG b"), textContentType)
+		assertNoError(t, err)
+		assertBatchResult(t, result, 2, 0)
+
+		if logs[0].Level != LevelWarn || logs[1].Level != LevelDebug || logs[0].Raw != "WARN a" || logs[1].Unix == 0 {
+			t.Errorf("got %+v", logs)
+		}
+	})
+}
+
+func TestAddAppLogs(t *testing.T) {
+	var app App
+	for i := 0; i < MaxAppLogs+5; i++ {
+		app.addAppLogs(AppLog{Unix: int64(i)})
+	}
+	if len(app.AppLogs) != MaxAppLogs || app.AppLogs[0].Unix != 5 {
+		t.Errorf("got %d logs starting at %d want %d starting at 5", len(app.AppLogs), app.AppLogs[0].Unix, MaxAppLogs)
+	}
+}
@@ -12,6 +12,15 @@ import (
 
 type Apps []App
 
+// snapshot copies the apps so that later changes of the store do not show up in the copy.
+func (a Apps) snapshot() Apps {
+	apps := make(Apps, len(a))
+	for i, v := range a {
+		apps[i] = v.snapshot()
+	}
+	return apps
+}
+
 func (a Apps) Find(name string) *App {
 	for i, v := range a {
 		if v.Name == name {
@@ -32,19 +41,134 @@ func NewApps(rdr io.Reader) (Apps, error) {
 	return apps, err
 }
 
+// salvageApps decodes all complete apps and logs from a damaged apps database,
+// stopping at the first record which cannot be read.
+func salvageApps(rdr io.Reader) Apps {
+	var apps Apps
+	dec := json.NewDecoder(rdr)
+	if !expectDelim(dec, '[') {
+		return apps
+	}
+	for dec.More() {
+		app, complete := salvageApp(dec)
+		if app.Name != "" || len(app.Logs) > 0 {
+			apps = append(apps, app)
+		}
+		if !complete {
+			break
+		}
+	}
+	return apps
+}
+
+func salvageApp(dec *json.Decoder) (App, bool) {
+	var app App
+	fields := map[string]json.RawMessage{}
+	complete := salvageAppFields(dec, &app, fields)
+
+	// everything besides the logs is small enough to be either complete or missing
+	b, _ := json.Marshal(fields)
+	json.Unmarshal(b, &app)
+	return app, complete
+}
+
+func salvageAppFields(dec *json.Decoder, app *App, fields map[string]json.RawMessage) bool {
+	if !expectDelim(dec, '{') {
+		return false
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		key, _ := tok.(string)
+		if key != "logs" {
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return false
+			}
+			fields[key] = value
+			continue
+		}
+
+		tok, err = dec.Token()
+		if err != nil {
+			return false
+		}
+		if tok == nil {
+			continue
+		}
+		if tok != json.Delim('[') {
+			return false
+		}
+		for dec.More() {
+			var l AccessLog
+			if err := dec.Decode(&l); err != nil {
+				return false
+			}
+			app.Logs = append(app.Logs, l)
+		}
+		if !expectDelim(dec, ']') {
+			return false
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) bool {
+	tok, err := dec.Token()
+	return err == nil && tok == delim
+}
+
 // App
 
 type App struct {
 	Name   string      `json:"app"`
 	Health HealthCheck `json:"health"`
 	Logs   AccessLogs  `json:"logs"`
+	// Pruned counts the logs dropped by retention, it is also the position of the first log kept.
+	Pruned int            `json:"pruned,omitempty"`
+	Events []ProcessEvent `json:"events,omitempty"`
+	// HealthReceived is the server time of the last health report, HealthSince the time the
+	// current status began. An app which missed its reports is Stale.
+	HealthReceived int64          `json:"healthReceived,omitempty"`
+	HealthSince    int64          `json:"healthSince,omitempty"`
+	Stale          bool           `json:"stale,omitempty"`
+	HealthHistory  []HealthRecord `json:"healthHistory,omitempty"`
+	Targets        []HealthCheck  `json:"targets,omitempty"`
+	AppLogs        []AppLog       `json:"appLogs,omitempty"`
+}
+
+// snapshot copies the app. Its slices keep sharing the backing arrays of the store, as stores
+// only ever append to them, but are capped so that appends never show up in the snapshot.
+func (a App) snapshot() App {
+	a.Logs = a.Logs[:len(a.Logs):len(a.Logs)]
+	a.Events = a.Events[:len(a.Events):len(a.Events)]
+	a.HealthHistory = a.HealthHistory[:len(a.HealthHistory):len(a.HealthHistory)]
+	a.AppLogs = a.AppLogs[:len(a.AppLogs):len(a.AppLogs)]
+	return a
 }
 
+// GetLogsSorted returns a copy of the logs sorted by system time, newest first.
 func (a *App) GetLogsSorted() AccessLogs {
-	sort.Slice(a.Logs, func(i, j int) bool {
-		return a.Logs[i].Unix > a.Logs[j].Unix
+	logs := make(AccessLogs, len(a.Logs))
+	copy(logs, a.Logs)
+	sort.SliceStable(logs, func(i, j int) bool {
+		return logs[i].Unix > logs[j].Unix
 	})
-	return a.Logs
+	return logs
+}
+
+// pruneLogs drops the count oldest logs.
+func (a *App) pruneLogs(count int) {
+	if count <= 0 {
+		return
+	}
+	if count > len(a.Logs) {
+		count = len(a.Logs)
+	}
+	a.Logs = a.Logs[count:]
+	a.Pruned += count
 }
 
 func (a *App) GetLogCountPerDay() map[string]int {
@@ -0,0 +1,295 @@
+package mond
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultCheckTimeout = Duration(10 * time.Second)
+const DefaultCertWarnDays = 14
+
+// Checker types registered by default.
+const (
+	CheckerHttp    = "http"
+	CheckerTcp     = "tcp"
+	CheckerDns     = "dns"
+	CheckerTls     = "tls"
+	CheckerCommand = "command"
+)
+
+// maxCommandOutput limits how much of the output of a check command ends up in the HealthCheck.
+const maxCommandOutput = 512
+
+// CheckResult is what a Checker found out. A Reason on an Up result is a warning. ExitCode and
+// Output are only set by checkers running a command.
+type CheckResult struct {
+	Up       bool
+	Latency  time.Duration
+	Reason   string
+	ExitCode *int
+	Output   string
+}
+
+// Checker probes a target of the type it is registered for.
+type Checker func(target HealthTarget) CheckResult
+
+var (
+	checkersMu sync.RWMutex
+	checkers   = map[string]Checker{
+		CheckerHttp:    checkHttp,
+		CheckerTcp:     checkTcp,
+		CheckerDns:     checkDns,
+		CheckerTls:     checkTls,
+		CheckerCommand: checkCommand,
+	}
+)
+
+// RegisterChecker makes checker available for targets of type name, replacing an earlier one.
+func RegisterChecker(name string, checker Checker) {
+	checkersMu.Lock()
+	defer checkersMu.Unlock()
+	checkers[name] = checker
+}
+
+func lookupChecker(name string) (Checker, bool) {
+	checkersMu.RLock()
+	defer checkersMu.RUnlock()
+	checker, ok := checkers[name]
+	return checker, ok
+}
+
+// DnsCheck resolves the host of a dns target, through Resolver ("host:port") if it is set. All of
+// ExpectAddresses have to be among the addresses found.
+type DnsCheck struct {
+	Resolver        string   `json:"resolver,omitempty"`
+	ExpectAddresses []string `json:"expectAddresses,omitempty"`
+}
+
+// TlsCheck connects to a tls target and warns WarnDays before its certificate expires. ServerName
+// defaults to the host of the target, SkipVerify accepts certificates which cannot be verified.
+type TlsCheck struct {
+	ServerName string `json:"serverName,omitempty"`
+	WarnDays   int    `json:"warnDays,omitempty"`
+	SkipVerify bool   `json:"skipVerify,omitempty"`
+}
+
+// CommandCheck runs Command, split by ParseCommandLine, and expects it to exit with 0.
+type CommandCheck struct {
+	Command string `json:"command,omitempty"`
+}
+
+// Check runs the Checker of the target and returns its health.
+func (t HealthTarget) Check() HealthCheck {
+	check := HealthCheck{Status: "DOWN", Timestamp: time.Now().Unix()}
+	checker, ok := lookupChecker(t.checkerType())
+	if !ok {
+		check.Reason = fmt.Sprintf("unknown check type %q", t.checkerType())
+		return check
+	}
+	result := checker(t)
+	check.Latency = result.Latency.Milliseconds()
+	check.Reason = result.Reason
+	check.ExitCode = result.ExitCode
+	check.Output = result.Output
+	if result.Up {
+		check.Status = "UP"
+		check.Slow = t.SlowAfter > 0 && result.Latency > time.Duration(t.SlowAfter)
+	}
+	return check
+}
+
+// checkNamed checks the target and puts its name and url into the result.
+func (t HealthTarget) checkNamed() HealthCheck {
+	check := t.Check()
+	check.Target = t.reportedName()
+	check.Url = t.Url
+	return check
+}
+
+func (t HealthTarget) checkerType() string {
+	if t.Type != "" {
+		return t.Type
+	}
+	if i := strings.Index(t.Url, "://"); i > 0 {
+		if _, ok := lookupChecker(t.Url[:i]); ok {
+			return t.Url[:i]
+		}
+	}
+	if t.Command != "" {
+		return CheckerCommand
+	}
+	return CheckerHttp
+}
+
+func (t HealthTarget) timeout() time.Duration {
+	if t.Timeout <= 0 {
+		return time.Duration(DefaultCheckTimeout)
+	}
+	return time.Duration(t.Timeout)
+}
+
+// address returns host:port of the target, its Url may carry a scheme.
+func (t HealthTarget) address(defaultPort string) string {
+	address := t.Url
+	if i := strings.Index(address, "://"); i >= 0 {
+		address = address[i+3:]
+	}
+	if i := strings.IndexAny(address, "/?"); i >= 0 {
+		address = address[:i]
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil && defaultPort != "" {
+		address = net.JoinHostPort(strings.Trim(address, "[]"), defaultPort)
+	}
+	return address
+}
+
+// CheckTargets checks all targets concurrently and returns their health in the same order.
+func CheckTargets(targets []HealthTarget) []HealthCheck {
+	checks := make([]HealthCheck, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t HealthTarget) {
+			defer wg.Done()
+			checks[i] = t.checkNamed()
+		}(i, t)
+	}
+	wg.Wait()
+	return checks
+}
+
+// checkTcp connects to the port of the target.
+func checkTcp(t HealthTarget) CheckResult {
+	started := time.Now()
+	conn, err := net.DialTimeout("tcp", t.address(""), t.timeout())
+	latency := time.Since(started)
+	if err != nil {
+		return CheckResult{Latency: latency, Reason: err.Error()}
+	}
+	conn.Close()
+	return CheckResult{Up: true, Latency: latency}
+}
+
+// checkDns resolves the host of the target.
+func checkDns(t HealthTarget) CheckResult {
+	host := t.address("")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	resolver := net.DefaultResolver
+	if t.Resolver != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, t.Resolver)
+			},
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout())
+	defer cancel()
+
+	started := time.Now()
+	addresses, err := resolver.LookupHost(ctx, host)
+	latency := time.Since(started)
+	if err != nil {
+		return CheckResult{Latency: latency, Reason: err.Error()}
+	}
+	for _, want := range t.ExpectAddresses {
+		if !containsString(addresses, want) {
+			return CheckResult{Latency: latency, Reason: fmt.Sprintf("%s resolved to %s, not %s", host, strings.Join(addresses, ","), want)}
+		}
+	}
+	return CheckResult{Up: true, Latency: latency}
+}
+
+// checkTls does a handshake with the target and looks at the expiry of its certificate.
+func checkTls(t HealthTarget) CheckResult {
+	address := t.address("443")
+	serverName := t.ServerName
+	if serverName == "" {
+		serverName, _, _ = net.SplitHostPort(address)
+	}
+	started := time.Now()
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: t.timeout()}, "tcp", address, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: t.SkipVerify,
+	})
+	latency := time.Since(started)
+	if err != nil {
+		return CheckResult{Latency: latency, Reason: err.Error()}
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return CheckResult{Latency: latency, Reason: "no certificate"}
+	}
+	left := time.Until(certs[0].NotAfter)
+	if left <= 0 {
+		return CheckResult{Latency: latency, Reason: fmt.Sprintf("certificate expired on %s", certs[0].NotAfter.Format("2006-01-02"))}
+	}
+	warnDays := t.WarnDays
+	if warnDays <= 0 {
+		warnDays = DefaultCertWarnDays
+	}
+	result := CheckResult{Up: true, Latency: latency}
+	if days := int(left.Hours() / 24); days < warnDays {
+		result.Reason = fmt.Sprintf("certificate expires in %d days", days)
+	}
+	return result
+}
+
+// checkCommand runs the command of the target. Its exit code and output are kept, the output
+// becomes the reason too if it fails.
+func checkCommand(t HealthTarget) CheckResult {
+	line, err := ParseCommandLine(t.Command)
+	if err != nil {
+		return CheckResult{Reason: err.Error()}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, line.Args[0], line.Args[1:]...)
+	if len(line.Env) > 0 {
+		cmd.Env = append(os.Environ(), line.Env...)
+	}
+
+	started := time.Now()
+	out, err := cmd.CombinedOutput()
+	result := CheckResult{Up: err == nil, Latency: time.Since(started)}
+	result.Output = strings.TrimSpace(string(out))
+	if len(result.Output) > maxCommandOutput {
+		result.Output = result.Output[len(result.Output)-maxCommandOutput:]
+	}
+	if cmd.ProcessState != nil && ctx.Err() == nil {
+		code := cmd.ProcessState.ExitCode()
+		result.ExitCode = &code
+	}
+	if err == nil {
+		return result
+	}
+	result.Reason = err.Error()
+	if ctx.Err() != nil {
+		result.Reason = fmt.Sprintf("timed out after %s", t.timeout())
+	}
+	if result.Output != "" {
+		result.Reason += ": " + result.Output
+	}
+	return result
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
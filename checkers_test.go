@@ -0,0 +1,79 @@
+package mond
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckers(t *testing.T) {
+	t.Run("tcp connects to open ports only", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assertNoError(t, err)
+		address := listener.Addr().String()
+
+		assertCheck(t, HealthTarget{Url: "tcp://" + address}, "UP", "")
+		listener.Close()
+		assertCheck(t, HealthTarget{Type: CheckerTcp, Url: address}, "DOWN", "refused")
+	})
+
+	t.Run("dns resolves hosts and expects addresses", func(t *testing.T) {
+		assertCheck(t, HealthTarget{Url: "dns://localhost", DnsCheck: DnsCheck{ExpectAddresses: []string{"127.0.0.1"}}}, "UP", "")
+		assertCheck(t, HealthTarget{Url: "dns://localhost", DnsCheck: DnsCheck{ExpectAddresses: []string{"10.9.9.9"}}}, "DOWN", "not 10.9.9.9")
+	})
+
+	t.Run("dns asks the configured resolver", func(t *testing.T) {
+		listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+		assertNoError(t, err)
+		defer listener.Close()
+
+		target := HealthTarget{Url: "dns://example.com", Timeout: Duration(100 * time.Millisecond), DnsCheck: DnsCheck{Resolver: listener.LocalAddr().String()}}
+		assertCheck(t, target, "DOWN", "timeout")
+	})
+
+	t.Run("tls checks the handshake and the certificate expiry", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+		address := strings.TrimPrefix(server.URL, "https://")
+
+		assertCheck(t, HealthTarget{Url: "tls://" + address}, "DOWN", "certificate")
+		assertCheck(t, HealthTarget{Url: "tls://" + address, TlsCheck: TlsCheck{SkipVerify: true}}, "UP", "")
+		assertCheck(t, HealthTarget{Url: "tls://" + address, TlsCheck: TlsCheck{SkipVerify: true, WarnDays: 1000000}}, "UP", "certificate expires in")
+	})
+
+	t.Run("command turns exit code and output into the health", func(t *testing.T) {
+		assertCheck(t, HealthTarget{CommandCheck: CommandCheck{Command: "sh -c 'exit 0'"}}, "UP", "")
+		assertCheck(t, HealthTarget{CommandCheck: CommandCheck{Command: `sh -c 'echo queue full; exit 2'`}}, "DOWN", "exit status 2: queue full")
+		assertCheck(t, HealthTarget{Timeout: Duration(50 * time.Millisecond), CommandCheck: CommandCheck{Command: "sleep 5"}}, "DOWN", "timed out")
+		assertCheck(t, HealthTarget{CommandCheck: CommandCheck{Command: "LEVEL=ok sh -c 'test $LEVEL = ok'"}}, "UP", "")
+
+		up := HealthTarget{CommandCheck: CommandCheck{Command: `sh -c 'echo "3 jobs queued "'`}}.Check()
+		if up.ExitCode == nil || *up.ExitCode != 0 || up.Output != "3 jobs queued" {
+			t.Errorf("got %+v want exit code 0 with the output", up)
+		}
+		down := HealthTarget{CommandCheck: CommandCheck{Command: `sh -c 'echo queue full; exit 2'`}}.Check()
+		if down.ExitCode == nil || *down.ExitCode != 2 || down.Output != "queue full" {
+			t.Errorf("got %+v want exit code 2 with the output", down)
+		}
+	})
+
+	t.Run("uses registered checkers and rejects unknown types", func(t *testing.T) {
+		RegisterChecker("always", func(target HealthTarget) CheckResult {
+			return CheckResult{Up: true, Reason: "checked " + target.Url}
+		})
+
+		assertCheck(t, HealthTarget{Url: "always://x"}, "UP", "checked always://x")
+		assertCheck(t, HealthTarget{Type: "never", Url: "x"}, "DOWN", `unknown check type "never"`)
+	})
+}
+
+func assertCheck(t testing.TB, target HealthTarget, status, reason string) {
+	t.Helper()
+	check := target.Check()
+	if check.Status != status || !strings.Contains(check.Reason, reason) || (reason == "" && check.Reason != "") {
+		t.Errorf("got %+v want %s with reason %q", check, status, reason)
+	}
+}
@@ -0,0 +1,111 @@
+package mond
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+)
+
+// ClientConfig describes what mond-client runs and where it reports to. Command is parsed by
+// ParseCommandLine, Args are appended to it as they are.
+type ClientConfig struct {
+	AppName       string            `json:"appName"`
+	ServerUrl     string            `json:"serverUrl"`
+	User          string            `json:"user"`
+	Password      string            `json:"password"`
+	Command       string            `json:"command"`
+	Args          []string          `json:"args"`
+	Dir           string            `json:"dir"`
+	Env           map[string]string `json:"env"`
+	HealthTargets []HealthTarget    `json:"healthTargets"`
+	AppLogs       *AppLogsConfig    `json:"appLogs"`
+	Host          string            `json:"host"`
+	InstanceId    string            `json:"instanceId"`
+}
+
+// AppLogsConfig makes mond-client send the lines of Streams, all of them if it is empty, as
+// application logs grouped by Multiline instead of as access logs.
+type AppLogsConfig struct {
+	Streams   []string        `json:"streams"`
+	Multiline MultilineConfig `json:"multiline"`
+}
+
+// Includes reports whether the lines of stream are application logs.
+func (c *AppLogsConfig) Includes(stream string) bool {
+	return c != nil && (len(c.Streams) == 0 || containsString(c.Streams, stream))
+}
+
+// LoadClientConfig reads a ClientConfig from the JSON file found at path.
+func LoadClientConfig(path string) (ClientConfig, error) {
+	var config ClientConfig
+	file, err := os.Open(path)
+	if err != nil {
+		return config, fmt.Errorf("problem opening config %s, %v", path, err)
+	}
+	defer file.Close()
+
+	dec := json.NewDecoder(file)
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&config)
+	if err != nil {
+		return config, fmt.Errorf("problem parsing config %s, %v", path, err)
+	}
+	return config, nil
+}
+
+// Source returns the host and instance every forwarded line is tagged with. They default to the
+// host name and a random id.
+func (c *ClientConfig) Source() LogSource {
+	source := LogSource{Host: c.Host, Instance: c.InstanceId}
+	if source.Host == "" {
+		source.Host, _ = os.Hostname()
+	}
+	if source.Instance == "" {
+		source.Instance = NewInstanceId()
+	}
+	return source
+}
+
+// ReportUrl returns the server url carrying the credentials, if any are set.
+func (c *ClientConfig) ReportUrl() (string, error) {
+	u, err := url.ParseRequestURI(c.ServerUrl)
+	if err != nil {
+		return "", fmt.Errorf("invalid server url %q, %v", c.ServerUrl, err)
+	}
+	if c.User != "" {
+		u.User = url.UserPassword(c.User, c.Password)
+	}
+	return u.String(), nil
+}
+
+// NewCommand returns a function creating the configured command, which runs in Dir with the
+// environment of mond-client plus Env and the assignments in front of Command.
+func (c *ClientConfig) NewCommand() (func() *exec.Cmd, error) {
+	line, err := ParseCommandLine(c.Command)
+	if err != nil {
+		return nil, fmt.Errorf("problem parsing command, %v", err)
+	}
+	args := append(line.Args[1:len(line.Args):len(line.Args)], c.Args...)
+
+	env := os.Environ()
+	names := make([]string, 0, len(c.Env))
+	for name := range c.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+c.Env[name])
+	}
+	env = append(env, line.Env...)
+
+	dir := c.Dir
+	return func() *exec.Cmd {
+		cmd := exec.Command(line.Args[0], args...)
+		cmd.Dir = dir
+		cmd.Env = env
+		return cmd
+	}, nil
+}
@@ -0,0 +1,128 @@
+package mond
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// TrustedProxies are the networks of the proxies whose forwarded headers are believed.
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies reads networks like 10.0.0.0/8 or fd00::/8 and single addresses.
+func ParseTrustedProxies(entries []string) (TrustedProxies, error) {
+	var proxies TrustedProxies
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := ParseIp(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q, want an ip or a cidr like 10.0.0.0/8", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				bits = 8 * net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q, want an ip or a cidr like 10.0.0.0/8", entry)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// Contains reports whether ip is the address of a trusted proxy.
+func (t TrustedProxies) Contains(ip net.IP) bool {
+	for _, network := range t {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Client returns the address of the client a request from peer was forwarded for according to
+// header, an X-Forwarded-For or Forwarded header. Without trusted proxies that is the first
+// address of the chain. Otherwise the chain is followed back from peer as long as the proxies
+// are trusted. Without a usable header or if peer itself is no trusted proxy, peer is the client.
+func (t TrustedProxies) Client(peer, header string) string {
+	chain := ForwardedChain(header)
+	if len(t) == 0 {
+		for _, entry := range chain {
+			if ip := ParseIp(entry); ip != nil {
+				return ip.String()
+			}
+		}
+		return ipString(ParseIp(peer))
+	}
+	if ip := ParseIp(peer); ip == nil || !t.Contains(ip) || len(chain) == 0 {
+		return ipString(ip)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := ParseIp(chain[i])
+		if ip == nil {
+			return ""
+		}
+		if !t.Contains(ip) {
+			return ip.String()
+		}
+	}
+	return ipString(ParseIp(chain[0]))
+}
+
+// ForwardedChain returns the addresses of an X-Forwarded-For header like "203.0.113.7, 10.0.0.2"
+// or of the for parameters of a Forwarded header, the client first.
+func ForwardedChain(header string) []string {
+	var chain []string
+	for _, element := range strings.Split(header, ",") {
+		element = strings.TrimSpace(element)
+		if element == "" || element == "-" {
+			continue
+		}
+		if !strings.Contains(element, "=") {
+			chain = append(chain, element)
+			continue
+		}
+		for _, pair := range strings.Split(element, ";") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+				chain = append(chain, strings.Trim(kv[1], `"`))
+			}
+		}
+	}
+	return chain
+}
+
+// ParseIp reads an IPv4 or IPv6 address with or without port, like 10.0.0.1, 10.0.0.1:80, ::1 or
+// [::1]:80. It returns nil for anything else.
+func ParseIp(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if ip := net.ParseIP(s); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		return net.ParseIP(host)
+	}
+	return net.ParseIP(strings.Trim(s, "[]"))
+}
+
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// resolveClients makes parser return valid addresses only, RemoteIp being the client resolved
+// from the forwarded header the parser found.
+func resolveClients(parser LogParser, trusted TrustedProxies) LogParser {
+	return LogParserFunc(func(raw string) (AccessLog, bool) {
+		log, ok := parser.Parse(raw)
+		log.RemoteIp = trusted.Client(log.Ip, log.RemoteIp)
+		log.Ip = ipString(ParseIp(log.Ip))
+		return log, ok
+	})
+}
@@ -0,0 +1,90 @@
+package mond
+
+import (
+	"testing"
+)
+
+func TestParseIp(t *testing.T) {
+	cases := map[string]string{
+		"10.0.0.1":                 "10.0.0.1",
+		"10.0.0.1:4711":            "10.0.0.1",
+		" 2001:DB8::1 ":            "2001:db8::1",
+		"[2001:db8::1]:443":        "2001:db8::1",
+		"[::1]":                    "::1",
+		"::ffff:192.0.2.1":         "192.0.2.1",
+		"fe80::1%eth0":             "",
+		"256.0.0.1":                "",
+		"example.com":              "",
+		"unknown":                  "",
+		"10.129.38.1\"":            "",
+		"2001:db8::1, 10.0.0.1":    "",
+		"":                         "",
+		"_hidden":                  "",
+		"192.0.2.60:http":          "192.0.2.60",
+		"[2001:db8:cafe::17]:4711": "2001:db8:cafe::17",
+	}
+	for s, want := range cases {
+		if got := ipString(ParseIp(s)); got != want {
+			t.Errorf("got %q for %q want %q", got, s, want)
+		}
+	}
+}
+
+func TestForwardedChain(t *testing.T) {
+	t.Run("x-forwarded-for", func(t *testing.T) {
+		assertStringArray(t, ForwardedChain("203.0.113.7, 2001:db8::2 ,10.0.0.2"), []string{"203.0.113.7", "2001:db8::2", "10.0.0.2"})
+	})
+
+	t.Run("forwarded", func(t *testing.T) {
+		got := ForwardedChain(`for=192.0.2.60;proto=http;by=203.0.113.43, For="[2001:db8:cafe::17]:4711"`)
+		assertStringArray(t, got, []string{"192.0.2.60", "[2001:db8:cafe::17]:4711"})
+	})
+
+	t.Run("no header", func(t *testing.T) {
+		if got := ForwardedChain("-"); len(got) != 0 {
+			t.Errorf("got %v want nothing", got)
+		}
+	})
+}
+
+func TestTrustedProxies(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "fd00::/8", "192.0.2.1"})
+	assertNoError(t, err)
+
+	cases := []struct {
+		name    string
+		proxies TrustedProxies
+		peer    string
+		header  string
+		want    string
+	}{
+		{"first of the chain without trusted proxies", nil, "10.0.0.1", "203.0.113.7, 198.51.100.1", "203.0.113.7"},
+		{"first valid address without trusted proxies", nil, "10.0.0.1", "unknown, 203.0.113.7", "203.0.113.7"},
+		{"peer without a header", trusted, "10.0.0.1", "", "10.0.0.1"},
+		{"direct client without port", trusted, "203.0.113.7:52100", "", "203.0.113.7"},
+		{"direct client without trusted proxies", nil, "[2001:db8::7]:52100", "", "2001:db8::7"},
+		{"last untrusted address", trusted, "10.0.0.1", "203.0.113.7, 198.51.100.1, 10.0.0.5", "198.51.100.1"},
+		{"through an ipv6 proxy", trusted, "fd00::1", "2001:db8::7", "2001:db8::7"},
+		{"through a single trusted address", trusted, "192.0.2.1", "198.51.100.1", "198.51.100.1"},
+		{"untrusted peer", trusted, "198.51.100.9:443", "203.0.113.7", "198.51.100.9"},
+		{"peer with port", trusted, "10.0.0.1:443", "203.0.113.7", "203.0.113.7"},
+		{"nothing past an invalid address", trusted, "10.0.0.1", "203.0.113.7, _hidden", ""},
+		{"first of a fully trusted chain", trusted, "10.0.0.1", "10.0.0.7, 10.0.0.8", "10.0.0.7"},
+		{"forwarded header", trusted, "10.0.0.1", `for="[2001:db8:cafe::17]:4711", for=10.1.1.1`, "2001:db8:cafe::17"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.proxies.Client(c.peer, c.header); got != c.want {
+				t.Errorf("got %q want %q", got, c.want)
+			}
+		})
+	}
+
+	t.Run("rejects invalid entries", func(t *testing.T) {
+		for _, entry := range []string{"10.0.0.0/33", "localhost", ""} {
+			if _, err := ParseTrustedProxies([]string{entry}); err == nil {
+				t.Errorf("expected an error for %q", entry)
+			}
+		}
+	})
+}
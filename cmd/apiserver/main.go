@@ -10,22 +10,78 @@ import (
 )
 
 const dbFileNameEnv = "MOND_DB_FILE_NAME"
+const storeDirEnv = "MOND_STORE_DIR"
+const syncWritesEnv = "MOND_SYNC_WRITES"
+const configFileEnv = "MOND_CONFIG_FILE"
+const silencesFileEnv = "MOND_SILENCES_FILE"
 const usernameEnv = "MOND_USERNAME"
 const passwordEnv = "MOND_PW"
 const addrEnv = "MOND_SERVE_ADDR"
 const defaultDbFileName = "apps.db.json"
+const defaultSilencesFileName = "silences.json"
 const defaultUser = "test"
 const defaultPassword = "1234"
 const defaultAddr = ":8080" // TODO: change for local testing, prod=8080
 
 func main() {
-	store, closeFile, err := mond.FileSystemAppsStoreFromFile(dbFileNameFromEnv())
+	config, err := configFromEnv()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer closeFile()
+
+	store, closeStore, err := storeFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeStore()
+
+	silences, err := mond.LoadSilences(silencesFileFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	parsers, err := mond.NewLogParsers(config.Logs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var dispatcher *mond.Dispatcher
+	if config.Notify.IsEnabled() {
+		dispatcher, err = mond.NewDispatcher(config.Notify)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dispatcher.SetSilences(silences)
+		store = mond.WatchHealth(store, dispatcher.NotifyHealth)
+	}
+
+	if config.Retention.IsEnabled() {
+		stopRetention := mond.StartRetention(store, config.Retention)
+		defer stopRetention()
+	}
+
+	stopStaleness := mond.StartStaleness(store, config.Staleness)
+	defer stopStaleness()
+
+	if config.Probing.IsEnabled() {
+		stopProbing := mond.StartProbing(store, config.Probing)
+		defer stopProbing()
+	}
 
 	server := mond.NewApiServer(store, checkEnvSecurityInfo())
+	server.SetSilences(silences)
+	server.SetLogParsers(parsers)
+	var notifyAlert func(mond.Alert)
+	if dispatcher != nil {
+		server.SetDispatcher(dispatcher)
+		notifyAlert = dispatcher.NotifyAlert
+	}
+	if config.Alerting.IsEnabled() {
+		alerter := mond.NewAlerter(config.Alerting.Rules)
+		server.SetAlerter(alerter)
+		stopAlerting := mond.StartAlerting(store, alerter, config.Alerting.Interval, notifyAlert)
+		defer stopAlerting()
+	}
 	store.RecordHealth(mond.MondAppName, mond.HealthCheck{
 		Status:    "UP",
 		Timestamp: time.Now().Unix(),
@@ -33,6 +89,60 @@ func main() {
 	log.Fatal(http.ListenAndServe(addrFromEnv(), server))
 }
 
+func configFromEnv() (mond.ServerConfig, error) {
+	configFile := os.Getenv(configFileEnv)
+	if configFile == "" {
+		return mond.ServerConfig{}, nil
+	}
+	fmt.Println("Using config ", configFile)
+	return mond.LoadServerConfig(configFile)
+}
+
+// storeFromEnv uses the append only segment store if a store dir is set, the single json file otherwise.
+// An empty segment store starts with the apps of the json file.
+func storeFromEnv() (mond.AccessLogStore, func(), error) {
+	storeDir := os.Getenv(storeDirEnv)
+	if storeDir != "" {
+		fmt.Println("Using segment store in ", storeDir)
+		store, closeStore, err := mond.SegmentedAppsStoreFromDir(storeDir, syncWritesFromEnv(true))
+		if err != nil {
+			return nil, nil, err
+		}
+		imported, err := store.ImportAppsDBFile(dbFileNameFromEnv())
+		if err != nil {
+			closeStore()
+			return nil, nil, err
+		}
+		if imported > 0 {
+			fmt.Printf("Imported %d apps from %s\n", imported, dbFileNameFromEnv())
+		}
+		return store, closeStore, nil
+	}
+	return mond.FileSystemAppsStoreFromFile(dbFileNameFromEnv(), syncWritesFromEnv(false))
+}
+
+// syncWritesFromEnv tells whether every write is flushed to disk, by default only if sync is set.
+// The segment store does so by default, an append is cheap to flush. The json file is rewritten on
+// every change, flushing each of them is left to those who ask for it.
+func syncWritesFromEnv(sync bool) bool {
+	switch os.Getenv(syncWritesEnv) {
+	case "true", "1":
+		return true
+	case "false", "0":
+		fmt.Println("WARN: writes are not synced to disk!")
+		return false
+	}
+	return sync
+}
+
+func silencesFileFromEnv() string {
+	silencesFile := os.Getenv(silencesFileEnv)
+	if silencesFile == "" {
+		silencesFile = defaultSilencesFileName
+	}
+	return silencesFile
+}
+
 func dbFileNameFromEnv() string {
 	dbFileName := os.Getenv(dbFileNameEnv)
 	if dbFileName == "" {
@@ -1,76 +1,187 @@
 package main
 
 import (
-	"bufio"
+	"encoding/json"
 	"fmt"
 	mond "mond-api"
 	"net/http"
-	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
-	"strings"
+	"path/filepath"
+	"strconv"
 	"syscall"
 	"time"
 )
 
+const MondConfigFileEnv = "MOND_CONFIG_FILE"
 const MondStartCmdEnv = "MOND_START_CMD"
 const MondAppNameEnv = "MOND_APP_NAME"
+const MondBatchSizeEnv = "MOND_BATCH_SIZE"
+const MondBatchIntervalEnv = "MOND_BATCH_INTERVAL"
+const MondSpoolDirEnv = "MOND_SPOOL_DIR"
+const MondSpoolMaxBytesEnv = "MOND_SPOOL_MAX_BYTES"
+const MondMaxRestartsEnv = "MOND_MAX_RESTARTS"
+const MondRestartMinBackoffEnv = "MOND_RESTART_MIN_BACKOFF"
+const MondRestartMaxBackoffEnv = "MOND_RESTART_MAX_BACKOFF"
+const MondStopGracePeriodEnv = "MOND_STOP_GRACE_PERIOD"
+const MondInstanceIdEnv = "MOND_INSTANCE_ID"
 
-func checkEnv() (string, string, error) {
-	//os.Setenv(MondStartCmd, "ping 127.0.0.1") // TODO remove, used for testing only
-	startCmdWithArgs := os.Getenv(MondStartCmdEnv)
-	if startCmdWithArgs == "" {
-		return "", "", fmt.Errorf("no start command defined, set %s for current env", MondStartCmdEnv)
+// loadConfig reads the config file named by MOND_CONFIG_FILE, if set. MOND_START_CMD, MOND_APP_NAME
+// and the positional args override what it declares.
+func loadConfig() (mond.ClientConfig, error) {
+	var config mond.ClientConfig
+	var err error
+	if path := os.Getenv(MondConfigFileEnv); path != "" {
+		config, err = mond.LoadClientConfig(path)
+		if err != nil {
+			return config, err
+		}
 	}
-	scSplitted := strings.SplitN(startCmdWithArgs, " ", 2)
-	startCmd := scSplitted[0]
-	startArgs := ""
-	if len(scSplitted) > 1 {
-		startArgs = scSplitted[1]
+	if startCmd := os.Getenv(MondStartCmdEnv); startCmd != "" {
+		config.Command = startCmd
+		config.Args = nil
+	}
+	if appName := os.Getenv(MondAppNameEnv); appName != "" {
+		config.AppName = appName
+	}
+	if instanceId := os.Getenv(MondInstanceIdEnv); instanceId != "" {
+		config.InstanceId = instanceId
+	}
+	args := os.Args[1:]
+	if len(args) > 0 {
+		config.ServerUrl = args[0]
+	}
+	if len(args) > 1 {
+		config.HealthTargets = nil
+		for _, arg := range args[1:] {
+			config.HealthTargets = append(config.HealthTargets, mond.ParseHealthTarget(arg))
+		}
 	}
-	return startCmd, startArgs, nil
-}
 
-func getAppNameFromEnv() string {
-	appName := os.Getenv(MondAppNameEnv)
-	if appName == "" {
-		appName = "default"
+	if config.Command == "" {
+		return config, fmt.Errorf("no start command defined, set %s or command in %s", MondStartCmdEnv, MondConfigFileEnv)
+	}
+	if config.ServerUrl == "" {
+		return config, fmt.Errorf("no server url defined, pass it as first argument or set serverUrl in %s", MondConfigFileEnv)
+	}
+	if config.AppName == "" {
+		config.AppName = "default"
 		fmt.Println("WARN: using default app name!")
 	}
-	return appName
+	return config, nil
 }
 
-func checkArgs() (string, []string, error) {
-	args := os.Args[1:]
-	if len(args) < 2 {
-		return "", nil, fmt.Errorf("Too few arguments \n")
+// getShipperConfigFromEnv reads the batching and spooling settings, unset values use the defaults.
+func getShipperConfigFromEnv(appName string) (mond.ShipperConfig, error) {
+	config := mond.ShipperConfig{
+		SpoolDir: os.Getenv(MondSpoolDirEnv),
+	}
+	if config.SpoolDir == "" {
+		config.SpoolDir = filepath.Join(os.TempDir(), "mond-spool-"+appName)
+	}
+	if v := os.Getenv(MondBatchSizeEnv); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return config, fmt.Errorf("invalid %s %q", MondBatchSizeEnv, v)
+		}
+		config.BatchSize = size
+	}
+	if v := os.Getenv(MondBatchIntervalEnv); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return config, fmt.Errorf("invalid %s %q", MondBatchIntervalEnv, v)
+		}
+		config.BatchInterval = interval
+	}
+	if v := os.Getenv(MondSpoolMaxBytesEnv); v != "" {
+		maxBytes, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return config, fmt.Errorf("invalid %s %q", MondSpoolMaxBytesEnv, v)
+		}
+		config.MaxSpoolBytes = maxBytes
 	}
+	return config, nil
+}
 
-	reportUrl := args[0]
-	_, err := url.ParseRequestURI(reportUrl)
-	if err != nil {
-		return "", nil, fmt.Errorf("Invalid URL: %s \n", err.Error())
+// getSupervisorConfigFromEnv reads the restart settings, unset values use the defaults.
+func getSupervisorConfigFromEnv() (mond.SupervisorConfig, error) {
+	config := mond.SupervisorConfig{MaxRestarts: mond.DefaultMaxRestarts}
+	if v := os.Getenv(MondMaxRestartsEnv); v != "" {
+		restarts, err := strconv.Atoi(v)
+		if err != nil {
+			return config, fmt.Errorf("invalid %s %q", MondMaxRestartsEnv, v)
+		}
+		config.MaxRestarts = restarts
+	}
+	if v := os.Getenv(MondRestartMinBackoffEnv); v != "" {
+		backoff, err := time.ParseDuration(v)
+		if err != nil {
+			return config, fmt.Errorf("invalid %s %q", MondRestartMinBackoffEnv, v)
+		}
+		config.MinBackoff = backoff
+	}
+	if v := os.Getenv(MondRestartMaxBackoffEnv); v != "" {
+		backoff, err := time.ParseDuration(v)
+		if err != nil {
+			return config, fmt.Errorf("invalid %s %q", MondRestartMaxBackoffEnv, v)
+		}
+		config.MaxBackoff = backoff
 	}
-	websites := args[1:]
-	return reportUrl, websites, nil
+	if v := os.Getenv(MondStopGracePeriodEnv); v != "" {
+		grace, err := time.ParseDuration(v)
+		if err != nil {
+			return config, fmt.Errorf("invalid %s %q", MondStopGracePeriodEnv, v)
+		}
+		config.StopGracePeriod = grace
+	}
+	return config, nil
 }
 
 func main() {
-	appName := getAppNameFromEnv()
-
-	startCmd, startArgs, err := checkEnv()
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("ERROR: %v \n", err)
+		return
+	}
+	appName := config.AppName
+	websites := config.HealthTargets
+	reportUrl, err := config.ReportUrl()
+	if err != nil {
+		fmt.Printf("ERROR: %v \n", err)
+		return
+	}
+	newCommand, err := config.NewCommand()
 	if err != nil {
 		fmt.Printf("ERROR: %v \n", err)
 		return
 	}
 
-	reportUrl, websites, err := checkArgs()
+	shipperConfig, err := getShipperConfigFromEnv(appName)
+	if err != nil {
+		fmt.Printf("ERROR: %v \n", err)
+		return
+	}
+	supervisorConfig, err := getSupervisorConfigFromEnv()
 	if err != nil {
 		fmt.Printf("ERROR: %v \n", err)
 		return
 	}
-	fmt.Printf("Reporting to %s \n from %v \n", reportUrl, websites)
+	shipper, err := mond.NewLogShipper(reportUrl+mond.ApiAccessLogsPath+appName, mond.ReportAccessLogs, shipperConfig)
+	if err != nil {
+		fmt.Printf("ERROR: %v \n", err)
+		return
+	}
+	var grouper *mond.LineGrouper
+	var appLogShipper *mond.LogShipper
+	if config.AppLogs != nil {
+		grouper, appLogShipper, err = startAppLogs(config.AppLogs, reportUrl+mond.ApiAppLogsPath+appName, shipperConfig)
+		if err != nil {
+			fmt.Printf("ERROR: %v \n", err)
+			return
+		}
+	}
+	fmt.Printf("Reporting to %s \n from %v \n", config.ServerUrl, websites)
 
 	// check ReportUrl
 	err = mond.ReportRawLog(reportUrl, "Start Reporting")
@@ -78,84 +189,136 @@ func main() {
 		fmt.Printf("ERROR: Reporting: %v \n", err)
 	}
 
+	client := config.Source()
+	fmt.Printf("Tagging lines with host %s and instance %s \n", client.Host, client.Instance)
+	supervisor := newSupervisor(appName, reportUrl, client, shipper, grouper, config.AppLogs, supervisorConfig, newCommand)
+	go forwardSignals(supervisor)
+
 	// Start reporting health
-	go startReportingHealth(appName, reportUrl, websites)
+	quit := make(chan struct{})
+	go startReportingHealth(appName, reportUrl, websites, supervisor.Running, quit)
 
-	// Start command and watching Stdout
-	err = startCmdAndWatchStdout(appName, reportUrl, startCmd, startArgs)
-	if err != nil {
-		fmt.Printf("ERROR: %v \n", err)
+	// Start command and watching Stdout, restarting it when it crashes
+	exit := supervisor.Run()
+	if exit.Type == mond.ProcessCrashed {
+		fmt.Printf("ERROR: giving up, process %s \n", exit.Describe())
 	}
-}
 
-func startCmdAndWatchStdout(appName, reportUrl, command, args string) error {
-	reportLogsUrl := reportUrl + mond.ApiAccessLogsPath + appName
-	var argsArr []string
-	if strings.Contains(args, "'") {
-		argsArr = strings.SplitN(args, " ", 2)
-		argsArr[1] = strings.ReplaceAll(argsArr[1], "'", "")
-	} else {
-		argsArr = strings.Split(args, " ")
-	}
-	fmt.Printf("Start Command: %s\n ", command)
-	for i, c := range argsArr {
-		fmt.Printf("- Arg %d: %s\n ", i, c)
+	close(quit)
+	if grouper != nil {
+		grouper.Close()
+		appLogShipper.Close()
 	}
-	cmd := exec.Command(command, argsArr...)
-	out, err := cmd.StdoutPipe()
+	shipper.Close()
+	reportHealth(reportUrl+mond.ApiHealthPath+appName, mond.HealthCheck{Status: "DOWN", Timestamp: time.Now().Unix()})
+	os.Exit(exitCode(exit))
+}
+
+// startAppLogs groups the application logs into entries and ships them to url, spooling them apart
+// from the access logs.
+func startAppLogs(config *mond.AppLogsConfig, url string, shipperConfig mond.ShipperConfig) (*mond.LineGrouper, *mond.LogShipper, error) {
+	shipperConfig.SpoolDir += "-applogs"
+	shipper, err := mond.NewLogShipper(url, mond.ReportAppLogs, shipperConfig)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	errOut, err := cmd.StderrPipe()
+	grouper, err := mond.NewLineGrouper(config.Multiline, func(log mond.AppLog) {
+		line, err := json.Marshal(log)
+		if err != nil {
+			fmt.Printf("WARN: dropping app log, %v\n", err)
+			return
+		}
+		shipper.Add(string(line))
+	})
 	if err != nil {
-		return err
+		shipper.Close()
+		return nil, nil, err
 	}
+	return grouper, shipper, nil
+}
 
-	err = cmd.Start()
-	if err != nil {
-		return err
+// forwardSignals passes signals on to the process. Interrupts stop it, so mond-client ends with it.
+func forwardSignals(supervisor *mond.Supervisor) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, append([]os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT}, passedSignals...)...)
+	for sig := range c {
+		switch sig {
+		case os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT:
+			fmt.Printf("Stopping process on %v\n", sig)
+			supervisor.Stop(sig)
+		default:
+			supervisor.Signal(sig)
+		}
 	}
+}
 
-	scanner := bufio.NewScanner(out)
-	errScanner := bufio.NewScanner(errOut)
-	stop := make(chan bool)
-	go readStuff(reportLogsUrl, scanner, stop)
-	go readStuff(reportLogsUrl, errScanner, stop)
-	<-stop
-
-	err = cmd.Wait()
-	if err != nil {
-		return err
+// exitCode returns the exit code of the process, 1 if it never ran.
+func exitCode(exit mond.ProcessEvent) int {
+	if exit.ExitCode < 0 {
+		return 1
 	}
-	return nil
+	return exit.ExitCode
 }
 
-func startReportingHealth(appName, reportUrl string, websites []string) {
+// newSupervisor runs the command, shipping every line it writes tagged with its source and the host
+// and instance of client.
+func newSupervisor(appName, reportUrl string, client mond.LogSource, shipper *mond.LogShipper, grouper *mond.LineGrouper,
+	appLogs *mond.AppLogsConfig, config mond.SupervisorConfig, newCommand func() *exec.Cmd) *mond.Supervisor {
+	cmd := newCommand()
+	fmt.Printf("Start Command: %s\n ", cmd.Path)
+	for i, c := range cmd.Args[1:] {
+		fmt.Printf("- Arg %d: %s\n ", i, c)
+	}
+	output := func(source mond.LogSource, line string) {
+		fmt.Println(line)
+		source.Host, source.Instance = client.Host, client.Instance
+		if grouper != nil && appLogs.Includes(source.Stream) {
+			grouper.Add(source, line)
+			return
+		}
+		log, err := json.Marshal(mond.AccessLog{Raw: line, LogSource: source})
+		if err != nil {
+			fmt.Printf("WARN: dropping log line, %v\n", err)
+			return
+		}
+		shipper.Add(string(log))
+	}
+	reportEventsUrl := reportUrl + mond.ApiEventsPath + appName
 	reportHealthUrl := reportUrl + mond.ApiHealthPath + appName
-	c := make(chan os.Signal)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	events := func(event mond.ProcessEvent) {
+		fmt.Printf("Process %s \n", event.Describe())
+		_, err := mond.ReportProcessEvent(mond.Report, reportEventsUrl, event)
+		if err != nil {
+			fmt.Printf("problem reporting process event: %v\n", err)
+		}
+		if event.Type == mond.ProcessCrashed {
+			reportHealth(reportHealthUrl, mond.HealthCheck{Status: "DOWN", Timestamp: event.Timestamp})
+		}
+	}
+	return mond.NewSupervisor(newCommand, config, output, events)
+}
 
+// startReportingHealth reports the health of every target, or DOWN for the whole app while the
+// process is not running. Without targets a running process counts as UP.
+func startReportingHealth(appName, reportUrl string, targets []mond.HealthTarget, running func() bool, quit chan struct{}) {
+	reportHealthUrl := reportUrl + mond.ApiHealthPath + appName
 	ticker := time.NewTicker(60 * time.Second)
-	quit := make(chan struct{})
 	for {
 		select {
 		case <-ticker.C:
+			if !running() {
+				reportHealth(reportHealthUrl, mond.HealthCheck{Status: "DOWN", Timestamp: time.Now().Unix()})
+				continue
+			}
+			if len(targets) == 0 {
+				reportHealth(reportHealthUrl, mond.HealthCheck{Status: "UP", Timestamp: time.Now().Unix()})
+				continue
+			}
 			// do check
-			results := mond.CheckWebsites(mond.CheckWebsite, websites)
-			for _, v := range results {
-				status, err := mond.ReportHealthCheck(mond.Report, reportHealthUrl, v)
-				if err != nil {
-					fmt.Printf("problem reporting health: %v\n", err)
-				}
-				if status != http.StatusAccepted {
-					fmt.Printf("got status=%d want 202 \n", status)
-				} else {
-					// successfully reported TODO check if needed
-					//fmt.Printf("reported %s=%v \n", k, v)
-				}
+			for _, check := range mond.CheckTargets(targets) {
+				reportHealth(reportHealthUrl, check)
 			}
 		case <-quit:
-		case <-c:
 			ticker.Stop()
 			fmt.Println("Quit")
 			return
@@ -163,15 +326,12 @@ func startReportingHealth(appName, reportUrl string, websites []string) {
 	}
 }
 
-func readStuff(reportUrl string, scanner *bufio.Scanner, stop chan bool) {
-	for scanner.Scan() {
-		//fmt.Println("Performed Scan")
-		text := scanner.Text()
-		fmt.Println(text)
-		mond.ReportRawLog(reportUrl, text)
+func reportHealth(reportHealthUrl string, check mond.HealthCheck) {
+	status, err := mond.ReportHealthCheck(mond.Report, reportHealthUrl, check)
+	if err != nil {
+		fmt.Printf("problem reporting health: %v\n", err)
 	}
-	if err := scanner.Err(); err != nil {
-		fmt.Fprintln(os.Stderr, "error reading standard input:", err)
+	if status != http.StatusAccepted {
+		fmt.Printf("got status=%d want 202 \n", status)
 	}
-	stop <- true
 }
@@ -0,0 +1,12 @@
+//go:build windows || plan9 || js
+// +build windows plan9 js
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// passedSignals are passed on to the process as they are.
+var passedSignals = []os.Signal{syscall.SIGHUP}
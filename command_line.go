@@ -0,0 +1,145 @@
+package mond
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CommandLine is a command split into words the way a POSIX shell does. Leading VAR=value words
+// are environment assignments, the first of Args is the program.
+type CommandLine struct {
+	Env  []string
+	Args []string
+}
+
+// shellWord is a word being read, quoted is the position of its first quoted character.
+type shellWord struct {
+	text   strings.Builder
+	quoted int
+	ok     bool
+}
+
+// ParseCommandLine splits line like a POSIX shell without running one. Single quotes, double
+// quotes, backslash escapes and comments work as in sh. Expansions, redirections, pipes and
+// command lists are rejected instead of being passed on as arguments.
+func ParseCommandLine(line string) (CommandLine, error) {
+	var command CommandLine
+	words, quotedAt, err := splitShellWords(line)
+	if err != nil {
+		return command, err
+	}
+	for i, word := range words {
+		if len(command.Args) == 0 && isAssignment(word, quotedAt[i]) {
+			command.Env = append(command.Env, word)
+			continue
+		}
+		command.Args = append(command.Args, word)
+	}
+	if len(command.Args) == 0 {
+		return command, fmt.Errorf("no command in %q", line)
+	}
+	return command, nil
+}
+
+func splitShellWords(line string) ([]string, []int, error) {
+	var words []string
+	var quotedAt []int
+	word := &shellWord{quoted: -1}
+	finish := func() {
+		if word.ok {
+			words = append(words, word.text.String())
+			quotedAt = append(quotedAt, word.quoted)
+		}
+		word = &shellWord{quoted: -1}
+	}
+	markQuoted := func() {
+		word.ok = true
+		if word.quoted < 0 {
+			word.quoted = word.text.Len()
+		}
+	}
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			finish()
+		case c == '#' && !word.ok:
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case c == '\\':
+			i++
+			if i == len(runes) {
+				return nil, nil, fmt.Errorf("unfinished escape at the end of %q", line)
+			}
+			if runes[i] == '\n' {
+				continue
+			}
+			markQuoted()
+			word.text.WriteRune(runes[i])
+		case c == '\'':
+			markQuoted()
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, nil, fmt.Errorf("unterminated single quote in %q", line)
+			}
+			word.text.WriteString(string(runes[i+1 : end]))
+			i = end
+		case c == '"':
+			markQuoted()
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				switch runes[i] {
+				case '$', '`':
+					return nil, nil, fmt.Errorf("unsupported expansion %q in %q", runes[i], line)
+				case '\\':
+					if i+1 < len(runes) && strings.ContainsRune("$`\"\\\n", runes[i+1]) {
+						i++
+						if runes[i] == '\n' {
+							continue
+						}
+					}
+				}
+				word.text.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, nil, fmt.Errorf("unterminated double quote in %q", line)
+			}
+		case strings.ContainsRune("$`", c):
+			return nil, nil, fmt.Errorf("unsupported expansion %q in %q", c, line)
+		case strings.ContainsRune("|&;<>()", c):
+			return nil, nil, fmt.Errorf("unsupported shell operator %q in %q", c, line)
+		default:
+			word.ok = true
+			word.text.WriteRune(c)
+		}
+	}
+	finish()
+	return words, quotedAt, nil
+}
+
+func indexRune(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// isAssignment reports whether word is NAME=value with an unquoted, valid NAME.
+func isAssignment(word string, quotedAt int) bool {
+	eq := strings.IndexByte(word, '=')
+	if eq <= 0 || (quotedAt >= 0 && quotedAt <= eq) {
+		return false
+	}
+	for i, c := range word[:eq] {
+		letter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
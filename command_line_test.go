@@ -0,0 +1,60 @@
+package mond
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommandLine(t *testing.T) {
+	cases := []struct {
+		line string
+		want CommandLine
+	}{
+		{"ping 127.0.0.1", CommandLine{Args: []string{"ping", "127.0.0.1"}}},
+		{"  ping \t -c 3  ", CommandLine{Args: []string{"ping", "-c", "3"}}},
+		{`sh -c 'echo "hi there"'`, CommandLine{Args: []string{"sh", "-c", `echo "hi there"`}}},
+		{`echo "a 'b' \"c\" \d \$"`, CommandLine{Args: []string{"echo", `a 'b' "c" \d $`}}},
+		{`echo a\ b \'c`, CommandLine{Args: []string{"echo", "a b", "'c"}}},
+		{`echo "" ''`, CommandLine{Args: []string{"echo", "", ""}}},
+		{`echo pre"mid"'end'`, CommandLine{Args: []string{"echo", "premidend"}}},
+		{"echo a \\\n b", CommandLine{Args: []string{"echo", "a", "b"}}},
+		{"echo a # comment", CommandLine{Args: []string{"echo", "a"}}},
+		{"echo a#b", CommandLine{Args: []string{"echo", "a#b"}}},
+		{`PORT=8080 MODE="x y" ./app -v`, CommandLine{Env: []string{"PORT=8080", "MODE=x y"}, Args: []string{"./app", "-v"}}},
+		{`"PORT=8080" ./app`, CommandLine{Args: []string{"PORT=8080", "./app"}}},
+		{`./app PORT=8080`, CommandLine{Args: []string{"./app", "PORT=8080"}}},
+		{`1X=2 ./app`, CommandLine{Args: []string{"1X=2", "./app"}}},
+	}
+	for _, c := range cases {
+		t.Run(c.line, func(t *testing.T) {
+			got, err := ParseCommandLine(c.line)
+			assertNoError(t, err)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %#v want %#v", got, c.want)
+			}
+		})
+	}
+
+	invalid := []string{
+		"",
+		"A=1",
+		`echo 'open`,
+		`echo "open`,
+		`echo \`,
+		"echo $HOME",
+		`echo "$HOME"`,
+		"echo `date`",
+		"echo a | grep a",
+		"echo a > out",
+		"echo a; echo b",
+		"sleep 1 &",
+	}
+	for _, line := range invalid {
+		t.Run(line, func(t *testing.T) {
+			_, err := ParseCommandLine(line)
+			if err == nil {
+				t.Errorf("expected an error for %q", line)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package mond
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServerConfig holds the settings of the apiserver which do not fit into a single env variable.
+type ServerConfig struct {
+	Retention RetentionConfig `json:"retention"`
+	Staleness StalenessConfig `json:"staleness"`
+	Probing   ProbingConfig   `json:"probing"`
+	Alerting  AlertingConfig  `json:"alerting"`
+	Notify    NotifyConfig    `json:"notify"`
+	Logs      LogsConfig      `json:"logs"`
+}
+
+// LoadServerConfig reads a ServerConfig from the JSON file found at path.
+func LoadServerConfig(path string) (ServerConfig, error) {
+	var config ServerConfig
+	file, err := os.Open(path)
+	if err != nil {
+		return config, fmt.Errorf("problem opening config %s, %v", path, err)
+	}
+	defer file.Close()
+
+	dec := json.NewDecoder(file)
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&config)
+	if err != nil {
+		return config, fmt.Errorf("problem parsing config %s, %v", path, err)
+	}
+	config.Retention.lowerAppNames()
+	config.Staleness.lowerAppNames()
+	config.Probing.lowerAppNames()
+	err = config.Alerting.Validate()
+	if err != nil {
+		return config, fmt.Errorf("problem in config %s, %v", path, err)
+	}
+	return config, nil
+}
+
+// Duration is a time.Duration written as "90s", "12h" or "30d" in JSON.
+type Duration time.Duration
+
+func ParseDuration(s string) (Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return Duration(time.Duration(days) * 24 * time.Hour), nil
+	}
+	d, err := time.ParseDuration(s)
+	return Duration(d), err
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return fmt.Errorf("duration must be a string like \"12h\", got %s", b)
+	}
+	*d, err = ParseDuration(s)
+	return err
+}
@@ -0,0 +1,110 @@
+package mond
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadServerConfig(t *testing.T) {
+	t.Run("reads durations in days, hours and minutes", func(t *testing.T) {
+		file, clean := createTempFile(t, `{"retention":{"interval":"5m","default":{"maxAge":"30d"},"apps":{"appa":{"maxAge":"12h"}}}}`)
+		defer clean()
+
+		config, err := LoadServerConfig(file.Name())
+		assertNoError(t, err)
+
+		if config.Retention.Interval != Duration(5*time.Minute) {
+			t.Errorf("got interval %v", config.Retention.Interval)
+		}
+		if config.Retention.Default.MaxAge != Duration(30*24*time.Hour) {
+			t.Errorf("got default max age %v", config.Retention.Default.MaxAge)
+		}
+		if config.Retention.PolicyFor("appa").MaxAge != Duration(12*time.Hour) {
+			t.Errorf("got appa max age %v", config.Retention.PolicyFor("appa").MaxAge)
+		}
+	})
+
+	t.Run("keeps retention policies under lower case app names", func(t *testing.T) {
+		file, clean := createTempFile(t, `{"retention":{"apps":{"BusyApp":{"maxCount":10}}}}`)
+		defer clean()
+
+		config, err := LoadServerConfig(file.Name())
+		assertNoError(t, err)
+
+		if got := config.Retention.PolicyFor("busyapp").MaxCount; got != 10 {
+			t.Errorf("got max count %d want 10", got)
+		}
+	})
+
+	t.Run("keeps staleness intervals under lower case app names", func(t *testing.T) {
+		file, clean := createTempFile(t, `{"staleness":{"apps":{"NightlyJob":"25h"}}}`)
+		defer clean()
+
+		config, err := LoadServerConfig(file.Name())
+		assertNoError(t, err)
+
+		if got := config.Staleness.StaleAfter("nightlyjob"); got != Duration(25*time.Hour) {
+			t.Errorf("got %v want 25h", got)
+		}
+	})
+
+	t.Run("reads the targets to probe", func(t *testing.T) {
+		file, clean := createTempFile(t, `{"probing":{"interval":"2m","apps":{"site":["https://example.com",{"name":"db","url":"tcp://db:5432","interval":"30s"}]}}}`)
+		defer clean()
+
+		config, err := LoadServerConfig(file.Name())
+		assertNoError(t, err)
+
+		targets := config.Probing.Apps["site"]
+		if len(targets) != 2 || targets[0].Url != "https://example.com" || targets[1].Name != "db" {
+			t.Fatalf("got targets %+v", targets)
+		}
+		if got := config.Probing.IntervalOf(targets[0]); got != 2*time.Minute {
+			t.Errorf("got interval %v want 2m", got)
+		}
+		if got := config.Probing.IntervalOf(targets[1]); got != 30*time.Second {
+			t.Errorf("got interval %v want 30s", got)
+		}
+	})
+
+	t.Run("probes apps under their lower case name", func(t *testing.T) {
+		file, clean := createTempFile(t, `{"probing":{"apps":{"Website":["https://example.com"],"website":["tcp://example.com:22"]}}}`)
+		defer clean()
+
+		config, err := LoadServerConfig(file.Name())
+		assertNoError(t, err)
+
+		if len(config.Probing.Apps) != 1 || len(config.Probing.Apps["website"]) != 2 {
+			t.Errorf("got apps %+v want both targets under website", config.Probing.Apps)
+		}
+	})
+
+	t.Run("reads the log formats", func(t *testing.T) {
+		file, clean := createTempFile(t, `{"logs":{"default":"combined","trustedProxies":["10.0.0.0/8","::1"],"apps":{"api":"logfmt","caddy":{"format":"json","fields":{"ip":"request.remote_ip"}}}}}`)
+		defer clean()
+
+		config, err := LoadServerConfig(file.Name())
+		assertNoError(t, err)
+
+		if config.Logs.Default.Format != LogFormatCombined {
+			t.Errorf("got default format %q", config.Logs.Default.Format)
+		}
+		if config.Logs.Apps["api"].Format != LogFormatLogfmt {
+			t.Errorf("got api format %q", config.Logs.Apps["api"].Format)
+		}
+		assertStringArray(t, config.Logs.TrustedProxies, []string{"10.0.0.0/8", "::1"})
+		if caddy := config.Logs.Apps["caddy"]; caddy.Format != LogFormatJson || caddy.Fields["ip"] != "request.remote_ip" {
+			t.Errorf("got caddy format %+v", caddy)
+		}
+	})
+
+	t.Run("rejects unknown settings", func(t *testing.T) {
+		file, clean := createTempFile(t, `{"retenion":{}}`)
+		defer clean()
+
+		_, err := LoadServerConfig(file.Name())
+		if err == nil {
+			t.Error("expected an error for a misspelled setting")
+		}
+	})
+}
@@ -4,33 +4,59 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sync"
+	"time"
 )
 
+// FileSystemAppsStore keeps all apps in a single JSON file which is rewritten on every change.
+// It is safe for concurrent use, reads return snapshots which are not affected by later writes.
 type FileSystemAppsStore struct {
+	mu       sync.RWMutex
 	database *json.Encoder
+	tape     *tape
 	apps     Apps
 }
 
 // NewFileSystemAppsStore creates a FileSystemAppsStore initialising the store if needed.
+// A corrupt database is moved aside and replaced by everything which could be salvaged from it.
 func NewFileSystemAppsStore(file *os.File) (*FileSystemAppsStore, error) {
+	removeStaleTapeFiles(file.Name())
 	err := initialiseAppsDBFile(file)
 	if err != nil {
 		return nil, fmt.Errorf("problem initialising apps db file, %v", err)
 	}
 
+	t := &tape{path: file.Name(), sync: true}
 	apps, err := NewApps(file)
 	if err != nil {
-		return nil, fmt.Errorf("problem loading apps store from file %s, %v", file.Name(), err)
+		apps, err = recoverAppsDBFile(file, t, err)
+		if err != nil {
+			return nil, fmt.Errorf("problem loading apps store from file %s, %v", file.Name(), err)
+		}
+	}
+	upgraded := false
+	for i := range apps {
+		if apps[i].upgradeLogs() {
+			upgraded = true
+		}
+	}
+	if upgraded {
+		err = json.NewEncoder(t).Encode(apps)
+		if err != nil {
+			return nil, fmt.Errorf("problem writing upgraded logs to %s, %v", file.Name(), err)
+		}
 	}
 
 	return &FileSystemAppsStore{
-		database: json.NewEncoder(&tape{file}),
+		database: json.NewEncoder(t),
+		tape:     t,
 		apps:     apps,
 	}, nil
 }
 
 // FileSystemAppsStoreFromFile creates a FileSystemAppsStore from the contents of a JSON file found at path.
-func FileSystemAppsStoreFromFile(path string) (*FileSystemAppsStore, func(), error) {
+// Unless syncWrites is set, writes are not flushed to disk before they are acknowledged.
+func FileSystemAppsStoreFromFile(path string, syncWrites bool) (*FileSystemAppsStore, func(), error) {
 	db, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
 
 	if err != nil {
@@ -47,6 +73,7 @@ func FileSystemAppsStoreFromFile(path string) (*FileSystemAppsStore, func(), err
 		db.Close()
 		return nil, nil, fmt.Errorf("problem creating file system player store, %v ", err)
 	}
+	store.tape.sync = syncWrites
 
 	return store, closeFunc, nil
 }
@@ -66,7 +93,35 @@ func initialiseAppsDBFile(file *os.File) error {
 	return nil
 }
 
+// recoverAppsDBFile salvages the complete records of a damaged apps db file, moves the damaged
+// file aside and writes the salvaged apps in its place.
+func recoverAppsDBFile(file *os.File, t *tape, cause error) (Apps, error) {
+	_, err := file.Seek(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("problem rewinding damaged file, %v", err)
+	}
+	apps := salvageApps(file)
+	if apps == nil {
+		apps = Apps{}
+	}
+
+	damaged := fmt.Sprintf("%s.corrupt-%d", file.Name(), time.Now().Unix())
+	err = os.Rename(file.Name(), damaged)
+	if err != nil {
+		return nil, fmt.Errorf("problem moving damaged file aside, %v (%v)", err, cause)
+	}
+	err = json.NewEncoder(t).Encode(apps)
+	if err != nil {
+		return nil, fmt.Errorf("problem writing salvaged apps, %v", err)
+	}
+
+	fmt.Printf("WARN: %v, salvaged %d apps and moved the damaged file to %s\n", cause, len(apps), damaged)
+	return apps, nil
+}
+
 func (f *FileSystemAppsStore) GetAppNames() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	var apps []string
 	for _, v := range f.apps {
 		apps = append(apps, v.Name)
@@ -75,55 +130,117 @@ func (f *FileSystemAppsStore) GetAppNames() []string {
 }
 
 func (f *FileSystemAppsStore) GetApps() Apps {
-	return f.apps
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.apps.snapshot()
 }
 
 func (f *FileSystemAppsStore) GetApp(name string) *App {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	app := f.apps.Find(name)
 	if app != nil {
-		return app
+		snapshot := app.snapshot()
+		return &snapshot
 	}
 	return nil
 }
 
 func (f *FileSystemAppsStore) GetAccessLogs(name string) AccessLogs {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	app := f.apps.Find(name)
 	if app != nil {
-		return app.Logs
+		return app.snapshot().Logs
 	}
 	return AccessLogs{}
 }
 
 func (f *FileSystemAppsStore) RecordAccessLog(name string, log AccessLog) {
+	f.RecordAccessLogs(name, AccessLogs{log})
+}
+
+// RecordAccessLogs appends all logs with a single write of the database.
+func (f *FileSystemAppsStore) RecordAccessLogs(name string, logs AccessLogs) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	logs = currentLogs(logs)
 	app := f.apps.Find(name)
 	if app != nil {
-		app.Logs = append(app.Logs, log)
+		app.Logs = append(app.Logs, logs...)
 	} else {
 		f.apps = append(f.apps, App{
 			Name: name,
-			Logs: AccessLogs{log},
+			Logs: logs,
 		})
 	}
 	f.database.Encode(f.apps)
 }
 
+func (f *FileSystemAppsStore) PruneAccessLogs(name string, count int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	app := f.apps.Find(name)
+	if app == nil || count <= 0 {
+		return
+	}
+	app.pruneLogs(count)
+	f.database.Encode(f.apps)
+}
+
+func (f *FileSystemAppsStore) RecordProcessEvent(name string, event ProcessEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	app := f.apps.Find(name)
+	if app == nil {
+		f.apps = append(f.apps, App{Name: name})
+		app = &f.apps[len(f.apps)-1]
+	}
+	app.addEvent(event)
+	f.database.Encode(f.apps)
+}
+
+func (f *FileSystemAppsStore) RecordAppLogs(name string, logs []AppLog) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	app := f.apps.Find(name)
+	if app == nil {
+		f.apps = append(f.apps, App{Name: name})
+		app = &f.apps[len(f.apps)-1]
+	}
+	app.addAppLogs(logs...)
+	f.database.Encode(f.apps)
+}
+
 func (f *FileSystemAppsStore) GetHealth(name string) HealthCheck {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	app := f.apps.Find(name)
 	if app != nil {
-		return app.Health
+		return app.CurrentHealth()
 	}
 	return UNHEALTHY
 }
 
 func (f *FileSystemAppsStore) RecordHealth(name string, check HealthCheck) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	app := f.apps.Find(name)
-	if app != nil {
-		app.Health = check
-	} else {
-		f.apps = append(f.apps, App{
-			Name:   name,
-			Health: check,
-		})
+	if app == nil {
+		f.apps = append(f.apps, App{Name: name})
+		app = &f.apps[len(f.apps)-1]
+	}
+	app.recordHealth(check, time.Now().Unix())
+	f.database.Encode(f.apps)
+}
+
+func (f *FileSystemAppsStore) MarkHealthStale(name string, at int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	app := f.apps.Find(name)
+	if app == nil || app.Stale {
+		return
 	}
+	app.markStale(at)
 	f.database.Encode(f.apps)
 }
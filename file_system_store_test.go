@@ -3,7 +3,9 @@ package mond
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -52,12 +54,57 @@ func TestFileSystemStore(t *testing.T) {
 
 		gotLogs := store.GetAccessLogs("App1")
 		wantLogs := AccessLogs{
-			{Raw: "Test1"},
-			{Raw: "Test2"},
+			{Raw: "Test1", Version: AccessLogVersion},
+			{Raw: "Test2", Version: AccessLogVersion},
 		}
 		assertAccessLogsEquals(t, gotLogs, wantLogs)
 	})
 
+	t.Run("fills the fields of logs recorded before they were versioned", func(t *testing.T) {
+		raw := `10.0.0.1 - - [02/Jul/2021:22:50:59 +0200] \"GET /a HTTP/1.1\" 200 7280 \"https://example.com/\" \"curl/7.68.0\"`
+		database, cleanDatabase := createTempFile(t, `[{"app":"App1","logs":[
+			{"timestamp":1625259059,"ip":"10.0.0.1","path":"/a","remoteIp":"","status":"200","raw":"`+raw+`"},
+			{"timestamp":0,"ip":"","path":"","remoteIp":"","status":"","raw":"GET /b HTTP/1.1","method":"PUT","version":2}
+		]}]`)
+		defer cleanDatabase()
+
+		store, err := NewFileSystemAppsStore(database)
+		assertNoError(t, err)
+
+		got := store.GetAccessLogs("App1")
+		assertAccessLogEquals(t, got[0], AccessLog{Ip: "10.0.0.1", Timestamp: 1625259059, Method: "GET", Path: "/a", Protocol: "HTTP/1.1", Status: "200",
+			Bytes: 7280, Referrer: "https://example.com/", UserAgent: "curl/7.68.0", Raw: strings.Replace(raw, `\"`, `"`, -1)})
+		if got[1].Method != "PUT" || got[1].Protocol != "" {
+			t.Errorf("expected a current log to stay as it was, got %+v", got[1])
+		}
+
+		reopened, _ := os.Open(database.Name())
+		defer reopened.Close()
+		apps, err := NewApps(reopened)
+		assertNoError(t, err)
+		if written := apps[0].Logs[0]; written.Version != AccessLogVersion || written.Method != "GET" {
+			t.Errorf("expected the upgraded log to be written back, got %+v", written)
+		}
+	})
+
+	t.Run("does not rewrite the database for logs it recorded itself", func(t *testing.T) {
+		database, cleanDatabase := createTempFile(t, `[]`)
+		defer cleanDatabase()
+		store, err := NewFileSystemAppsStore(database)
+		assertNoError(t, err)
+		store.RecordAccessLog(MondAppName, AccessLog{Raw: "https://example.com/dashboard/"})
+		recorded, _ := os.Stat(database.Name())
+
+		reopened, _ := os.Open(database.Name())
+		defer reopened.Close()
+		_, err = NewFileSystemAppsStore(reopened)
+		assertNoError(t, err)
+
+		if loaded, _ := os.Stat(database.Name()); !os.SameFile(recorded, loaded) {
+			t.Error("expected the database not to be rewritten on load")
+		}
+	})
+
 	t.Run("store log for existing apps", func(t *testing.T) {
 		database, cleanDatabase := createTempFile(t, `[
 			{"app":"App1","logs":[
@@ -74,9 +121,9 @@ func TestFileSystemStore(t *testing.T) {
 
 		got := store.GetAccessLogs("App1")
 		want := AccessLogs{
-			{Raw: "Test1"},
-			{Raw: "Test2"},
-			{Raw: "Test3"},
+			{Raw: "Test1", Version: AccessLogVersion},
+			{Raw: "Test2", Version: AccessLogVersion},
+			{Raw: "Test3", Version: AccessLogVersion},
 		}
 		assertAccessLogsEquals(t, got, want)
 	})
@@ -92,7 +139,7 @@ func TestFileSystemStore(t *testing.T) {
 
 		got := store.GetAccessLogs("App1")
 		want := AccessLogs{
-			{Raw: "Test"},
+			{Raw: "Test", Version: AccessLogVersion},
 		}
 		assertAccessLogsEquals(t, got, want)
 	})
@@ -105,6 +152,38 @@ func TestFileSystemStore(t *testing.T) {
 
 		assertNoError(t, err)
 	})
+
+	t.Run("recovers complete records from a half written file", func(t *testing.T) {
+		database, cleanDatabase := createTempFile(t, `[
+			{"app":"App1","health":{"status":"UP","timestamp":1},"logs":[
+				{"timestamp":0,"ip":"","path":"","remoteIp":"","status":"","raw":"Test1"}
+			]},
+			{"app":"App2","logs":[
+				{"timestamp":0,"ip":"","path":"","remoteIp":"","status":"","raw":"Test2"},
+				{"timestamp":0,"ip":"","path":"","remoteIp":"","sta`)
+		defer cleanDatabase()
+
+		store, err := NewFileSystemAppsStore(database)
+		assertNoError(t, err)
+
+		damaged, _ := filepath.Glob(database.Name() + ".corrupt-*")
+		if len(damaged) != 1 {
+			t.Fatalf("expected damaged file to be moved aside, got %v", damaged)
+		}
+		defer os.Remove(damaged[0])
+
+		assertAppNamesEquals(t, store.GetAppNames(), []string{"App1", "App2"})
+		assertHealthEquals(t, store.GetHealth("App1"), HEALTHY)
+		assertAccessLogsEquals(t, store.GetAccessLogs("App2"), AccessLogs{{Raw: "Test2", Version: AccessLogVersion}})
+
+		reopened, _ := os.Open(database.Name())
+		defer reopened.Close()
+		apps, err := NewApps(reopened)
+		assertNoError(t, err)
+		if len(apps) != 2 {
+			t.Errorf("expected salvaged apps to be written back, got %v", apps)
+		}
+	})
 }
 
 func assertAppNamesEquals(t testing.TB, got, want []string) {
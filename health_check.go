@@ -17,9 +17,24 @@ var HEALTHY = HealthCheck{
 	Timestamp: 1,
 }
 
+// Derived health states of an app with several targets.
+const (
+	HealthDegraded = "DEGRADED"
+)
+
+// HealthCheck is the health of an app, or of one of its targets if Target is set.
 type HealthCheck struct {
 	Status string `json:"status"`
 	Timestamp int64 `json:"timestamp"`
+	Target string `json:"target,omitempty"`
+	Url string `json:"url,omitempty"`
+	// Latency of the check in milliseconds, Reason why it failed or a warning, Slow if it passed slowly.
+	Latency int64 `json:"latency,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Slow bool `json:"slow,omitempty"`
+	// ExitCode and Output of the command of command checks.
+	ExitCode *int `json:"exitCode,omitempty"`
+	Output string `json:"output,omitempty"`
 }
 
 func NewHealthCheck(rdr io.Reader) (*HealthCheck, error) {
@@ -38,8 +53,11 @@ func (h *HealthCheck) GetFormattedTime() string {
 }
 
 func (h *HealthCheck) GetCardClass() string {
-	if h.Status == "UP" {
+	if h.Status == "UP" && !h.Slow && h.Reason == "" {
 		return "bg-success"
 	}
+	if h.Status == HealthDegraded || h.Status == "UP" {
+		return "bg-warning"
+	}
 	return "bg-danger"
 }
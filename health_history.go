@@ -0,0 +1,182 @@
+package mond
+
+import (
+	"fmt"
+	"time"
+)
+
+// HealthHistoryMaxAge is how far back the health history of an app reaches.
+const HealthHistoryMaxAge = 30 * 24 * time.Hour
+
+// Windows the dashboard shows the uptime for.
+var UptimeWindows = []UptimeWindow{
+	{Name: "24h", Length: 24 * time.Hour},
+	{Name: "7d", Length: 7 * 24 * time.Hour},
+	{Name: "30d", Length: 30 * 24 * time.Hour},
+}
+
+type UptimeWindow struct {
+	Name   string
+	Length time.Duration
+}
+
+// HealthRecord is a run of health reports with the same status, received from From until To.
+// A new record starts with every status transition, so the history is the series of all
+// reports without storing each of them.
+type HealthRecord struct {
+	Status      string `json:"status"`
+	From        int64  `json:"from"`
+	To          int64  `json:"to"`
+	Count       int    `json:"count"`
+	Maintenance bool   `json:"maintenance,omitempty"`
+}
+
+// Uptime sums up the health history of an app within a window. Percent is -1 if nothing is known
+// about the window. MTTR is the mean time from going down to being up again, 0 without outages.
+// Maintenance is the recorded time left out of the uptime.
+type Uptime struct {
+	Window      string   `json:"window"`
+	Percent     float64  `json:"percent"`
+	Outages     int      `json:"outages"`
+	MTTR        Duration `json:"mttr"`
+	Recorded    Duration `json:"recorded"`
+	Maintenance Duration `json:"maintenance,omitempty"`
+}
+
+// addHealthRecord appends a report received at the given time to the history and drops the
+// records older than HealthHistoryMaxAge.
+func (a *App) addHealthRecord(status string, received int64) {
+	last := len(a.HealthHistory) - 1
+	if last >= 0 && a.HealthHistory[last].Status == status && received >= a.HealthHistory[last].To {
+		// the history is shared with snapshots, so the record is replaced instead of changed
+		record := a.HealthHistory[last]
+		record.To = received
+		record.Count++
+		a.HealthHistory = append(a.HealthHistory[:last:last], record)
+	} else {
+		a.HealthHistory = append(a.HealthHistory, HealthRecord{Status: status, From: received, To: received, Count: 1})
+	}
+
+	cutoff := received - int64(HealthHistoryMaxAge/time.Second)
+	n := 0
+	for n < len(a.HealthHistory)-1 && a.HealthHistory[n+1].From <= cutoff {
+		n++
+	}
+	a.HealthHistory = a.HealthHistory[n:]
+}
+
+// HealthHistoryBetween returns the records overlapping the time range, zero bounds are open.
+func (a *App) HealthHistoryBetween(from, to int64) []HealthRecord {
+	records := []HealthRecord{}
+	for i, r := range a.HealthHistory {
+		until := r.To
+		if i+1 < len(a.HealthHistory) {
+			until = a.HealthHistory[i+1].From
+		}
+		if (from == 0 || until >= from) && (to == 0 || r.From <= to) {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// MarkMaintenance flags the records with reports received within one of the maintenance periods.
+func MarkMaintenance(records []HealthRecord, maintenance []Period) []HealthRecord {
+	marked := make([]HealthRecord, len(records))
+	for i, r := range records {
+		for _, p := range maintenance {
+			if p.From <= r.To && r.From <= p.To {
+				r.Maintenance = true
+			}
+		}
+		marked[i] = r
+	}
+	return marked
+}
+
+// UptimeIn computes the uptime over the window ending at now. A status lasts until the next
+// record starts, the last one until now. Time before the first record is not counted. Outages
+// count towards the MTTR if they ended within the window.
+func (a *App) UptimeIn(window UptimeWindow, now time.Time) Uptime {
+	return a.UptimeExcluding(window, now, nil)
+}
+
+// UptimeExcluding computes the uptime like UptimeIn but leaves out the sorted, merged maintenance
+// periods. Outages which started within maintenance are not counted.
+func (a *App) UptimeExcluding(window UptimeWindow, now time.Time, maintenance []Period) Uptime {
+	uptime := Uptime{Window: window.Name, Percent: -1}
+	start := now.Add(-window.Length).Unix()
+	end := now.Unix()
+
+	var up, recorded, downtime, excluded int64
+	downSince := int64(-1)
+	for i, r := range a.HealthHistory {
+		from, until := r.From, end
+		if i+1 < len(a.HealthHistory) {
+			until = a.HealthHistory[i+1].From
+		}
+		if r.Status != "UP" && downSince < 0 {
+			downSince = r.From
+		}
+		if r.Status == "UP" && downSince >= 0 {
+			if r.From >= start && !inPeriods(downSince, maintenance) {
+				uptime.Outages++
+				downtime += r.From - downSince
+			}
+			downSince = -1
+		}
+
+		if from < start {
+			from = start
+		}
+		if until > end {
+			until = end
+		}
+		if until <= from {
+			continue
+		}
+		skipped := overlap(from, until, maintenance)
+		excluded += skipped
+		recorded += until - from - skipped
+		if r.Status == "UP" {
+			up += until - from - skipped
+		}
+	}
+
+	uptime.Recorded = Duration(time.Duration(recorded) * time.Second)
+	uptime.Maintenance = Duration(time.Duration(excluded) * time.Second)
+	if recorded > 0 {
+		uptime.Percent = float64(up) * 100 / float64(recorded)
+	}
+	if uptime.Outages > 0 {
+		uptime.MTTR = Duration(time.Duration(downtime/int64(uptime.Outages)) * time.Second)
+	}
+	return uptime
+}
+
+// Uptimes returns the uptime for each of the UptimeWindows.
+func (a *App) Uptimes(now time.Time) []Uptime {
+	return a.UptimesExcluding(now, nil)
+}
+
+// UptimesExcluding returns the uptime for each of the UptimeWindows without the maintenance periods.
+func (a *App) UptimesExcluding(now time.Time, maintenance []Period) []Uptime {
+	var uptimes []Uptime
+	for _, w := range UptimeWindows {
+		uptimes = append(uptimes, a.UptimeExcluding(w, now, maintenance))
+	}
+	return uptimes
+}
+
+// GetUptimes is used by the dashboard.
+func (a *App) GetUptimes() []Uptime {
+	return a.Uptimes(time.Now())
+}
+
+// GetPercentFormatted returns the uptime like "99.95%" or "n/a".
+func (u Uptime) GetPercentFormatted() string {
+	if u.Percent < 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.2f%%", u.Percent)
+}
@@ -0,0 +1,145 @@
+package mond
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHealthHistory(t *testing.T) {
+	t.Run("keeps runs of reports and starts a record with every transition", func(t *testing.T) {
+		app := App{Name: "app"}
+		app.recordHealth(HEALTHY, 10)
+		app.recordHealth(HEALTHY, 20)
+		app.recordHealth(UNHEALTHY, 30)
+		app.markStale(100)
+		app.recordHealth(HEALTHY, 110)
+
+		want := []HealthRecord{
+			{Status: "UP", From: 10, To: 20, Count: 2},
+			{Status: "DOWN", From: 30, To: 30, Count: 1},
+			{Status: HealthStale, From: 100, To: 100, Count: 1},
+			{Status: "UP", From: 110, To: 110, Count: 1},
+		}
+		if !reflect.DeepEqual(app.HealthHistory, want) {
+			t.Errorf("got %v want %v", app.HealthHistory, want)
+		}
+		if got := app.HealthHistoryBetween(40, 105); !reflect.DeepEqual(got, want[1:3]) {
+			t.Errorf("got %v want %v", got, want[1:3])
+		}
+	})
+
+	t.Run("forgets records older than the max age", func(t *testing.T) {
+		app := App{Name: "app"}
+		app.recordHealth(UNHEALTHY, 0)
+		app.recordHealth(HEALTHY, 10)
+		app.recordHealth(HEALTHY, int64(HealthHistoryMaxAge/time.Second)+20)
+
+		if len(app.HealthHistory) != 1 || app.HealthHistory[0].Status != "UP" {
+			t.Errorf("got %v want only the UP record", app.HealthHistory)
+		}
+	})
+
+	t.Run("does not change snapshots taken before", func(t *testing.T) {
+		app := App{Name: "app"}
+		app.recordHealth(HEALTHY, 10)
+		snapshot := app.snapshot()
+		app.recordHealth(HEALTHY, 20)
+
+		if snapshot.HealthHistory[0].To != 10 {
+			t.Errorf("got snapshot %v want it unchanged", snapshot.HealthHistory)
+		}
+	})
+}
+
+func TestUptime(t *testing.T) {
+	now := time.Unix(100000, 0)
+	app := App{Name: "app", HealthHistory: []HealthRecord{
+		{Status: "UP", From: 0, To: 90000},
+		{Status: "DOWN", From: 96000, To: 96500},
+		{Status: "UP", From: 97000, To: 98000},
+		{Status: HealthStale, From: 98000, To: 98000},
+		{Status: "UP", From: 99000, To: 99000},
+	}}
+
+	t.Run("computes uptime and time to recovery within the window", func(t *testing.T) {
+		got := app.UptimeIn(UptimeWindow{Name: "1h", Length: time.Hour}, now)
+
+		// 96400..97000 down, 97000..98000 up, 98000..99000 stale, 99000..100000 up
+		want := Uptime{Window: "1h", Percent: 2000 * 100 / 3600.0, Outages: 2, MTTR: Duration(1000 * time.Second), Recorded: Duration(time.Hour)}
+		if got != want {
+			t.Errorf("got %+v want %+v", got, want)
+		}
+	})
+
+	t.Run("does not count the time before the first record", func(t *testing.T) {
+		got := app.UptimeIn(UptimeWindow{Name: "30d", Length: 30 * 24 * time.Hour}, now)
+
+		if got.Recorded != Duration(100000*time.Second) || got.Outages != 2 {
+			t.Errorf("got %+v want 100000s recorded and 2 outages", got)
+		}
+	})
+
+	t.Run("leaves maintenance and the outages it started out", func(t *testing.T) {
+		got := app.UptimeExcluding(UptimeWindow{Name: "1h", Length: time.Hour}, now, []Period{{From: 95000, To: 97000}})
+
+		// 96400..97000 down in maintenance, 97000..98000 up, 98000..99000 stale, 99000..100000 up
+		want := Uptime{Window: "1h", Percent: 2000 * 100 / 3000.0, Outages: 1, MTTR: Duration(1000 * time.Second), Recorded: Duration(3000 * time.Second), Maintenance: Duration(600 * time.Second)}
+		if got != want {
+			t.Errorf("got %+v want %+v", got, want)
+		}
+	})
+
+	t.Run("reports an unknown uptime without history", func(t *testing.T) {
+		empty := App{Name: "empty"}
+		if got := empty.UptimeIn(UptimeWindows[0], now); got.Percent != -1 || got.GetPercentFormatted() != "n/a" {
+			t.Errorf("got %+v want an unknown uptime", got)
+		}
+	})
+}
+
+func TestMarkMaintenance(t *testing.T) {
+	records := []HealthRecord{{Status: "UP", From: 10, To: 20}, {Status: "DOWN", From: 30, To: 40}, {Status: "UP", From: 50, To: 60}}
+
+	got := MarkMaintenance(records, []Period{{From: 25, To: 30}})
+
+	if got[0].Maintenance || !got[1].Maintenance || got[2].Maintenance || records[1].Maintenance {
+		t.Errorf("got %v want only the DOWN record marked on a copy", got)
+	}
+}
+
+func TestGETHealthHistory(t *testing.T) {
+	store := &StubLogStore{AppAccessLogs: Apps{{Name: "appa", HealthHistory: []HealthRecord{
+		{Status: "UP", From: 10, To: 20, Count: 2},
+		{Status: "DOWN", From: 30, To: 40, Count: 2},
+	}}}}
+	server := NewApiServer(store, testInfo)
+	silences, _ := LoadSilences("")
+	silences.Add(Silence{App: "appa", Start: 35, End: 38}, time.Unix(40, 0))
+	server.SetSilences(silences)
+
+	request, _ := http.NewRequest(http.MethodGet, ApiHealthPath+"appa"+ApiHistorySuffix+"?from=35", nil)
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	assertStatus(t, response.Code, http.StatusOK)
+	var records []HealthRecord
+	json.NewDecoder(response.Body).Decode(&records)
+	if len(records) != 1 || records[0].Status != "DOWN" || !records[0].Maintenance {
+		t.Errorf("got %v want the DOWN record in maintenance", records)
+	}
+
+	request, _ = http.NewRequest(http.MethodGet, ApiHealthPath+"appa"+ApiUptimeSuffix, nil)
+	response = httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	assertStatus(t, response.Code, http.StatusOK)
+	var uptimes []Uptime
+	json.NewDecoder(response.Body).Decode(&uptimes)
+	if len(uptimes) != len(UptimeWindows) {
+		t.Errorf("got %v want one uptime per window", uptimes)
+	}
+}
@@ -0,0 +1,105 @@
+package mond
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// HealthTarget is something checked for an app, reported under its Name. Type picks the Checker,
+// it defaults to the scheme of Url if a Checker is registered for it and to http otherwise. A
+// target answering slower than SlowAfter is still UP but flagged as slow. Interval is only used
+// when the apiserver probes the target, mond-client checks all targets with each report.
+type HealthTarget struct {
+	Name      string   `json:"name"`
+	Url       string   `json:"url"`
+	Type      string   `json:"type,omitempty"`
+	Timeout   Duration `json:"timeout,omitempty"`
+	SlowAfter Duration `json:"slowAfter,omitempty"`
+	Interval  Duration `json:"interval,omitempty"`
+	HttpCheck
+	DnsCheck
+	TlsCheck
+	CommandCheck
+}
+
+// ParseHealthTarget reads a target written as "name=url" or just the url, which then is its name.
+func ParseHealthTarget(s string) HealthTarget {
+	if i := strings.Index(s, "="); i > 0 && !strings.ContainsAny(s[:i], ":/?") {
+		return HealthTarget{Name: s[:i], Url: s[i+1:]}
+	}
+	return HealthTarget{Name: s, Url: s}
+}
+
+// UnmarshalJSON accepts a target object as well as a string read by ParseHealthTarget.
+func (t *HealthTarget) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = ParseHealthTarget(s)
+		return nil
+	}
+	type plain HealthTarget
+	var target plain
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&target); err != nil {
+		return err
+	}
+	*t = HealthTarget(target)
+	t.Name = t.reportedName()
+	return nil
+}
+
+// reportedName returns the name the health of the target is reported under. Without a name it is
+// the url, or the command for targets which have none, as a report without target stands for the
+// whole app.
+func (t HealthTarget) reportedName() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	if t.Url != "" {
+		return t.Url
+	}
+	return t.Command
+}
+
+// deriveHealth sums up the targets: UP if all of them are up, DOWN if none is, DEGRADED otherwise.
+// The timestamp is the one of the latest report.
+func deriveHealth(targets []HealthCheck) HealthCheck {
+	var overall HealthCheck
+	up := 0
+	for _, t := range targets {
+		if t.Status == "UP" {
+			up++
+		}
+		if t.Timestamp > overall.Timestamp {
+			overall.Timestamp = t.Timestamp
+		}
+	}
+	switch up {
+	case len(targets):
+		overall.Status = "UP"
+	case 0:
+		overall.Status = "DOWN"
+	default:
+		overall.Status = HealthDegraded
+	}
+	return overall
+}
+
+// withTarget returns a copy of targets with the state of check's target replaced or added.
+func withTarget(targets []HealthCheck, check HealthCheck) []HealthCheck {
+	updated := make([]HealthCheck, 0, len(targets)+1)
+	found := false
+	for _, t := range targets {
+		if t.Target == check.Target {
+			t = check
+			found = true
+		}
+		updated = append(updated, t)
+	}
+	if !found {
+		updated = append(updated, check)
+	}
+	return updated
+}
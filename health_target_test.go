@@ -0,0 +1,92 @@
+package mond
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHealthTarget(t *testing.T) {
+	cases := map[string]HealthTarget{
+		"http://localhost:8080":          {Name: "http://localhost:8080", Url: "http://localhost:8080"},
+		"api=http://localhost:8080":      {Name: "api", Url: "http://localhost:8080"},
+		"http://localhost:8080/?a=b":     {Name: "http://localhost:8080/?a=b", Url: "http://localhost:8080/?a=b"},
+		"search=http://localhost/?q=mon": {Name: "search", Url: "http://localhost/?q=mon"},
+	}
+	for s, want := range cases {
+		if got := ParseHealthTarget(s); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v want %+v for %q", got, want, s)
+		}
+	}
+
+	var targets []HealthTarget
+	err := json.Unmarshal([]byte(`["api=http://a", {"name":"web","url":"http://b"}, {"url":"http://c"}, {"command":"check-queue"}]`), &targets)
+	assertNoError(t, err)
+	want := []HealthTarget{
+		{Name: "api", Url: "http://a"},
+		{Name: "web", Url: "http://b"},
+		{Name: "http://c", Url: "http://c"},
+		{Name: "check-queue", CommandCheck: CommandCheck{Command: "check-queue"}},
+	}
+	if !reflect.DeepEqual(targets, want) {
+		t.Errorf("got %+v want %+v", targets, want)
+	}
+}
+
+func TestHealthTargets(t *testing.T) {
+	t.Run("derives the app status from the latest state of each target", func(t *testing.T) {
+		app := App{Name: "app"}
+		app.recordHealth(HealthCheck{Status: "UP", Timestamp: 1, Target: "api"}, 10)
+		app.recordHealth(HealthCheck{Status: "UP", Timestamp: 2, Target: "web"}, 10)
+		assertHealthEquals(t, app.Health, HealthCheck{Status: "UP", Timestamp: 2})
+
+		app.recordHealth(HealthCheck{Status: "DOWN", Timestamp: 3, Target: "api"}, 20)
+		assertHealthEquals(t, app.Health, HealthCheck{Status: HealthDegraded, Timestamp: 3})
+
+		app.recordHealth(HealthCheck{Status: "DOWN", Timestamp: 4, Target: "web"}, 30)
+		assertHealthEquals(t, app.Health, HealthCheck{Status: "DOWN", Timestamp: 4})
+
+		if len(app.Targets) != 2 || app.Targets[0].Target != "api" || app.Targets[1].Timestamp != 4 {
+			t.Errorf("got targets %+v want the latest state of api and web", app.Targets)
+		}
+	})
+
+	t.Run("a report without target stands for the whole app", func(t *testing.T) {
+		app := App{Name: "app"}
+		app.recordHealth(HealthCheck{Status: "UP", Timestamp: 1, Target: "api"}, 10)
+		app.recordHealth(UNHEALTHY, 20)
+
+		assertHealthEquals(t, app.Health, UNHEALTHY)
+		if len(app.Targets) != 0 {
+			t.Errorf("got targets %+v want none", app.Targets)
+		}
+	})
+
+	t.Run("it lists the targets of an app", func(t *testing.T) {
+		store := &StubLogStore{}
+		server := NewApiServer(store, testInfo)
+		for _, body := range []string{
+			`{"status":"UP","timestamp":1,"target":"api","url":"http://a"}`,
+			`{"status":"DOWN","timestamp":2,"target":"web","url":"http://b"}`,
+		} {
+			request, _ := http.NewRequest(http.MethodPost, ApiHealthPath+"appa", strings.NewReader(body))
+			server.ServeHTTP(httptest.NewRecorder(), request)
+		}
+
+		assertHealthEquals(t, store.GetHealth("appa"), HealthCheck{Status: HealthDegraded, Timestamp: 2})
+
+		request, _ := http.NewRequest(http.MethodGet, ApiHealthPath+"appa"+ApiTargetsSuffix, nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		var targets []HealthCheck
+		json.NewDecoder(response.Body).Decode(&targets)
+		if len(targets) != 2 || targets[1].Status != "DOWN" || targets[1].Url != "http://b" {
+			t.Errorf("got %+v want api and web", targets)
+		}
+	})
+}
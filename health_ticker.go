@@ -5,30 +5,11 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
 )
 
-// CheckWebsite returns true if the URL returns a 200 status code, false otherwise.
+// CheckWebsite returns UP if a HEAD request to the URL returns a 200 status code, DOWN with the reason otherwise.
 func CheckWebsite(url string) HealthCheck {
-	response, err := http.Head(url)
-	if err != nil {
-		return HealthCheck{
-			Status:    "DOWN",
-			Timestamp: time.Now().Unix(),
-		}
-	}
-
-	if response.StatusCode != http.StatusOK {
-		return HealthCheck{
-			Status:    response.Status,
-			Timestamp: time.Now().Unix(),
-		}
-	}
-
-	return HealthCheck{
-		Status:    "UP",
-		Timestamp: time.Now().Unix(),
-	}
+	return HealthTarget{Url: url}.Check()
 }
 
 // WebsiteChecker checks a url, returning a bool.
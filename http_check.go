@@ -0,0 +1,167 @@
+package mond
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultMaxRedirects = 10
+
+// maxCheckBodyBytes limits how much of a response body is read for assertions.
+const maxCheckBodyBytes = 1 << 20
+
+// HttpCheck configures how an http target is checked. Without body assertions a HEAD request is
+// sent, otherwise a GET. ExpectStatus holds codes like "204", classes like "2xx" or ranges like
+// "200-399" and defaults to "200". A negative MaxRedirects does not follow redirects at all.
+type HttpCheck struct {
+	Method       string            `json:"method,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	ExpectStatus []string          `json:"expectStatus,omitempty"`
+	BodyContains string            `json:"bodyContains,omitempty"`
+	BodyRegex    string            `json:"bodyRegex,omitempty"`
+	JsonPath     []JsonAssertion   `json:"jsonPath,omitempty"`
+	MaxRedirects int               `json:"maxRedirects,omitempty"`
+}
+
+// JsonAssertion expects the value at a dotted Path like "data.items.0.state" to exist and, if
+// Equals is set, to equal it. Strings are compared as they are, other values in their JSON form.
+type JsonAssertion struct {
+	Path   string `json:"path"`
+	Equals string `json:"equals,omitempty"`
+}
+
+func (c HttpCheck) hasBodyAssertions() bool {
+	return c.BodyContains != "" || c.BodyRegex != "" || len(c.JsonPath) > 0
+}
+
+// checkHttp requests the target and checks the answer.
+func checkHttp(t HealthTarget) CheckResult {
+	method := t.Method
+	if method == "" {
+		method = http.MethodHead
+		if t.hasBodyAssertions() {
+			method = http.MethodGet
+		}
+	}
+	req, err := http.NewRequest(method, t.Url, nil)
+	if err != nil {
+		return CheckResult{Reason: fmt.Sprintf("invalid request, %v", err)}
+	}
+	for name, value := range t.Headers {
+		req.Header.Set(name, value)
+		if strings.EqualFold(name, "host") {
+			req.Host = value
+		}
+	}
+
+	started := time.Now()
+	resp, err := t.client().Do(req)
+	if err != nil {
+		return CheckResult{Latency: time.Since(started), Reason: err.Error()}
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxCheckBodyBytes))
+	latency := time.Since(started)
+	if err != nil {
+		return CheckResult{Latency: latency, Reason: fmt.Sprintf("problem reading body, %v", err)}
+	}
+
+	expected := t.ExpectStatus
+	if len(expected) == 0 {
+		expected = []string{"200"}
+	}
+	if !matchesStatus(strconv.Itoa(resp.StatusCode), expected) {
+		return CheckResult{Latency: latency, Reason: fmt.Sprintf("got status %s want %s", resp.Status, strings.Join(expected, ","))}
+	}
+	reason := t.assertBody(body)
+	return CheckResult{Up: reason == "", Latency: latency, Reason: reason}
+}
+
+func (t HealthTarget) client() *http.Client {
+	maxRedirects := t.MaxRedirects
+	if maxRedirects == 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+	return &http.Client{
+		Timeout: t.timeout(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if maxRedirects < 0 {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		},
+	}
+}
+
+func (c HttpCheck) assertBody(body []byte) string {
+	if c.BodyContains != "" && !strings.Contains(string(body), c.BodyContains) {
+		return fmt.Sprintf("body does not contain %q", c.BodyContains)
+	}
+	if c.BodyRegex != "" {
+		re, err := regexp.Compile(c.BodyRegex)
+		if err != nil {
+			return fmt.Sprintf("invalid body regex, %v", err)
+		}
+		if !re.Match(body) {
+			return fmt.Sprintf("body does not match %q", c.BodyRegex)
+		}
+	}
+	if len(c.JsonPath) == 0 {
+		return ""
+	}
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return fmt.Sprintf("body is no JSON, %v", err)
+	}
+	for _, a := range c.JsonPath {
+		value, ok := lookupJsonPath(doc, a.Path)
+		if !ok {
+			return fmt.Sprintf("body has no %s", a.Path)
+		}
+		if a.Equals != "" && jsonValueString(value) != a.Equals {
+			return fmt.Sprintf("got %s=%s want %s", a.Path, jsonValueString(value), a.Equals)
+		}
+	}
+	return ""
+}
+
+func lookupJsonPath(doc interface{}, path string) (interface{}, bool) {
+	value := doc
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func jsonValueString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
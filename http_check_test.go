@@ -0,0 +1,100 @@
+package mond
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHttpCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.Header().Set("content-type", jsonContentType)
+			w.Write([]byte(`{"status":"ok","checks":[{"name":"db","up":true}],"version":3}`))
+		case "/private":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		case "/method":
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/moved":
+			http.Redirect(w, r, "/health", http.StatusFound)
+		case "/slow":
+			time.Sleep(50 * time.Millisecond)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	target := func(path string, check HttpCheck) HealthTarget {
+		return HealthTarget{Name: path, Url: server.URL + path, HttpCheck: check}
+	}
+
+	up := map[string]HealthTarget{
+		"plain HEAD":          target("/health", HttpCheck{}),
+		"body contains":       target("/health", HttpCheck{BodyContains: `"ok"`}),
+		"body regex":          target("/health", HttpCheck{BodyRegex: `"version":\d+`}),
+		"json path":           target("/health", HttpCheck{JsonPath: []JsonAssertion{{Path: "status", Equals: "ok"}, {Path: "checks.0.up", Equals: "true"}, {Path: "version"}}}),
+		"headers":             target("/private", HttpCheck{Headers: map[string]string{"Authorization": "Bearer token"}}),
+		"method":              target("/method", HttpCheck{Method: http.MethodPost}),
+		"expected class":      target("/missing", HttpCheck{ExpectStatus: []string{"4xx"}}),
+		"expected range":      target("/missing", HttpCheck{ExpectStatus: []string{"400-404"}}),
+		"followed redirect":   target("/moved", HttpCheck{}),
+		"unfollowed redirect": target("/moved", HttpCheck{MaxRedirects: -1, ExpectStatus: []string{"302"}}),
+	}
+	for name, target := range up {
+		t.Run(name+" is up", func(t *testing.T) {
+			check := target.Check()
+			if check.Status != "UP" || check.Reason != "" {
+				t.Errorf("got %+v want UP", check)
+			}
+		})
+	}
+
+	down := map[string]struct {
+		target HealthTarget
+		reason string
+	}{
+		"unexpected status": {target("/missing", HttpCheck{}), "got status 404 Not Found want 200"},
+		"missing text":      {target("/health", HttpCheck{BodyContains: "error"}), `body does not contain "error"`},
+		"wrong json value":  {target("/health", HttpCheck{JsonPath: []JsonAssertion{{Path: "version", Equals: "4"}}}), "got version=3 want 4"},
+		"missing json path": {target("/health", HttpCheck{JsonPath: []JsonAssertion{{Path: "checks.1.up"}}}), "body has no checks.1.up"},
+		"not json":          {target("/method", HttpCheck{Method: http.MethodPost, JsonPath: []JsonAssertion{{Path: "a"}}}), "body is no JSON"},
+		"redirect":          {target("/moved", HttpCheck{MaxRedirects: -1}), "got status 302 Found"},
+		"timeout":           {HealthTarget{Url: server.URL + "/slow", Timeout: Duration(10 * time.Millisecond)}, "Timeout"},
+	}
+	for name, c := range down {
+		t.Run(name+" is down", func(t *testing.T) {
+			check := c.target.Check()
+			if check.Status != "DOWN" || !strings.Contains(check.Reason, c.reason) {
+				t.Errorf("got %+v want DOWN because of %q", check, c.reason)
+			}
+		})
+	}
+
+	t.Run("flags slow answers and measures the latency", func(t *testing.T) {
+		check := HealthTarget{Url: server.URL + "/slow", SlowAfter: Duration(10 * time.Millisecond)}.Check()
+
+		if check.Status != "UP" || !check.Slow || check.Latency < 50 {
+			t.Errorf("got %+v want a slow UP taking at least 50ms", check)
+		}
+		if check.GetCardClass() != "bg-warning" {
+			t.Errorf("got card class %s want bg-warning", check.GetCardClass())
+		}
+	})
+
+	t.Run("checks all targets and names the results", func(t *testing.T) {
+		checks := CheckTargets([]HealthTarget{target("/health", HttpCheck{}), target("/missing", HttpCheck{})})
+
+		if len(checks) != 2 || checks[0].Target != "/health" || checks[0].Status != "UP" || checks[1].Status != "DOWN" {
+			t.Errorf("got %+v want /health UP and /missing DOWN", checks)
+		}
+	})
+}
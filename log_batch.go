@@ -0,0 +1,140 @@
+package mond
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+const MaxBatchBytes = 32 << 20
+const MaxRawLogLength = 64 << 10
+
+// Batch formats accepted by POST /logs/{app}, picked by the format parameter or detected.
+const (
+	BatchFormatRaw    = "raw"
+	BatchFormatNDJSON = "ndjson"
+	BatchFormatJSON   = "json"
+)
+
+// BatchResult reports how many logs of a batch were accepted and why the others were not.
+type BatchResult struct {
+	Accepted int          `json:"accepted"`
+	Rejected int          `json:"rejected"`
+	Errors   []BatchError `json:"errors,omitempty"`
+}
+
+// BatchError refers to the line of the body or the index of the array element it was found in.
+type BatchError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+func (r *BatchResult) reject(line int, err error) {
+	r.Rejected++
+	r.Errors = append(r.Errors, BatchError{Line: line, Error: err.Error()})
+}
+
+// ParseLogBatch reads all logs of a batch. Raw lines are read by parser, or auto-detected without
+// trusted proxies if it is nil, NDJSON lines and JSON array elements are taken as AccessLog
+// objects. Objects carrying nothing but a raw line and its LogSource, like those forwarded by
+// mond-client, get the rest from the parser too. Empty lines are skipped.
+func ParseLogBatch(body []byte, contentType, format string, parser LogParser) (AccessLogs, BatchResult, error) {
+	if format == "" {
+		format = detectBatchFormat(body, contentType)
+	}
+	var logs AccessLogs
+	var result BatchResult
+	now := time.Now().Unix()
+	if parser == nil {
+		parser = defaultLogParser
+	}
+
+	switch format {
+	case BatchFormatRaw:
+		for i, line := range strings.Split(string(body), "\n") {
+			line = strings.TrimSuffix(line, "\r")
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if len(line) > MaxRawLogLength {
+				result.reject(i+1, fmt.Errorf("line longer than %d bytes", MaxRawLogLength))
+				continue
+			}
+			log, _ := parser.Parse(line)
+			log.Unix = now
+			log.Raw = line
+			log.Version = AccessLogVersion
+			logs = append(logs, log)
+		}
+	case BatchFormatNDJSON:
+		for i, line := range bytes.Split(body, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			log, err := decodeBatchLog(line, now, parser)
+			if err != nil {
+				result.reject(i+1, err)
+				continue
+			}
+			logs = append(logs, log)
+		}
+	case BatchFormatJSON:
+		var elements []json.RawMessage
+		if err := json.Unmarshal(body, &elements); err != nil {
+			return nil, result, fmt.Errorf("body is no JSON array, %v", err)
+		}
+		for i, element := range elements {
+			log, err := decodeBatchLog(element, now, parser)
+			if err != nil {
+				result.reject(i, err)
+				continue
+			}
+			logs = append(logs, log)
+		}
+	default:
+		return nil, result, fmt.Errorf("unknown format %q, want raw, ndjson or json", format)
+	}
+
+	result.Accepted = len(logs)
+	return logs, result, nil
+}
+
+// detectBatchFormat falls back to raw lines unless the content type says NDJSON or the body is
+// a JSON array. Log lines may well look like JSON, so objects are never guessed.
+func detectBatchFormat(body []byte, contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == ndjsonContentType {
+		return BatchFormatNDJSON
+	}
+	trimmed := bytes.TrimSpace(body)
+	if bytes.HasPrefix(trimmed, []byte("[")) && json.Valid(trimmed) {
+		return BatchFormatJSON
+	}
+	return BatchFormatRaw
+}
+
+func decodeBatchLog(data []byte, now int64, parser LogParser) (AccessLog, error) {
+	var log AccessLog
+	data = bytes.TrimSpace(data)
+	if !bytes.HasPrefix(data, []byte("{")) {
+		return log, fmt.Errorf("not an AccessLog object")
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		return log, fmt.Errorf("invalid AccessLog, %v", err)
+	}
+	if log.Raw != "" && log.Ip == "" && log.Path == "" && log.Status == "" && log.Timestamp == 0 {
+		parsed, _ := parser.Parse(log.Raw)
+		parsed.Unix, parsed.Raw, parsed.LogSource = log.Unix, log.Raw, log.LogSource
+		log = parsed
+	}
+	if log.Unix == 0 {
+		log.Unix = now
+	}
+	log.Version = AccessLogVersion
+	return log, nil
+}
@@ -0,0 +1,113 @@
+package mond
+
+import (
+	"testing"
+)
+
+func TestParseLogBatch(t *testing.T) {
+	t.Run("raw lines", func(t *testing.T) {
+		body := "line 1\r\n\nline 2\n"
+
+		logs, result, err := ParseLogBatch([]byte(body), textContentType, "", nil)
+
+		assertNoError(t, err)
+		assertBatchResult(t, result, 2, 0)
+		if len(logs) != 2 || logs[0].Raw != "line 1" || logs[1].Raw != "line 2" {
+			t.Errorf("got %v", logs)
+		}
+	})
+
+	t.Run("raw lines are read by the parser", func(t *testing.T) {
+		body := `10.0.0.1 - - [02/Jul/2021:22:50:59 +0200] "PUT /a HTTP/1.1" 201 12 "-" "curl/7.68.0"`
+
+		logs, _, err := ParseLogBatch([]byte(body), textContentType, "", nil)
+
+		assertNoError(t, err)
+		want := AccessLog{Ip: "10.0.0.1", Timestamp: 1625259059, Method: "PUT", Path: "/a", Protocol: "HTTP/1.1", Status: "201", Bytes: 12,
+			UserAgent: "curl/7.68.0", RemoteIp: "10.0.0.1", Raw: body}
+		assertAccessLogEquals(t, logs[0], want)
+		if logs[0].Version != AccessLogVersion {
+			t.Errorf("got version %d want %d", logs[0].Version, AccessLogVersion)
+		}
+	})
+
+	t.Run("raw lines which look like json", func(t *testing.T) {
+		body := `{"level":"info"}` + "\n" + "[main] started"
+
+		logs, result, err := ParseLogBatch([]byte(body), jsonContentType, "", nil)
+
+		assertNoError(t, err)
+		assertBatchResult(t, result, 2, 0)
+		if logs[0].Raw != `{"level":"info"}` {
+			t.Errorf("got %v", logs)
+		}
+	})
+
+	t.Run("ndjson with a broken line", func(t *testing.T) {
+		body := `{"raw":"a","status":"200","unix":5}` + "\n" + `{"raw":` + "\n" + `{"raw":"c"}`
+
+		logs, result, err := ParseLogBatch([]byte(body), ndjsonContentType+"; charset=utf-8", "", nil)
+
+		assertNoError(t, err)
+		assertBatchResult(t, result, 2, 1)
+		if result.Errors[0].Line != 2 {
+			t.Errorf("got error on line %d want 2", result.Errors[0].Line)
+		}
+		if logs[0].Unix != 5 || logs[0].Status != "200" || logs[1].Raw != "c" || logs[1].Unix == 0 {
+			t.Errorf("got %v", logs)
+		}
+	})
+
+	t.Run("ndjson raw lines with their source are read by the parser", func(t *testing.T) {
+		body := `{"raw":"10.0.0.1 - - [02/Jul/2021:22:50:59 +0200] \"GET /a HTTP/1.1\" 200 5","stream":"stdout","pid":42,"host":"web-1","instance":"a1","captured":1625259059123}`
+
+		logs, result, err := ParseLogBatch([]byte(body), ndjsonContentType, "", nil)
+
+		assertNoError(t, err)
+		assertBatchResult(t, result, 1, 0)
+		want := LogSource{Stream: StreamStdout, Pid: 42, Host: "web-1", Instance: "a1", Captured: 1625259059123}
+		if logs[0].Path != "/a" || logs[0].Status != "200" || logs[0].LogSource != want || logs[0].Unix == 0 || logs[0].Version != AccessLogVersion {
+			t.Errorf("got %+v", logs[0])
+		}
+	})
+
+	t.Run("json array", func(t *testing.T) {
+		body := `[{"raw":"a"}, 1, {"raw":"b"}]`
+
+		logs, result, err := ParseLogBatch([]byte(body), jsonContentType, "", nil)
+
+		assertNoError(t, err)
+		assertBatchResult(t, result, 2, 1)
+		if result.Errors[0].Line != 1 {
+			t.Errorf("got error on element %d want 1", result.Errors[0].Line)
+		}
+		if logs[1].Raw != "b" {
+			t.Errorf("got %v", logs)
+		}
+	})
+
+	t.Run("explicit format", func(t *testing.T) {
+		body := `[{"raw":"a"}]`
+
+		logs, _, err := ParseLogBatch([]byte(body), jsonContentType, BatchFormatRaw, nil)
+
+		assertNoError(t, err)
+		if len(logs) != 1 || logs[0].Raw != body {
+			t.Errorf("got %v", logs)
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		_, _, err := ParseLogBatch([]byte("a"), textContentType, "xml", nil)
+		if err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func assertBatchResult(t testing.TB, got BatchResult, accepted, rejected int) {
+	t.Helper()
+	if got.Accepted != accepted || got.Rejected != rejected {
+		t.Errorf("got %d accepted and %d rejected, want %d and %d", got.Accepted, got.Rejected, accepted, rejected)
+	}
+}
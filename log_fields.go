@@ -0,0 +1,249 @@
+package mond
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fields of an AccessLog which can be mapped to the keys of json and logfmt logs.
+const (
+	FieldIp        = "ip"
+	FieldRemoteIp  = "remoteIp"
+	FieldPath      = "path"
+	FieldStatus    = "status"
+	FieldTime      = "time"
+	FieldMethod    = "method"
+	FieldProtocol  = "protocol"
+	FieldBytes     = "bytes"
+	FieldReferrer  = "referrer"
+	FieldUserAgent = "userAgent"
+	FieldLatency   = "latency"
+)
+
+// defaultLogFields are the keys tried for each field if none is configured. They cover the logs
+// of Caddy, Traefik, logrus, zap and the usual web frameworks.
+var defaultLogFields = map[string][]string{
+	FieldIp: {"remote_addr", "remote_ip", "client_ip", "clientip", "ip", "ClientHost", "request.remote_ip", "request.client_ip"},
+	FieldRemoteIp: {"x_forwarded_for", "http_x_forwarded_for", "forwarded_for", "request_X-Forwarded-For", "request.headers.X-Forwarded-For",
+		"forwarded", "http_forwarded", "request_Forwarded", "request.headers.Forwarded"},
+	FieldPath:      {"path", "uri", "request_uri", "url", "RequestPath", "request.uri", "request.path", "request"},
+	FieldStatus:    {"status", "status_code", "statusCode", "code", "DownstreamStatus", "response.status"},
+	FieldTime:      {"time", "timestamp", "ts", "@timestamp", "StartUTC", "time_local"},
+	FieldMethod:    {"method", "request_method", "RequestMethod", "request.method"},
+	FieldProtocol:  {"protocol", "proto", "server_protocol", "RequestProtocol", "request.proto"},
+	FieldBytes:     {"bytes", "size", "body_bytes_sent", "bytes_sent", "response_size", "DownstreamContentSize"},
+	FieldReferrer:  {"referrer", "referer", "http_referer", "request_Referer", "request.headers.Referer"},
+	FieldUserAgent: {"user_agent", "userAgent", "http_user_agent", "request_User-Agent", "request.headers.User-Agent"},
+	FieldLatency:   {"latency_ms", "duration_ms", "elapsed_ms", "request_time", "latency", "duration", "Duration"},
+}
+
+// fieldMapping looks up the fields of an AccessLog in a document of key values.
+type fieldMapping map[string][]string
+
+func newFieldMapping(fields map[string]string) (fieldMapping, error) {
+	mapping := fieldMapping{}
+	for field, keys := range defaultLogFields {
+		mapping[field] = keys
+	}
+	for field, key := range fields {
+		if _, ok := defaultLogFields[field]; !ok {
+			return nil, fmt.Errorf("unknown field %q, want one of ip, remoteIp, path, status, time, method, protocol, bytes, referrer, userAgent or latency", field)
+		}
+		mapping[field] = []string{key}
+	}
+	return mapping, nil
+}
+
+// read fills an AccessLog from doc, it reports false if neither a path nor a status was found.
+func (m fieldMapping) read(doc map[string]interface{}) (AccessLog, bool) {
+	var log AccessLog
+	log.Ip = stripPort(m.value(doc, FieldIp))
+	log.RemoteIp = m.value(doc, FieldRemoteIp)
+	if path := m.value(doc, FieldPath); strings.Contains(path, " HTTP/") {
+		log.setRequest(path)
+	} else {
+		log.Path = path
+	}
+	log.Status = m.value(doc, FieldStatus)
+	log.Timestamp = parseLogTime(m.value(doc, FieldTime))
+	if method := m.value(doc, FieldMethod); method != "" {
+		log.Method = method
+	}
+	if protocol := m.value(doc, FieldProtocol); protocol != "" {
+		log.Protocol = protocol
+	}
+	log.Bytes = parseBytes(m.value(doc, FieldBytes))
+	log.Referrer = m.value(doc, FieldReferrer)
+	log.UserAgent = m.value(doc, FieldUserAgent)
+	log.Latency = parseLatency(m.lookup(doc, FieldLatency))
+	return log, log.Path != "" || log.Status != ""
+}
+
+func (m fieldMapping) value(doc map[string]interface{}, field string) string {
+	_, value := m.lookup(doc, field)
+	return value
+}
+
+// lookup returns the first key of the field found in doc and its value.
+func (m fieldMapping) lookup(doc map[string]interface{}, field string) (string, string) {
+	for _, key := range m[field] {
+		value, ok := doc[key]
+		if !ok {
+			value, ok = lookupJsonPath(doc, key)
+		}
+		if !ok || value == nil {
+			continue
+		}
+		if values, ok := value.([]interface{}); ok {
+			if len(values) == 0 {
+				continue
+			}
+			value = values[0]
+		}
+		if s := jsonValueString(value); s != "" && s != "-" {
+			return key, s
+		}
+	}
+	return "", ""
+}
+
+// parseLatency returns the milliseconds of a duration like "12ms" or a number. Numbers are
+// taken as seconds unless their key ends in ms, us or ns, or is the Duration of Traefik logs,
+// which is in nanoseconds.
+func parseLatency(key, value string) float64 {
+	if value == "" {
+		return 0
+	}
+	if d, err := time.ParseDuration(value); err == nil && strings.TrimLeft(value, "0123456789.") != "" {
+		return float64(d) / float64(time.Millisecond)
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	lower := strings.ToLower(key)
+	switch {
+	case strings.HasSuffix(lower, "ms"):
+		return n
+	case strings.HasSuffix(lower, "us"):
+		return n / 1e3
+	case strings.HasSuffix(lower, "ns") || key == "Duration":
+		return n / 1e6
+	}
+	return n * 1e3
+}
+
+// stripPort drops the port of addresses like 10.0.0.1:4711 or [::1]:4711.
+func stripPort(addr string) string {
+	if strings.HasPrefix(addr, "[") {
+		if end := strings.Index(addr, "]"); end > 0 {
+			return addr[1:end]
+		}
+	}
+	if strings.Count(addr, ":") == 1 {
+		return addr[:strings.Index(addr, ":")]
+	}
+	return addr
+}
+
+// jsonLogParser reads lines holding a JSON object each.
+type jsonLogParser struct {
+	fields fieldMapping
+}
+
+var defaultJsonParser = jsonLogParser{fields: defaultLogFields}
+
+func newJsonLogParser(config LogFormatConfig) (LogParser, error) {
+	fields, err := newFieldMapping(config.Fields)
+	if err != nil {
+		return nil, err
+	}
+	return jsonLogParser{fields: fields}, nil
+}
+
+func (p jsonLogParser) Parse(raw string) (AccessLog, bool) {
+	var doc map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return AccessLog{}, false
+	}
+	return p.fields.read(doc)
+}
+
+// logfmtLogParser reads lines of key=value pairs, values with spaces are quoted.
+type logfmtLogParser struct {
+	fields fieldMapping
+}
+
+var defaultLogfmtParser = logfmtLogParser{fields: defaultLogFields}
+
+func newLogfmtLogParser(config LogFormatConfig) (LogParser, error) {
+	fields, err := newFieldMapping(config.Fields)
+	if err != nil {
+		return nil, err
+	}
+	return logfmtLogParser{fields: fields}, nil
+}
+
+func (p logfmtLogParser) Parse(raw string) (AccessLog, bool) {
+	doc := parseLogfmt(raw)
+	if len(doc) < 2 {
+		return AccessLog{}, false
+	}
+	return p.fields.read(doc)
+}
+
+// parseLogfmt returns the pairs of a logfmt line. Bare keys stay empty, text which is no pair at
+// all makes the result empty.
+func parseLogfmt(line string) map[string]interface{} {
+	doc := map[string]interface{}{}
+	for i := 0; i < len(line); {
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' {
+			if line[i] == '"' {
+				return nil
+			}
+			i++
+		}
+		key := line[start:i]
+		if key == "" {
+			if i < len(line) {
+				return nil
+			}
+			break
+		}
+		if i >= len(line) || line[i] != '=' {
+			doc[key] = ""
+			continue
+		}
+		i++
+		if i < len(line) && line[i] == '"' {
+			var value strings.Builder
+			for i++; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+				}
+				value.WriteByte(line[i])
+			}
+			if i >= len(line) {
+				return nil
+			}
+			i++
+			doc[key] = value.String()
+			continue
+		}
+		start = i
+		for i < len(line) && line[i] != ' ' {
+			i++
+		}
+		doc[key] = line[start:i]
+	}
+	return doc
+}
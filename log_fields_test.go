@@ -0,0 +1,75 @@
+package mond
+
+import (
+	"testing"
+)
+
+func TestJsonLogParser(t *testing.T) {
+	t.Run("reads mapped fields", func(t *testing.T) {
+		parser, err := NewLogParser(LogFormatConfig{Format: LogFormatJson, Fields: map[string]string{
+			FieldIp: "peer.addr", FieldPath: "http.target", FieldStatus: "http.code", FieldTime: "at",
+		}})
+		assertNoError(t, err)
+
+		got, _ := parser.Parse(`{"at":"2021-07-02T20:50:59Z","peer":{"addr":"[::1]:4711"},"http":{"target":"/a","code":"503"}}`)
+		assertAccessLogEquals(t, got, AccessLog{Ip: "::1", Timestamp: 1625259059, Path: "/a", Status: "503"})
+	})
+
+	t.Run("reads the path of a request line", func(t *testing.T) {
+		got, ok := defaultJsonParser.Parse(`{"remote_addr":"10.0.0.1:5555","request":"GET /b HTTP/1.1","status":"200"}`)
+		if !ok {
+			t.Fatal("expected the line to be read")
+		}
+		assertAccessLogEquals(t, got, AccessLog{Ip: "10.0.0.1", Method: "GET", Path: "/b", Protocol: "HTTP/1.1", Status: "200"})
+	})
+
+	t.Run("does not read objects without path and status", func(t *testing.T) {
+		if _, ok := defaultJsonParser.Parse(`{"level":"info","msg":"started"}`); ok {
+			t.Error("expected the line not to be read")
+		}
+	})
+
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		if _, err := NewLogParser(LogFormatConfig{Format: LogFormatJson, Fields: map[string]string{"verb": "m"}}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestParseLatency(t *testing.T) {
+	cases := []struct {
+		key, value string
+		want       float64
+	}{
+		{"request_time", "0.250", 250},
+		{"duration_ms", "12", 12},
+		{"elapsed_us", "1500", 1.5},
+		{"Duration", "2000000", 2},
+		{"latency", "1m", 60000},
+		{"latency", "fast", 0},
+		{"latency", "", 0},
+	}
+	for _, c := range cases {
+		if got := parseLatency(c.key, c.value); got != c.want {
+			t.Errorf("got %v for %s=%q want %v", got, c.key, c.value, c.want)
+		}
+	}
+}
+
+func TestLogfmtLogParser(t *testing.T) {
+	t.Run("reads quoted values", func(t *testing.T) {
+		parser, err := NewLogParser(LogFormatConfig{Format: LogFormatLogfmt, Fields: map[string]string{FieldPath: "route"}})
+		assertNoError(t, err)
+
+		got, _ := parser.Parse(`ts=1625259059 route="/search?q=a b" status=200 forwarded_for="92.104.237.155, 10.0.0.9" debug`)
+		assertAccessLogEquals(t, got, AccessLog{Timestamp: 1625259059, Path: "/search?q=a b", Status: "200", RemoteIp: "92.104.237.155, 10.0.0.9"})
+	})
+
+	t.Run("does not read text", func(t *testing.T) {
+		for _, raw := range []string{"GET /index.html", `msg="unterminated status=200`, `say "hi" status=200`} {
+			if _, ok := defaultLogfmtParser.Parse(raw); ok {
+				t.Errorf("expected %q not to be read", raw)
+			}
+		}
+	})
+}
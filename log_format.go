@@ -0,0 +1,117 @@
+package mond
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// NginxCombinedFormat is the log_format nginx uses if none is given.
+const NginxCombinedFormat = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"`
+
+var nginxVariableReg = regexp.MustCompile(`\$(\w+)|\$\{(\w+)\}`)
+
+// nginxLogParser reads lines written with an nginx log_format.
+type nginxLogParser struct {
+	reg       *regexp.Regexp
+	variables []string
+}
+
+func newNginxLogParser(config LogFormatConfig) (LogParser, error) {
+	format := config.LogFormat
+	if format == "" {
+		format = NginxCombinedFormat
+	}
+	return NewNginxLogParser(format)
+}
+
+// NewNginxLogParser compiles an nginx log_format like NginxCombinedFormat. Values with spaces need
+// to be quoted or in brackets like $request and $time_local there. Of its variables
+// remote_addr, time_local, time_iso8601, msec, request, request_uri, uri, request_method,
+// server_protocol, status, body_bytes_sent, bytes_sent, http_referer, http_user_agent,
+// request_time, http_x_forwarded_for and http_forwarded are read, the others are skipped.
+func NewNginxLogParser(format string) (LogParser, error) {
+	p := &nginxLogParser{}
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	last := 0
+	matches := nginxVariableReg.FindAllStringSubmatchIndex(format, -1)
+	for i, m := range matches {
+		if i > 0 && m[0] == last {
+			return nil, fmt.Errorf("log_format %q has adjacent variables at %d", format, m[0])
+		}
+		pattern.WriteString(regexp.QuoteMeta(format[last:m[0]]))
+		var name string
+		if m[2] >= 0 {
+			name = format[m[2]:m[3]]
+		} else {
+			name = format[m[4]:m[5]]
+		}
+		p.variables = append(p.variables, name)
+		switch {
+		case !strings.HasSuffix(format[:m[0]], `"`) && !strings.HasSuffix(format[:m[0]], "["):
+			pattern.WriteString(`(\S*)`)
+		case i == len(matches)-1 && m[1] == len(format):
+			pattern.WriteString("(.*)")
+		default:
+			pattern.WriteString("(.*?)")
+		}
+		last = m[1]
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("log_format %q has no variables", format)
+	}
+	pattern.WriteString(regexp.QuoteMeta(format[last:]))
+	pattern.WriteString("$")
+	reg, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, fmt.Errorf("problem compiling log_format %q, %v", format, err)
+	}
+	p.reg = reg
+	return p, nil
+}
+
+func (p *nginxLogParser) Parse(raw string) (AccessLog, bool) {
+	m := p.reg.FindStringSubmatch(strings.TrimRight(raw, "\r\n"))
+	if m == nil {
+		return AccessLog{}, false
+	}
+	var log AccessLog
+	var uri string
+	for i, name := range p.variables {
+		value := m[i+1]
+		if value == "-" {
+			continue
+		}
+		switch name {
+		case "remote_addr":
+			log.Ip = value
+		case "time_local", "time_iso8601", "msec":
+			log.Timestamp = parseLogTime(value)
+		case "request":
+			log.setRequest(value)
+		case "request_uri", "uri":
+			uri = value
+		case "request_method":
+			log.Method = value
+		case "server_protocol":
+			log.Protocol = value
+		case "status":
+			log.Status = value
+		case "body_bytes_sent", "bytes_sent":
+			log.Bytes = parseBytes(value)
+		case "http_referer":
+			log.Referrer = value
+		case "http_user_agent":
+			log.UserAgent = value
+		case "request_time":
+			log.Latency = parseLatency(name, value)
+		case "http_x_forwarded_for", "http_forwarded":
+			log.RemoteIp = value
+		}
+	}
+	if uri != "" {
+		log.Path = uri
+	}
+	return log, true
+}
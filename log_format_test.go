@@ -0,0 +1,61 @@
+package mond
+
+import (
+	"testing"
+)
+
+func TestNginxLogParser(t *testing.T) {
+	t.Run("reads the variables of a log_format", func(t *testing.T) {
+		parser, err := NewNginxLogParser(`$remote_addr [$time_iso8601] "$request" $status $request_time "$http_x_forwarded_for"`)
+		assertNoError(t, err)
+
+		got, ok := parser.Parse(`10.0.0.4 [2021-07-02T22:50:59+02:00] "GET /futures?x=1 HTTP/1.1" 200 0.012 "92.104.237.155, 10.0.0.9"`)
+		if !ok {
+			t.Fatal("expected the line to be read")
+		}
+		assertAccessLogEquals(t, got, AccessLog{Ip: "10.0.0.4", Timestamp: 1625259059, Method: "GET", Path: "/futures?x=1", Protocol: "HTTP/1.1", Status: "200",
+			Latency: 12, RemoteIp: "92.104.237.155, 10.0.0.9"})
+	})
+
+	t.Run("prefers the uri to the request", func(t *testing.T) {
+		parser, err := NewNginxLogParser(`${status} "$request" $uri`)
+		assertNoError(t, err)
+
+		got, _ := parser.Parse(`404 "GET /a/../b HTTP/1.1" /b`)
+		assertAccessLogEquals(t, got, AccessLog{Method: "GET", Path: "/b", Protocol: "HTTP/1.1", Status: "404"})
+	})
+
+	t.Run("uses the combined format by default", func(t *testing.T) {
+		parser, err := NewLogParser(LogFormatConfig{Format: LogFormatNginx})
+		assertNoError(t, err)
+
+		got, _ := parser.Parse(`10.0.0.1 - - [02/Jul/2021:22:50:59 +0200] "GET /futures HTTP/1.1" 200 7280 "-" "curl/7.68.0"`)
+		assertAccessLogEquals(t, got, AccessLog{Ip: "10.0.0.1", Timestamp: 1625259059, Method: "GET", Path: "/futures", Protocol: "HTTP/1.1", Status: "200",
+			Bytes: 7280, UserAgent: "curl/7.68.0"})
+	})
+
+	t.Run("does not read other lines", func(t *testing.T) {
+		parser, err := NewNginxLogParser(`$remote_addr $status`)
+		assertNoError(t, err)
+
+		if _, ok := parser.Parse("10.0.0.1 200 extra"); ok {
+			t.Error("expected a line with more fields not to be read")
+		}
+	})
+
+	t.Run("reads split request variables", func(t *testing.T) {
+		parser, err := NewNginxLogParser(`$request_method $request_uri $server_protocol $status $bytes_sent "$http_referer"`)
+		assertNoError(t, err)
+
+		got, _ := parser.Parse(`DELETE /items/1 HTTP/2.0 204 0 "-"`)
+		assertAccessLogEquals(t, got, AccessLog{Method: "DELETE", Path: "/items/1", Protocol: "HTTP/2.0", Status: "204"})
+	})
+
+	t.Run("rejects formats it cannot read", func(t *testing.T) {
+		for _, format := range []string{"", "plain text", "$status$body_bytes_sent"} {
+			if _, err := NewNginxLogParser(format); err == nil {
+				t.Errorf("expected an error for %q", format)
+			}
+		}
+	})
+}
@@ -0,0 +1,285 @@
+package mond
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log formats registered by default.
+const (
+	LogFormatAuto     = "auto"
+	LogFormatCombined = "combined"
+	LogFormatCommon   = "common"
+	LogFormatNginx    = "nginx"
+	LogFormatHaproxy  = "haproxy"
+	LogFormatJson     = "json"
+	LogFormatLogfmt   = "logfmt"
+	LogFormatRegex    = "regex"
+)
+
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// LogParser reads an AccessLog out of a raw log line. It reports false if the line is not in its
+// format. The Raw and Unix fields are left to the caller. RemoteIp holds the X-Forwarded-For or
+// Forwarded header as logged, LogParsers resolve the client from it.
+type LogParser interface {
+	Parse(raw string) (AccessLog, bool)
+}
+
+// LogParserFunc turns a func into a LogParser.
+type LogParserFunc func(raw string) (AccessLog, bool)
+
+func (f LogParserFunc) Parse(raw string) (AccessLog, bool) {
+	return f(raw)
+}
+
+// LogParserFactory creates a parser for the settings of a LogFormatConfig.
+type LogParserFactory func(config LogFormatConfig) (LogParser, error)
+
+var (
+	logParsersMu sync.RWMutex
+	logParsers   = map[string]LogParserFactory{
+		LogFormatAuto:     fixedLogParser(AutoLogParser),
+		LogFormatCombined: fixedLogParser(LogParserFunc(parseCombined)),
+		LogFormatCommon:   fixedLogParser(LogParserFunc(parseCommon)),
+		LogFormatHaproxy:  fixedLogParser(LogParserFunc(parseHaproxy)),
+		LogFormatRegex:    fixedLogParser(LogParserFunc(parseRegex)),
+		LogFormatNginx:    newNginxLogParser,
+		LogFormatJson:     newJsonLogParser,
+		LogFormatLogfmt:   newLogfmtLogParser,
+	}
+)
+
+func fixedLogParser(parser LogParser) LogParserFactory {
+	return func(LogFormatConfig) (LogParser, error) {
+		return parser, nil
+	}
+}
+
+// RegisterLogParser makes factory create the parsers of the format name, replacing an earlier one.
+func RegisterLogParser(name string, factory LogParserFactory) {
+	logParsersMu.Lock()
+	defer logParsersMu.Unlock()
+	logParsers[name] = factory
+}
+
+// NewLogParser creates the parser configured by config. Lines it cannot read are auto-detected.
+func NewLogParser(config LogFormatConfig) (LogParser, error) {
+	format := config.Format
+	if format == "" {
+		format = LogFormatAuto
+	}
+	logParsersMu.RLock()
+	factory, ok := logParsers[format]
+	logParsersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+	parser, err := factory(config)
+	if err != nil {
+		return nil, err
+	}
+	if format == LogFormatAuto {
+		return parser, nil
+	}
+	return withFallback(parser), nil
+}
+
+func withFallback(parser LogParser) LogParser {
+	return LogParserFunc(func(raw string) (AccessLog, bool) {
+		if log, ok := parser.Parse(raw); ok {
+			return log, true
+		}
+		return AutoLogParser.Parse(raw)
+	})
+}
+
+// AutoLogParser detects the format of each line: JSON objects, combined and common logs, HAProxy
+// and logfmt lines in this order. Other lines are searched by ParseRawLog.
+var AutoLogParser LogParser = LogParserFunc(func(raw string) (AccessLog, bool) {
+	trimmed := strings.TrimSpace(raw)
+	if strings.HasPrefix(trimmed, "{") {
+		if log, ok := defaultJsonParser.Parse(trimmed); ok {
+			return log, true
+		}
+	}
+	for _, parse := range []func(string) (AccessLog, bool){parseCombined, parseCommon, parseHaproxy, defaultLogfmtParser.Parse} {
+		if log, ok := parse(trimmed); ok {
+			return log, true
+		}
+	}
+	return parseRegex(raw)
+})
+
+// LogFormatConfig selects the format of the logs of an app. LogFormat is the log_format string of
+// nginx logs, Fields maps the AccessLog fields ip, remoteIp, path, status and time to the dotted
+// keys of json and logfmt logs.
+type LogFormatConfig struct {
+	Format    string            `json:"format"`
+	LogFormat string            `json:"logFormat,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+}
+
+// UnmarshalJSON accepts the name of a format as well as a config object.
+func (c *LogFormatConfig) UnmarshalJSON(b []byte) error {
+	var format string
+	if err := json.Unmarshal(b, &format); err == nil {
+		*c = LogFormatConfig{Format: format}
+		return nil
+	}
+	type plain LogFormatConfig
+	var config plain
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&config); err != nil {
+		return err
+	}
+	*c = LogFormatConfig(config)
+	return nil
+}
+
+// LogsConfig selects the log format per app, Default applies to all others. TrustedProxies are
+// the ips or networks of proxies in front of the apps, see TrustedProxies.Client.
+type LogsConfig struct {
+	Default        LogFormatConfig            `json:"default"`
+	Apps           map[string]LogFormatConfig `json:"apps,omitempty"`
+	TrustedProxies []string                   `json:"trustedProxies,omitempty"`
+}
+
+// LogParsers holds the parser of each app.
+type LogParsers struct {
+	fallback LogParser
+	apps     map[string]LogParser
+}
+
+var defaultLogParser = resolveClients(AutoLogParser, nil)
+
+// NewLogParsers creates the parsers configured for the apps.
+func NewLogParsers(config LogsConfig) (*LogParsers, error) {
+	trusted, err := ParseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	fallback, err := NewLogParser(config.Default)
+	if err != nil {
+		return nil, fmt.Errorf("problem with the default log format, %v", err)
+	}
+	p := &LogParsers{fallback: resolveClients(fallback, trusted), apps: map[string]LogParser{}}
+	for name, c := range config.Apps {
+		parser, err := NewLogParser(c)
+		if err != nil {
+			return nil, fmt.Errorf("problem with the log format of %s, %v", name, err)
+		}
+		p.apps[strings.ToLower(name)] = resolveClients(parser, trusted)
+	}
+	return p, nil
+}
+
+// For returns the parser of an app, which resolves the clients of its logs.
+func (p *LogParsers) For(name string) LogParser {
+	if p == nil {
+		return defaultLogParser
+	}
+	if parser, ok := p.apps[name]; ok {
+		return parser
+	}
+	return p.fallback
+}
+
+var (
+	commonLogReg   = regexp.MustCompile(`^(\S+) \S+ \S+ \[([^\]]+)\] "([^"]*)" (\d{3}) (\S+)`)
+	combinedLogReg = regexp.MustCompile(`^(\S+) \S+ \S+ \[([^\]]+)\] "([^"]*)" (\d{3}) (\S+) "([^"]*)" "([^"]*)"(?: "([^"]*)")?`)
+	haproxyLogReg  = regexp.MustCompile(`(\S+):\d+ \[(\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2})(?:\.\d+)?\] \S+ \S+ (\S+) (\d{3}) (\d+) .*"([^"]*)"$`)
+)
+
+// parseCombined reads the combined log format of nginx and apache, optionally followed by the
+// X-Forwarded-For header like the default format of nginx proxies.
+func parseCombined(raw string) (AccessLog, bool) {
+	m := combinedLogReg.FindStringSubmatch(raw)
+	if m == nil {
+		return AccessLog{}, false
+	}
+	log := AccessLog{Ip: m[1], Timestamp: parseLogTime(m[2]), Status: m[4], Bytes: parseBytes(m[5]), RemoteIp: dashless(m[8])}
+	log.setRequest(m[3])
+	log.Referrer = dashless(m[6])
+	log.UserAgent = dashless(m[7])
+	return log, true
+}
+
+// parseCommon reads the common log format.
+func parseCommon(raw string) (AccessLog, bool) {
+	m := commonLogReg.FindStringSubmatch(raw)
+	if m == nil {
+		return AccessLog{}, false
+	}
+	log := AccessLog{Ip: m[1], Timestamp: parseLogTime(m[2]), Status: m[4], Bytes: parseBytes(m[5])}
+	log.setRequest(m[3])
+	return log, true
+}
+
+// parseHaproxy reads the HTTP log format of HAProxy, which logs local time without zone. The
+// latency is the total time, the last of its timers.
+func parseHaproxy(raw string) (AccessLog, bool) {
+	m := haproxyLogReg.FindStringSubmatch(raw)
+	if m == nil {
+		return AccessLog{}, false
+	}
+	log := AccessLog{Ip: strings.Trim(m[1], "[]"), Status: m[4], Bytes: parseBytes(m[5])}
+	log.setRequest(m[6])
+	if t, err := time.ParseInLocation("02/Jan/2006:15:04:05", m[2], time.Local); err == nil {
+		log.Timestamp = t.Unix()
+	}
+	timers := strings.Split(m[3], "/")
+	if total, err := strconv.ParseFloat(timers[len(timers)-1], 64); err == nil && total > 0 {
+		log.Latency = total
+	}
+	return log, true
+}
+
+// parseRegex searches the line for the parts of a log like ParseRawLog does.
+func parseRegex(raw string) (AccessLog, bool) {
+	log := findAccessLog(raw)
+	return log, log != AccessLog{}
+}
+
+// setRequest takes method, path and protocol from a request line like "GET /path HTTP/1.1".
+func (log *AccessLog) setRequest(request string) {
+	parts := strings.SplitN(request, " ", 3)
+	if len(parts) < 2 {
+		return
+	}
+	log.Method = parts[0]
+	log.Path = parts[1]
+	if len(parts) > 2 {
+		log.Protocol = parts[2]
+	}
+}
+
+func parseBytes(s string) int64 {
+	bytes, _ := strconv.ParseInt(s, 10, 64)
+	return bytes
+}
+
+// parseLogTime reads the time formats found in logs: the common log format, RFC 3339, unix
+// seconds or milliseconds, with or without fraction. It returns 0 for anything else.
+func parseLogTime(s string) int64 {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{clfTimeLayout, time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Unix()
+		}
+	}
+	if unix, err := strconv.ParseFloat(s, 64); err == nil && unix > 0 {
+		if unix > 1e12 {
+			unix /= 1000
+		}
+		return int64(unix)
+	}
+	return 0
+}
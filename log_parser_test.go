@@ -0,0 +1,164 @@
+package mond
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAutoLogParser(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  string
+		want AccessLog
+	}{
+		{
+			name: "nginx combined with forwarded for",
+			raw:  `10.129.38.1 - - [02/Jul/2021:22:50:59 +0200] "GET /futures HTTP/1.1" 200 7280 "-" "Mozilla/5.0 (X11; Linux x86_64)" "92.104.237.155"`,
+			want: AccessLog{Ip: "10.129.38.1", Timestamp: 1625259059, Method: "GET", Path: "/futures", Protocol: "HTTP/1.1", Status: "200", Bytes: 7280,
+				UserAgent: "Mozilla/5.0 (X11; Linux x86_64)", RemoteIp: "92.104.237.155"},
+		},
+		{
+			name: "apache common",
+			raw:  `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+			want: AccessLog{Ip: "127.0.0.1", Timestamp: 971211336, Method: "GET", Path: "/apache_pb.gif", Protocol: "HTTP/1.0", Status: "200", Bytes: 2326},
+		},
+		{
+			name: "apache combined with user and referer",
+			raw:  `192.168.1.20 - admin [02/Jul/2021:22:50:59 +0200] "POST /login HTTP/1.1" 302 - "https://example.com/" "curl/7.68.0"`,
+			want: AccessLog{Ip: "192.168.1.20", Timestamp: 1625259059, Method: "POST", Path: "/login", Protocol: "HTTP/1.1", Status: "302",
+				Referrer: "https://example.com/", UserAgent: "curl/7.68.0"},
+		},
+		{
+			name: "caddy json",
+			raw: `{"level":"info","ts":1625259059.123,"logger":"http.log.access","request":{"remote_ip":"10.0.0.1","proto":"HTTP/2.0","method":"GET","uri":"/futures",` +
+				`"headers":{"X-Forwarded-For":["92.104.237.155"],"User-Agent":["curl/7.68.0"]}},"duration":0.0125,"size":512,"status":200}`,
+			want: AccessLog{Ip: "10.0.0.1", Timestamp: 1625259059, Method: "GET", Path: "/futures", Protocol: "HTTP/2.0", Status: "200", Bytes: 512,
+				UserAgent: "curl/7.68.0", Latency: 12.5, RemoteIp: "92.104.237.155"},
+		},
+		{
+			name: "traefik json",
+			raw: `{"ClientHost":"10.0.0.2","DownstreamStatus":404,"DownstreamContentSize":19,"Duration":3000000,"RequestMethod":"GET","RequestPath":"/missing",` +
+				`"RequestProtocol":"HTTP/1.1","StartUTC":"2021-07-02T20:50:59.1234Z","request_X-Forwarded-For":"92.104.237.155, 10.0.0.9"}`,
+			want: AccessLog{Ip: "10.0.0.2", Timestamp: 1625259059, Method: "GET", Path: "/missing", Protocol: "HTTP/1.1", Status: "404", Bytes: 19,
+				Latency: 3, RemoteIp: "92.104.237.155, 10.0.0.9"},
+		},
+		{
+			name: "logfmt",
+			raw:  `time=2021-07-02T20:50:59Z level=info method=GET path=/futures status=500 client_ip=10.0.0.3 latency=1.5s msg="request done"`,
+			want: AccessLog{Ip: "10.0.0.3", Timestamp: 1625259059, Method: "GET", Path: "/futures", Status: "500", Latency: 1500},
+		},
+		{
+			name: "text without a format",
+			raw:  "SampleLog",
+			want: AccessLog{},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, _ := AutoLogParser.Parse(c.raw)
+			assertAccessLogEquals(t, got, c.want)
+		})
+	}
+
+	t.Run("haproxy in local time", func(t *testing.T) {
+		raw := `Jul  2 22:50:59 lb haproxy[1234]: 10.0.1.2:33317 [02/Jul/2021:22:50:59.655] http-in static/srv1 10/0/30/69/109 200 2750 - - ---- 1/1/1/1/0 0/0 "GET /futures HTTP/1.1"`
+		got, ok := AutoLogParser.Parse(raw)
+		if !ok {
+			t.Fatal("expected the line to be read")
+		}
+		want := AccessLog{Ip: "10.0.1.2", Timestamp: time.Date(2021, 7, 2, 22, 50, 59, 0, time.Local).Unix(), Method: "GET", Path: "/futures",
+			Protocol: "HTTP/1.1", Status: "200", Bytes: 2750, Latency: 109}
+		assertAccessLogEquals(t, got, want)
+	})
+}
+
+func TestNewLogParser(t *testing.T) {
+	t.Run("rejects unknown formats", func(t *testing.T) {
+		_, err := NewLogParser(LogFormatConfig{Format: "xml"})
+		if err == nil || !strings.Contains(err.Error(), "xml") {
+			t.Errorf("got error %v", err)
+		}
+	})
+
+	t.Run("detects lines not in the configured format", func(t *testing.T) {
+		parser, err := NewLogParser(LogFormatConfig{Format: LogFormatLogfmt})
+		assertNoError(t, err)
+
+		got, ok := parser.Parse(`127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /a HTTP/1.0" 200 1`)
+		if !ok {
+			t.Fatal("expected the line to be read")
+		}
+		assertAccessLogEquals(t, got, AccessLog{Ip: "127.0.0.1", Timestamp: 971211336, Method: "GET", Path: "/a", Protocol: "HTTP/1.0", Status: "200", Bytes: 1})
+	})
+
+	t.Run("creates registered formats", func(t *testing.T) {
+		RegisterLogParser("pipes", func(config LogFormatConfig) (LogParser, error) {
+			return LogParserFunc(func(raw string) (AccessLog, bool) {
+				parts := strings.Split(raw, "|")
+				if len(parts) != 2 {
+					return AccessLog{}, false
+				}
+				return AccessLog{Path: parts[0], Status: parts[1]}, true
+			}), nil
+		})
+		parser, err := NewLogParser(LogFormatConfig{Format: "pipes"})
+		assertNoError(t, err)
+
+		got, _ := parser.Parse("/a|201")
+		assertAccessLogEquals(t, got, AccessLog{Path: "/a", Status: "201"})
+	})
+}
+
+func TestLogParsers(t *testing.T) {
+	parsers, err := NewLogParsers(LogsConfig{
+		Default: LogFormatConfig{Format: LogFormatCommon},
+		Apps:    map[string]LogFormatConfig{"API": {Format: LogFormatJson, Fields: map[string]string{FieldPath: "route"}}},
+	})
+	assertNoError(t, err)
+
+	got, _ := parsers.For("api").Parse(`{"route":"/users","status":201}`)
+	assertAccessLogEquals(t, got, AccessLog{Path: "/users", Status: "201"})
+
+	got, _ = parsers.For("other").Parse(`{"route":"/users","status":201}`)
+	assertAccessLogEquals(t, got, AccessLog{Status: "201"})
+
+	t.Run("resolves the client behind trusted proxies", func(t *testing.T) {
+		parsers, err := NewLogParsers(LogsConfig{TrustedProxies: []string{"10.0.0.0/8"}})
+		assertNoError(t, err)
+
+		got, _ := parsers.For("any").Parse(`10.0.0.1 - - [02/Jul/2021:22:50:59 +0200] "GET / HTTP/1.1" 200 1 "-" "curl" "203.0.113.7, 198.51.100.1, 10.0.0.9"`)
+		if got.Ip != "10.0.0.1" || got.RemoteIp != "198.51.100.1" {
+			t.Errorf("got ip %q and remote ip %q want 10.0.0.1 and 198.51.100.1", got.Ip, got.RemoteIp)
+		}
+
+		got, _ = parsers.For("any").Parse(`{"remote_addr":"[2001:db8::9]:4711","status":200,"x_forwarded_for":"203.0.113.7"}`)
+		if got.Ip != "2001:db8::9" || got.RemoteIp != "2001:db8::9" {
+			t.Errorf("got ip %q and remote ip %q want the untrusted peer 2001:db8::9 for both", got.Ip, got.RemoteIp)
+		}
+	})
+
+	t.Run("rejects invalid trusted proxies", func(t *testing.T) {
+		if _, err := NewLogParsers(LogsConfig{TrustedProxies: []string{"10.0.0.0/40"}}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("reports the app with an invalid format", func(t *testing.T) {
+		_, err := NewLogParsers(LogsConfig{Apps: map[string]LogFormatConfig{"api": {Format: LogFormatNginx, LogFormat: "no variables"}}})
+		if err == nil || !strings.Contains(err.Error(), "api") {
+			t.Errorf("got error %v", err)
+		}
+	})
+}
+
+func TestParseLogTime(t *testing.T) {
+	for _, s := range []string{"02/Jul/2021:22:50:59 +0200", "2021-07-02T20:50:59Z", "2021-07-02T22:50:59.5+02:00", "1625259059", "1625259059.987", "1625259059123"} {
+		if got := parseLogTime(s); got != 1625259059 {
+			t.Errorf("got %d for %q want 1625259059", got, s)
+		}
+	}
+	if got := parseLogTime("yesterday"); got != 0 {
+		t.Errorf("got %d for an unknown time want 0", got)
+	}
+}
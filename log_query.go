@@ -0,0 +1,285 @@
+package mond
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultDashboardLogsLimit = 200
+
+var statusFilterReg = regexp.MustCompile(`^(\d(\d\d|xx)|\d{3}-\d{3})$`)
+
+// LogQuery filters, sorts and pages the access logs of an app. Its zero value matches everything
+// in the order the logs were recorded. Latencies are in milliseconds.
+type LogQuery struct {
+	From       int64
+	To         int64
+	Timestamp  bool
+	Captured   bool
+	Statuses   []string
+	Path       string
+	PathRegex  *regexp.Regexp
+	Ip         string
+	RemoteIp   string
+	Search     string
+	Methods    []string
+	Protocol   string
+	Referrer   string
+	UserAgent  string
+	MinBytes   int64
+	MinLatency float64
+	MaxLatency float64
+	Desc       bool
+	Limit      int
+	Cursor     int
+	hasCursor  bool
+	SourceFilter
+}
+
+// ParseLogQuery reads a LogQuery from request parameters:
+//
+//	from, to    unix seconds, RFC 3339 or 2006-01-02, both inclusive
+//	time        unix (default) filters on system time, timestamp on the time parsed from the log,
+//	            captured on the time mond-client read the line
+//	status      comma separated codes or classes like 404,5xx
+//	path        path prefix
+//	pathRegex   regular expression on the path
+//	ip          client ip
+//	remoteIp    forwarded remote ip
+//	q           case insensitive text in the raw log
+//	method      comma separated request methods like GET,POST
+//	protocol    protocol like HTTP/2.0
+//	referrer    case insensitive text in the referrer
+//	userAgent   case insensitive text in the user agent
+//	minBytes    least response size in bytes
+//	minLatency  least latency in milliseconds
+//	maxLatency  most latency in milliseconds
+//	stream      stdout or stderr of the process writing the log
+//	pid         process id
+//	host        host of the client
+//	instance    instance id of the client
+//	sort        asc (default) or desc, the order logs were recorded in
+//	limit       page size
+//	cursor      position to continue from, returned by the previous page
+func ParseLogQuery(values url.Values) (LogQuery, error) {
+	var q LogQuery
+	var err error
+
+	if q.From, err = parseQueryTime(values.Get("from")); err != nil {
+		return q, fmt.Errorf("invalid from, %v", err)
+	}
+	if q.To, err = parseQueryTime(values.Get("to")); err != nil {
+		return q, fmt.Errorf("invalid to, %v", err)
+	}
+	switch values.Get("time") {
+	case "", "unix":
+	case "timestamp":
+		q.Timestamp = true
+	case "captured":
+		q.Captured = true
+	default:
+		return q, fmt.Errorf("invalid time %q, want unix, timestamp or captured", values.Get("time"))
+	}
+
+	for _, status := range strings.Split(values.Get("status"), ",") {
+		status = strings.ToLower(strings.TrimSpace(status))
+		if status == "" {
+			continue
+		}
+		if !statusFilterReg.MatchString(status) {
+			return q, fmt.Errorf("invalid status %q, want a code like 404, a class like 5xx or a range like 500-504", status)
+		}
+		q.Statuses = append(q.Statuses, status)
+	}
+
+	q.Path = values.Get("path")
+	if pathRegex := values.Get("pathRegex"); pathRegex != "" {
+		if q.PathRegex, err = regexp.Compile(pathRegex); err != nil {
+			return q, fmt.Errorf("invalid pathRegex, %v", err)
+		}
+	}
+	q.Ip = values.Get("ip")
+	q.RemoteIp = values.Get("remoteIp")
+	q.Search = strings.ToLower(values.Get("q"))
+	for _, method := range strings.Split(values.Get("method"), ",") {
+		if method = strings.ToUpper(strings.TrimSpace(method)); method != "" {
+			q.Methods = append(q.Methods, method)
+		}
+	}
+	q.Protocol = values.Get("protocol")
+	q.Referrer = strings.ToLower(values.Get("referrer"))
+	q.UserAgent = strings.ToLower(values.Get("userAgent"))
+	if minBytes := values.Get("minBytes"); minBytes != "" {
+		if q.MinBytes, err = strconv.ParseInt(minBytes, 10, 64); err != nil {
+			return q, fmt.Errorf("invalid minBytes %q", minBytes)
+		}
+	}
+	if minLatency := values.Get("minLatency"); minLatency != "" {
+		if q.MinLatency, err = strconv.ParseFloat(minLatency, 64); err != nil {
+			return q, fmt.Errorf("invalid minLatency %q, want milliseconds", minLatency)
+		}
+	}
+	if q.SourceFilter, err = parseSourceFilter(values); err != nil {
+		return q, err
+	}
+	if maxLatency := values.Get("maxLatency"); maxLatency != "" {
+		if q.MaxLatency, err = strconv.ParseFloat(maxLatency, 64); err != nil {
+			return q, fmt.Errorf("invalid maxLatency %q, want milliseconds", maxLatency)
+		}
+	}
+
+	switch values.Get("sort") {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, fmt.Errorf("invalid sort %q, want asc or desc", values.Get("sort"))
+	}
+	if limit := values.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit < 0 {
+			return q, fmt.Errorf("invalid limit %q", limit)
+		}
+	}
+	if cursor := values.Get("cursor"); cursor != "" {
+		if q.Cursor, err = strconv.Atoi(cursor); err != nil || q.Cursor < 0 {
+			return q, fmt.Errorf("invalid cursor %q", cursor)
+		}
+		q.hasCursor = true
+	}
+	return q, nil
+}
+
+func parseQueryTime(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return unix, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.Unix(), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot parse %q as unix seconds, RFC 3339 or date", value)
+}
+
+// Matches reports whether log passes all filters of the query.
+func (q LogQuery) Matches(log AccessLog) bool {
+	t := log.Unix
+	if q.Timestamp {
+		t = log.Timestamp
+	}
+	if q.Captured {
+		t = log.Captured / 1000
+	}
+	if q.From != 0 && t < q.From {
+		return false
+	}
+	if q.To != 0 && t > q.To {
+		return false
+	}
+	if len(q.Statuses) > 0 && !matchesStatus(log.Status, q.Statuses) {
+		return false
+	}
+	if q.Path != "" && !strings.HasPrefix(log.Path, q.Path) {
+		return false
+	}
+	if q.PathRegex != nil && !q.PathRegex.MatchString(log.Path) {
+		return false
+	}
+	if q.Ip != "" && log.Ip != q.Ip {
+		return false
+	}
+	if q.RemoteIp != "" && log.RemoteIp != q.RemoteIp {
+		return false
+	}
+	if q.Search != "" && !strings.Contains(strings.ToLower(log.Raw), q.Search) {
+		return false
+	}
+	if len(q.Methods) > 0 && !containsString(q.Methods, strings.ToUpper(log.Method)) {
+		return false
+	}
+	if q.Protocol != "" && !strings.EqualFold(log.Protocol, q.Protocol) {
+		return false
+	}
+	if q.Referrer != "" && !strings.Contains(strings.ToLower(log.Referrer), q.Referrer) {
+		return false
+	}
+	if q.UserAgent != "" && !strings.Contains(strings.ToLower(log.UserAgent), q.UserAgent) {
+		return false
+	}
+	if !q.MatchesSource(log.LogSource) {
+		return false
+	}
+	if q.MinBytes != 0 && log.Bytes < q.MinBytes {
+		return false
+	}
+	if q.MinLatency != 0 && log.Latency < q.MinLatency {
+		return false
+	}
+	if q.MaxLatency != 0 && log.Latency > q.MaxLatency {
+		return false
+	}
+	return true
+}
+
+// matchesStatus compares status to codes like 404, classes like 5xx and ranges like 200-399.
+func matchesStatus(status string, wanted []string) bool {
+	for _, w := range wanted {
+		if i := strings.Index(w, "-"); i > 0 {
+			code, err := strconv.Atoi(status)
+			low, lowErr := strconv.Atoi(w[:i])
+			high, highErr := strconv.Atoi(w[i+1:])
+			if err == nil && lowErr == nil && highErr == nil && low <= code && code <= high {
+				return true
+			}
+		} else if strings.HasSuffix(w, "xx") {
+			if len(status) == 3 && status[0] == w[0] {
+				return true
+			}
+		} else if status == w {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns one page of the matching logs of app and the cursor of the next page,
+// which is empty if there are no more matches. Cursors are positions among all logs ever
+// recorded for the app, so they stay valid while new logs arrive or old ones get pruned.
+func (q LogQuery) Apply(app *App) (AccessLogs, string) {
+	logs := AccessLogs{}
+	if app == nil {
+		return logs, ""
+	}
+
+	step, i := 1, 0
+	if q.Desc {
+		step, i = -1, len(app.Logs)-1
+	}
+	if q.hasCursor {
+		i = q.Cursor - app.Pruned
+		if !q.Desc && i < 0 {
+			i = 0
+		}
+		if q.Desc && i > len(app.Logs)-1 {
+			i = len(app.Logs) - 1
+		}
+	}
+
+	for ; i >= 0 && i < len(app.Logs); i += step {
+		if !q.Matches(app.Logs[i]) {
+			continue
+		}
+		if q.Limit > 0 && len(logs) == q.Limit {
+			return logs, strconv.Itoa(app.Pruned + i)
+		}
+		logs = append(logs, app.Logs[i])
+	}
+	return logs, ""
+}
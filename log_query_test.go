@@ -0,0 +1,111 @@
+package mond
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestLogQuery(t *testing.T) {
+	app := &App{
+		Name:   "appa",
+		Pruned: 10,
+		Logs: AccessLogs{
+			{Unix: 100, Timestamp: 50, Status: "200", Path: "/api/users", Ip: "10.0.0.1", Raw: "GET /api/users",
+				Method: "GET", Protocol: "HTTP/2.0", Bytes: 2048, Latency: 12, UserAgent: "curl/7.68.0"},
+			{Unix: 200, Timestamp: 150, Status: "404", Path: "/favicon.ico", Ip: "10.0.0.2", Raw: "GET /favicon.ico",
+				Method: "GET", Protocol: "HTTP/1.1", Bytes: 0, Latency: 1, Referrer: "https://example.com/", UserAgent: "Mozilla/5.0"},
+			{Unix: 300, Timestamp: 250, Status: "500", Path: "/api/orders", Ip: "10.0.0.1", Raw: "POST /api/orders",
+				Method: "POST", Protocol: "HTTP/1.1", Bytes: 120, Latency: 800},
+			{Unix: 400, Timestamp: 350, Status: "503", Path: "/api/orders", Ip: "10.0.0.3", RemoteIp: "92.1.1.1", Raw: "POST /api/orders",
+				Method: "POST", Protocol: "HTTP/1.1", Bytes: 120, Latency: 1200,
+				LogSource: LogSource{Stream: StreamStderr, Pid: 42, Host: "web-1", Instance: "a1", Captured: 390500}},
+		},
+	}
+
+	cases := []struct {
+		name      string
+		query     string
+		wantPaths []string
+		wantNext  string
+	}{
+		{"no filters", "", []string{"/api/users", "/favicon.ico", "/api/orders", "/api/orders"}, ""},
+		{"from and to on system time", "from=200&to=300", []string{"/favicon.ico", "/api/orders"}, ""},
+		{"from on log timestamp", "from=300&time=timestamp", []string{"/api/orders"}, ""},
+		{"status class", "status=5xx", []string{"/api/orders", "/api/orders"}, ""},
+		{"status codes", "status=200,404", []string{"/api/users", "/favicon.ico"}, ""},
+		{"status range", "status=404-500", []string{"/favicon.ico", "/api/orders"}, ""},
+		{"path prefix", "path=/api", []string{"/api/users", "/api/orders", "/api/orders"}, ""},
+		{"path regex", "pathRegex=ico$", []string{"/favicon.ico"}, ""},
+		{"ip", "ip=10.0.0.1", []string{"/api/users", "/api/orders"}, ""},
+		{"remote ip", "remoteIp=92.1.1.1", []string{"/api/orders"}, ""},
+		{"search raw", "q=post", []string{"/api/orders", "/api/orders"}, ""},
+		{"methods", "method=get,delete", []string{"/api/users", "/favicon.ico"}, ""},
+		{"protocol", "protocol=http/2.0", []string{"/api/users"}, ""},
+		{"referrer", "referrer=EXAMPLE", []string{"/favicon.ico"}, ""},
+		{"user agent", "userAgent=curl", []string{"/api/users"}, ""},
+		{"min bytes", "minBytes=1000", []string{"/api/users"}, ""},
+		{"latency range", "minLatency=10&maxLatency=1000", []string{"/api/users", "/api/orders"}, ""},
+		{"source", "stream=stderr&pid=42&host=web-1&instance=a1", []string{"/api/orders"}, ""},
+		{"other instance", "instance=b2", nil, ""},
+		{"from on capture time", "from=390&time=captured", []string{"/api/orders"}, ""},
+		{"descending", "sort=desc&status=4xx,2xx", []string{"/favicon.ico", "/api/users"}, ""},
+		{"first page", "limit=2", []string{"/api/users", "/favicon.ico"}, "12"},
+		{"next page", "limit=2&cursor=12", []string{"/api/orders", "/api/orders"}, ""},
+		{"first page descending", "limit=1&sort=desc&path=/api", []string{"/api/orders"}, "12"},
+		{"next page descending", "limit=1&sort=desc&path=/api&cursor=12", []string{"/api/orders"}, "10"},
+		{"cursor of pruned logs", "cursor=3", []string{"/api/users", "/favicon.ico", "/api/orders", "/api/orders"}, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(c.query)
+			query, err := ParseLogQuery(values)
+			assertNoError(t, err)
+
+			got, next := query.Apply(app)
+
+			var gotPaths []string
+			for _, l := range got {
+				gotPaths = append(gotPaths, l.Path)
+			}
+			assertStringArray(t, gotPaths, c.wantPaths)
+			if next != c.wantNext {
+				t.Errorf("got next cursor %q want %q", next, c.wantNext)
+			}
+		})
+	}
+}
+
+func TestParseLogQuery(t *testing.T) {
+	invalid := []string{
+		"from=yesterday",
+		"status=50",
+		"status=500-5xx",
+		"pathRegex=(",
+		"sort=up",
+		"limit=-1",
+		"cursor=abc",
+		"time=local",
+		"minBytes=1k",
+		"minLatency=slow",
+		"maxLatency=1s",
+		"pid=0",
+	}
+	for _, query := range invalid {
+		t.Run(query, func(t *testing.T) {
+			values, _ := url.ParseQuery(query)
+			_, err := ParseLogQuery(values)
+			if err == nil {
+				t.Errorf("expected an error for %q", query)
+			}
+		})
+	}
+
+	t.Run("parses dates", func(t *testing.T) {
+		values, _ := url.ParseQuery("from=2021-07-02T22:50:59%2B02:00")
+		query, err := ParseLogQuery(values)
+		assertNoError(t, err)
+		if query.From != 1625259059 {
+			t.Errorf("got from %d want %d", query.From, 1625259059)
+		}
+	})
+}
@@ -0,0 +1,264 @@
+package mond
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBatchSize      = 500
+	DefaultBatchInterval  = 2 * time.Second
+	DefaultPendingBatches = 10
+	DefaultMaxRetries     = 4
+	DefaultMinBackoff     = 500 * time.Millisecond
+	DefaultMaxBackoff     = 30 * time.Second
+	DefaultMaxSpoolBytes  = 64 << 20
+)
+
+// BatchSender delivers a batch of raw log lines to url.
+type BatchSender func(url string, lines []string) error
+
+// ShipperConfig tunes a LogShipper, zero values are replaced by the defaults. MaxPending lines
+// wait in memory while batches are sent, more go to the spool.
+type ShipperConfig struct {
+	BatchSize     int
+	BatchInterval time.Duration
+	MaxPending    int
+	MaxRetries    int
+	MinBackoff    time.Duration
+	MaxBackoff    time.Duration
+	SpoolDir      string
+	MaxSpoolBytes int64
+}
+
+func (c *ShipperConfig) setDefaults() {
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultBatchSize
+	}
+	if c.BatchInterval <= 0 {
+		c.BatchInterval = DefaultBatchInterval
+	}
+	if c.MaxPending <= 0 {
+		c.MaxPending = DefaultPendingBatches * c.BatchSize
+	}
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = DefaultMaxRetries
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = DefaultMinBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DefaultMaxBackoff
+	}
+	if c.MaxSpoolBytes <= 0 {
+		c.MaxSpoolBytes = DefaultMaxSpoolBytes
+	}
+}
+
+// LogShipper collects log lines and sends them in batches once BatchSize lines are buffered or
+// BatchInterval passed. Failed batches are retried with exponential backoff and jitter and end up
+// in an on disk Spool if the server stays unreachable. Spooled batches are sent first, in order,
+// as soon as the server is back. Adding lines never waits for the server.
+type LogShipper struct {
+	url     string
+	send    BatchSender
+	config  ShipperConfig
+	spool   *Spool
+	mu      sync.Mutex
+	pending []string
+	wake    chan struct{}
+	flush   chan chan struct{}
+	quit    chan struct{}
+	done    chan struct{}
+	sleep   func(time.Duration)
+}
+
+// NewLogShipper starts a LogShipper sending to url, picking up batches spooled by earlier runs.
+func NewLogShipper(url string, send BatchSender, config ShipperConfig) (*LogShipper, error) {
+	config.setDefaults()
+	spool, err := OpenSpool(config.SpoolDir, config.MaxSpoolBytes)
+	if err != nil {
+		return nil, err
+	}
+	s := &LogShipper{
+		url:    url,
+		send:   send,
+		config: config,
+		spool:  spool,
+		wake:   make(chan struct{}, 1),
+		flush:  make(chan chan struct{}),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+		sleep:  time.Sleep,
+	}
+	go s.run()
+	return s, nil
+}
+
+// Add queues line. If MaxPending lines are waiting already, they are spooled instead.
+func (s *LogShipper) Add(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, line)
+	if len(s.pending) >= s.config.MaxPending {
+		fmt.Printf("WARN: sending falls behind, spooling %d log lines\n", len(s.pending))
+		for _, batch := range s.batches(s.pending) {
+			s.spoolBatch(batch)
+		}
+		s.pending = nil
+	}
+	if len(s.pending) >= s.config.BatchSize {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush sends everything added so far, spooling what cannot be delivered.
+func (s *LogShipper) Flush() {
+	flushed := make(chan struct{})
+	select {
+	case s.flush <- flushed:
+		<-flushed
+	case <-s.done:
+	}
+}
+
+// Close flushes all pending lines and stops the shipper. No lines may be added afterwards.
+func (s *LogShipper) Close() {
+	close(s.quit)
+	<-s.done
+}
+
+func (s *LogShipper) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.config.BatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.wake:
+			s.ship(s.take())
+		case flushed := <-s.flush:
+			s.ship(s.take())
+			close(flushed)
+		case <-ticker.C:
+			s.ship(s.take())
+		case <-s.quit:
+			s.ship(s.take())
+			return
+		}
+	}
+}
+
+// take returns the pending lines and how many batches were spooled before them. Batches spooled
+// later on hold newer lines.
+func (s *LogShipper) take() ([]string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines := s.pending
+	s.pending = nil
+	return lines, s.spool.Len()
+}
+
+// ship sends the older spooled batches and then lines, in batches of BatchSize. While the server
+// is unreachable, new batches go to the spool right away instead of being retried again.
+func (s *LogShipper) ship(lines []string, older int) {
+	reachable := s.drainSpool(older)
+	for _, batch := range s.batches(lines) {
+		if !reachable {
+			s.spoolBatch(batch)
+			continue
+		}
+		err := s.sendWithRetry(batch)
+		if err != nil {
+			fmt.Printf("WARN: spooling %d log lines, %v\n", len(batch), err)
+			s.spoolBatch(batch)
+			reachable = false
+		}
+	}
+}
+
+// batches splits lines into batches of at most BatchSize lines.
+func (s *LogShipper) batches(lines []string) [][]string {
+	var batches [][]string
+	for len(lines) > s.config.BatchSize {
+		batches = append(batches, lines[:s.config.BatchSize])
+		lines = lines[s.config.BatchSize:]
+	}
+	if len(lines) > 0 {
+		batches = append(batches, lines)
+	}
+	return batches
+}
+
+// drainSpool sends up to max spooled batches, oldest first, and reports whether the server
+// could be reached.
+func (s *LogShipper) drainSpool(max int) bool {
+	for i := 0; i < max; i++ {
+		name, lines, ok := s.spool.Oldest()
+		if !ok {
+			return true
+		}
+		err := s.send(s.url, lines)
+		if err != nil && isRetryable(err) {
+			return false
+		}
+		if err != nil {
+			fmt.Printf("WARN: dropping %d spooled log lines, %v\n", len(lines), err)
+		}
+		s.spool.Remove(name)
+	}
+	return true
+}
+
+func (s *LogShipper) spoolBatch(batch []string) {
+	if len(batch) == 0 {
+		return
+	}
+	err := s.spool.Put(batch)
+	if err != nil {
+		fmt.Printf("ERROR: losing %d log lines, %v\n", len(batch), err)
+	}
+}
+
+func (s *LogShipper) sendWithRetry(batch []string) error {
+	var err error
+	for attempt := 0; attempt < s.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			s.sleep(Backoff(attempt, s.config.MinBackoff, s.config.MaxBackoff))
+		}
+		err = s.send(s.url, batch)
+		if err == nil {
+			return nil
+		}
+		if !isRetryable(err) {
+			fmt.Printf("WARN: dropping %d log lines, %v\n", len(batch), err)
+			return nil
+		}
+	}
+	return err
+}
+
+// Backoff returns the delay before retry number attempt, doubling from min up to max.
+// Half of the delay is random, so clients which failed together do not retry together.
+func Backoff(attempt int, min, max time.Duration) time.Duration {
+	d := min
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func isRetryable(err error) bool {
+	if reportErr, ok := err.(*ReportError); ok {
+		return reportErr.Temporary()
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+package mond
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeBatchServer struct {
+	mu      sync.Mutex
+	down    bool
+	status  int
+	batches [][]string
+	calls   int
+}
+
+func (f *fakeBatchServer) send(url string, lines []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.down {
+		return fmt.Errorf("connection refused")
+	}
+	if f.status != 0 {
+		return &ReportError{StatusCode: f.status}
+	}
+	f.batches = append(f.batches, append([]string{}, lines...))
+	return nil
+}
+
+func (f *fakeBatchServer) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+func (f *fakeBatchServer) received() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.batches
+}
+
+func newTestShipper(t *testing.T, server *fakeBatchServer, config ShipperConfig) (*LogShipper, func()) {
+	t.Helper()
+	dir, cleanDir := createTempDir(t)
+	if config.SpoolDir == "" {
+		config.SpoolDir = dir
+	}
+	if config.BatchInterval == 0 {
+		config.BatchInterval = time.Hour
+	}
+	config.MinBackoff = time.Millisecond
+	config.MaxBackoff = time.Millisecond
+	shipper, err := NewLogShipper("url", server.send, config)
+	assertNoError(t, err)
+	return shipper, cleanDir
+}
+
+func TestLogShipper(t *testing.T) {
+	t.Run("sends full batches", func(t *testing.T) {
+		server := &fakeBatchServer{}
+		shipper, clean := newTestShipper(t, server, ShipperConfig{BatchSize: 2})
+		defer clean()
+
+		for _, l := range []string{"a", "b", "c"} {
+			shipper.Add(l)
+		}
+		shipper.Close()
+
+		assertBatches(t, server.received(), [][]string{{"a", "b"}, {"c"}})
+	})
+
+	t.Run("sends partial batches after the interval", func(t *testing.T) {
+		server := &fakeBatchServer{}
+		shipper, clean := newTestShipper(t, server, ShipperConfig{BatchSize: 100, BatchInterval: 10 * time.Millisecond})
+		defer clean()
+		defer shipper.Close()
+
+		shipper.Add("a")
+
+		deadline := time.Now().Add(time.Second)
+		for len(server.received()) == 0 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		assertBatches(t, server.received(), [][]string{{"a"}})
+	})
+
+	t.Run("spools while the server is down and sends in order once it is back", func(t *testing.T) {
+		server := &fakeBatchServer{down: true}
+		shipper, clean := newTestShipper(t, server, ShipperConfig{BatchSize: 1, MaxRetries: 2})
+		defer clean()
+
+		shipper.Add("a")
+		shipper.Add("b")
+		shipper.Flush()
+		if got := shipper.spool.Len(); got != 2 {
+			t.Fatalf("got %d spooled batches want 2", got)
+		}
+
+		server.setDown(false)
+		shipper.Add("c")
+		shipper.Close()
+
+		assertBatches(t, server.received(), [][]string{{"a"}, {"b"}, {"c"}})
+		if got := shipper.spool.Len(); got != 0 {
+			t.Errorf("got %d spooled batches want 0", got)
+		}
+	})
+
+	t.Run("keeps spooled batches for the next run", func(t *testing.T) {
+		dir, cleanDir := createTempDir(t)
+		defer cleanDir()
+		server := &fakeBatchServer{down: true}
+		shipper, clean := newTestShipper(t, server, ShipperConfig{SpoolDir: dir})
+		defer clean()
+		shipper.Add("a")
+		shipper.Close()
+
+		server.setDown(false)
+		shipper, clean = newTestShipper(t, server, ShipperConfig{SpoolDir: dir})
+		defer clean()
+		shipper.Close()
+
+		assertBatches(t, server.received(), [][]string{{"a"}})
+	})
+
+	t.Run("spools lines instead of waiting for a slow server and keeps their order", func(t *testing.T) {
+		server := &fakeBatchServer{}
+		sending, release := make(chan struct{}), make(chan struct{})
+		send := func(url string, lines []string) error {
+			if lines[0] == "a" {
+				close(sending)
+				<-release
+			}
+			return server.send(url, lines)
+		}
+		dir, cleanDir := createTempDir(t)
+		defer cleanDir()
+		shipper, err := NewLogShipper("url", send, ShipperConfig{BatchSize: 1, BatchInterval: time.Hour, MaxPending: 2, SpoolDir: dir})
+		assertNoError(t, err)
+
+		shipper.Add("a")
+		<-sending
+		for _, l := range []string{"b", "c", "d"} {
+			shipper.Add(l)
+		}
+		if got := shipper.spool.Len(); got != 2 {
+			t.Errorf("got %d spooled batches want 2", got)
+		}
+		close(release)
+		shipper.Close()
+
+		assertBatches(t, server.received(), [][]string{{"a"}, {"b"}, {"c"}, {"d"}})
+	})
+
+	t.Run("drops batches the server rejects for good", func(t *testing.T) {
+		server := &fakeBatchServer{status: http.StatusBadRequest}
+		shipper, clean := newTestShipper(t, server, ShipperConfig{MaxRetries: 3})
+		defer clean()
+
+		shipper.Add("a")
+		shipper.Close()
+
+		if server.calls != 1 || shipper.spool.Len() != 0 {
+			t.Errorf("got %d calls and %d spooled batches, want 1 and 0", server.calls, shipper.spool.Len())
+		}
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt, want := range []time.Duration{100, 100, 200, 400, 800, 1000, 1000} {
+		got := Backoff(attempt, 100, 1000)
+		if got < want/2 || got > want {
+			t.Errorf("got backoff %d for attempt %d, want between %d and %d", got, attempt, want/2, want)
+		}
+	}
+}
+
+func assertBatches(t testing.TB, got, want [][]string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got batches %v want %v", got, want)
+	}
+	for i := range want {
+		assertStringArray(t, got[i], want[i])
+	}
+}
@@ -0,0 +1,99 @@
+package mond
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogSource tells where mond-client read a line: the stream and pid of the process writing it,
+// the host and instance of the client and the unix time in milliseconds it was captured at.
+type LogSource struct {
+	Stream   string `json:"stream,omitempty"`
+	Pid      int    `json:"pid,omitempty"`
+	Host     string `json:"host,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Captured int64  `json:"captured,omitempty"`
+}
+
+// GetCapturedFormatted returns the capture time, or nothing if it is unknown.
+func (s *LogSource) GetCapturedFormatted() string {
+	if s.Captured == 0 {
+		return ""
+	}
+	return time.Unix(0, s.Captured*int64(time.Millisecond)).Format("02.01.2006 15:04:05.000")
+}
+
+// Describe sums up the source like "web-1/3f2a pid 42 stderr" for the dashboard.
+func (s *LogSource) Describe() string {
+	var parts []string
+	if s.Host != "" || s.Instance != "" {
+		parts = append(parts, strings.Trim(s.Host+"/"+s.Instance, "/"))
+	}
+	if s.Pid != 0 {
+		parts = append(parts, fmt.Sprintf("pid %d", s.Pid))
+	}
+	if s.Stream != "" {
+		parts = append(parts, s.Stream)
+	}
+	return strings.Join(parts, " ")
+}
+
+// NewInstanceId returns a random id telling apart the mond-clients running on one host.
+func NewInstanceId() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// captureTime returns t in unix milliseconds, the precision of LogSource.Captured.
+func captureTime(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// SourceFilter matches the sources of logs, empty fields match everything.
+type SourceFilter struct {
+	Stream   string
+	Pid      int
+	Host     string
+	Instance string
+}
+
+// parseSourceFilter reads the stream, pid, host and instance parameters.
+func parseSourceFilter(values url.Values) (SourceFilter, error) {
+	f := SourceFilter{
+		Stream:   strings.ToLower(values.Get("stream")),
+		Host:     values.Get("host"),
+		Instance: values.Get("instance"),
+	}
+	if pid := values.Get("pid"); pid != "" {
+		var err error
+		if f.Pid, err = strconv.Atoi(pid); err != nil || f.Pid <= 0 {
+			return f, fmt.Errorf("invalid pid %q", pid)
+		}
+	}
+	return f, nil
+}
+
+// MatchesSource reports whether source passes the filter.
+func (f SourceFilter) MatchesSource(source LogSource) bool {
+	if f.Stream != "" && source.Stream != f.Stream {
+		return false
+	}
+	if f.Pid != 0 && source.Pid != f.Pid {
+		return false
+	}
+	if f.Host != "" && !strings.EqualFold(source.Host, f.Host) {
+		return false
+	}
+	if f.Instance != "" && source.Instance != f.Instance {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package mond
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestLogSource(t *testing.T) {
+	t.Run("describes the source", func(t *testing.T) {
+		cases := map[string]LogSource{
+			"web-1/a1 pid 42 stderr": {Stream: StreamStderr, Pid: 42, Host: "web-1", Instance: "a1"},
+			"web-1 stdout":           {Stream: StreamStdout, Host: "web-1"},
+			"":                       {},
+		}
+		for want, source := range cases {
+			if got := source.Describe(); got != want {
+				t.Errorf("got %q want %q", got, want)
+			}
+		}
+	})
+
+	t.Run("creates distinct instance ids", func(t *testing.T) {
+		a, b := NewInstanceId(), NewInstanceId()
+		if len(a) != 12 || a == b {
+			t.Errorf("got %q and %q want two different ids of 12 characters", a, b)
+		}
+	})
+}
+
+func TestSourceFilter(t *testing.T) {
+	source := LogSource{Stream: StreamStderr, Pid: 42, Host: "Web-1", Instance: "a1"}
+	cases := map[string]bool{
+		"":                  true,
+		"stream=STDERR":     true,
+		"stream=stdout":     false,
+		"pid=42&host=web-1": true,
+		"pid=43":            false,
+		"instance=a1":       true,
+		"instance=A1":       false,
+		"host=web-2&pid=42": false,
+	}
+	for query, want := range cases {
+		values, _ := url.ParseQuery(query)
+		filter, err := parseSourceFilter(values)
+		assertNoError(t, err)
+		if got := filter.MatchesSource(source); got != want {
+			t.Errorf("got %v for %q want %v", got, query, want)
+		}
+	}
+
+	for _, query := range []string{"pid=-1", "pid=web"} {
+		values, _ := url.ParseQuery(query)
+		if _, err := parseSourceFilter(values); err == nil {
+			t.Errorf("expected an error for %q", query)
+		}
+	}
+}
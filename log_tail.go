@@ -0,0 +1,70 @@
+package mond
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const DefaultTailBuffer = 256
+
+// LogTail passes recorded access logs on to live subscribers. Publishing never blocks, a
+// subscriber which does not keep up loses logs and is told how many it missed.
+type LogTail struct {
+	mu   sync.RWMutex
+	subs map[*TailSubscription]struct{}
+}
+
+// TailSubscription receives the logs of one app which match its query.
+type TailSubscription struct {
+	App     string
+	Query   LogQuery
+	Logs    chan AccessLog
+	dropped int64
+}
+
+func NewLogTail() *LogTail {
+	return &LogTail{subs: map[*TailSubscription]struct{}{}}
+}
+
+// Subscribe starts receiving the logs of app matching query, buffering up to buffer logs.
+func (t *LogTail) Subscribe(app string, query LogQuery, buffer int) *TailSubscription {
+	if buffer <= 0 {
+		buffer = DefaultTailBuffer
+	}
+	sub := &TailSubscription{
+		App:   app,
+		Query: query,
+		Logs:  make(chan AccessLog, buffer),
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.subs[sub] = struct{}{}
+	return sub
+}
+
+func (t *LogTail) Unsubscribe(sub *TailSubscription) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.subs, sub)
+}
+
+// Publish hands log to all subscribers of app without waiting for any of them.
+func (t *LogTail) Publish(app string, log AccessLog) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for sub := range t.subs {
+		if sub.App != app || !sub.Query.Matches(log) {
+			continue
+		}
+		select {
+		case sub.Logs <- log:
+		default:
+			atomic.AddInt64(&sub.dropped, 1)
+		}
+	}
+}
+
+// Dropped returns the number of logs lost since the last call.
+func (s *TailSubscription) Dropped() int64 {
+	return atomic.SwapInt64(&s.dropped, 0)
+}
@@ -0,0 +1,174 @@
+package mond
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogTail(t *testing.T) {
+	t.Run("publishes matching logs of the app only", func(t *testing.T) {
+		tail := NewLogTail()
+		query, _ := ParseLogQuery(map[string][]string{"status": {"5xx"}})
+		sub := tail.Subscribe("appa", query, 10)
+
+		tail.Publish("appa", AccessLog{Status: "200"})
+		tail.Publish("appb", AccessLog{Status: "500"})
+		tail.Publish("appa", AccessLog{Status: "503"})
+
+		if len(sub.Logs) != 1 {
+			t.Fatalf("got %d logs want 1", len(sub.Logs))
+		}
+		assertAccessLogsEquals(t, AccessLogs{<-sub.Logs}, AccessLogs{{Status: "503"}})
+	})
+
+	t.Run("drops logs instead of blocking on slow subscribers", func(t *testing.T) {
+		tail := NewLogTail()
+		sub := tail.Subscribe("appa", LogQuery{}, 2)
+
+		for i := 0; i < 5; i++ {
+			tail.Publish("appa", AccessLog{Raw: fmt.Sprint(i)})
+		}
+
+		if len(sub.Logs) != 2 {
+			t.Errorf("got %d buffered logs want 2", len(sub.Logs))
+		}
+		if got := sub.Dropped(); got != 3 {
+			t.Errorf("got %d dropped logs want 3", got)
+		}
+		if got := sub.Dropped(); got != 0 {
+			t.Errorf("dropped logs not reset, got %d", got)
+		}
+	})
+
+	t.Run("stops publishing after unsubscribe", func(t *testing.T) {
+		tail := NewLogTail()
+		sub := tail.Subscribe("appa", LogQuery{}, 2)
+		tail.Unsubscribe(sub)
+
+		tail.Publish("appa", AccessLog{})
+
+		if len(sub.Logs) != 0 {
+			t.Errorf("got %d logs after unsubscribe", len(sub.Logs))
+		}
+	})
+}
+
+func TestStreamLogs(t *testing.T) {
+	store := &StubLogStore{AppAccessLogs: Apps{{Name: "appa", Logs: AccessLogs{{Raw: "old log"}}}}}
+	server := httptest.NewServer(NewApiServer(store, testInfo))
+	defer server.Close()
+
+	postLog := func(raw string) {
+		resp, err := http.Post(server.URL+ApiAccessLogsPath+"appa", textContentType, strings.NewReader(raw))
+		assertNoError(t, err)
+		resp.Body.Close()
+	}
+
+	t.Run("as server sent events", func(t *testing.T) {
+		resp, err := http.Get(server.URL + ApiAccessLogsPath + "appa" + ApiStreamSuffix + "?limit=1&q=new")
+		assertNoError(t, err)
+		defer resp.Body.Close()
+		if got := resp.Header.Get("content-type"); got != eventStreamContentType {
+			t.Fatalf("got content-type %q want %q", got, eventStreamContentType)
+		}
+
+		postLog("other log")
+		postLog("new log")
+
+		rdr := bufio.NewReader(resp.Body)
+		line, err := rdr.ReadString('\n')
+		assertNoError(t, err)
+		var got AccessLog
+		json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &got)
+		if got.Raw != "new log" {
+			t.Errorf("got %q want %q", got.Raw, "new log")
+		}
+	})
+
+	t.Run("over a websocket", func(t *testing.T) {
+		conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+		assertNoError(t, err)
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n"+
+			"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n", ApiAccessLogsPath+"appa"+ApiStreamSuffix+"?limit=1")
+		rdr := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(rdr, nil)
+		assertNoError(t, err)
+		assertStatus(t, resp.StatusCode, http.StatusSwitchingProtocols)
+		if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+			t.Errorf("got accept key %q", got)
+		}
+
+		got := readTextFrame(t, rdr)
+		if got.Log == nil || got.Log.Raw != "new log" {
+			t.Errorf("expected latest log as backlog, got %+v", got)
+		}
+
+		postLog("newer log")
+		got = readTextFrame(t, rdr)
+		if got.Log == nil || got.Log.Raw != "newer log" {
+			t.Errorf("expected recorded log, got %+v", got)
+		}
+	})
+
+	t.Run("sends no log of the backlog again", func(t *testing.T) {
+		apiServer := NewApiServer(&StubLogStore{}, testInfo)
+		done := make(chan struct{})
+		go func() {
+			for i := 0; i < 100; i++ {
+				apiServer.recordAccessLog("appb", AccessLog{Raw: fmt.Sprint(i)})
+			}
+			close(done)
+		}()
+		sub, backlog := apiServer.subscribeLogs("appb", LogQuery{Limit: 1000})
+		<-done
+
+		seen := map[string]int{}
+		for _, l := range backlog {
+			seen[l.Raw]++
+		}
+		for len(sub.Logs) > 0 {
+			seen[(<-sub.Logs).Raw]++
+		}
+		for i := 0; i < 100; i++ {
+			if n := seen[fmt.Sprint(i)]; n != 1 {
+				t.Fatalf("got log %d %d times want once", i, n)
+			}
+		}
+	})
+}
+
+func readTextFrame(t testing.TB, rdr *bufio.Reader) tailMessage {
+	t.Helper()
+	var head [2]byte
+	_, err := io.ReadFull(rdr, head[:])
+	assertNoError(t, err)
+	if head[0]&0x0F != wsOpText {
+		t.Fatalf("got opcode %d want text", head[0]&0x0F)
+	}
+	n := int(head[1] & 0x7F)
+	if n == 126 {
+		var ext [2]byte
+		io.ReadFull(rdr, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, n)
+	_, err = io.ReadFull(rdr, payload)
+	assertNoError(t, err)
+
+	var m tailMessage
+	err = json.Unmarshal(payload, &m)
+	assertNoError(t, err)
+	return m
+}
@@ -0,0 +1,204 @@
+package mond
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMultilineMaxLines   = 500
+	DefaultMultilineFlushAfter = time.Second
+)
+
+// DefaultContinuation matches indented lines like the frames of a stack trace, the causes of
+// Java exceptions and the exception closing a Python traceback.
+const DefaultContinuation = `^(\s+\S|Caused by: |Suppressed: |[\w.$]+(Exception|Error)(: |$))`
+
+// crashStartReg matches the start of a Go crash, which takes every line after it.
+var crashStartReg = regexp.MustCompile(`^(panic: |fatal error: )`)
+
+// MultilineConfig sets how lines are grouped into entries. If Start is set, every line not
+// matching it continues the entry before. Otherwise lines matching Continuation do, which
+// defaults to DefaultContinuation. Entries end after MaxLines lines or once no line was added
+// for FlushAfter.
+type MultilineConfig struct {
+	Start        string   `json:"start"`
+	Continuation string   `json:"continuation"`
+	MaxLines     int      `json:"maxLines"`
+	FlushAfter   Duration `json:"flushAfter"`
+}
+
+// LineGrouper groups the lines of each stream into AppLog entries, so that a stack trace ends up
+// in the entry of the line it belongs to.
+type LineGrouper struct {
+	start        *regexp.Regexp
+	continuation *regexp.Regexp
+	maxLines     int
+	flushAfter   time.Duration
+	emit         func(AppLog)
+	now          func() time.Time
+
+	mu       sync.Mutex
+	emitting sync.Mutex
+	pending  map[string]*pendingEntry
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+type pendingEntry struct {
+	source LogSource
+	lines  []string
+	size   int
+	last   time.Time
+	crash  bool
+}
+
+// NewLineGrouper starts a LineGrouper handing every complete entry to emit.
+func NewLineGrouper(config MultilineConfig, emit func(AppLog)) (*LineGrouper, error) {
+	g := &LineGrouper{
+		maxLines:   config.MaxLines,
+		flushAfter: time.Duration(config.FlushAfter),
+		emit:       emit,
+		now:        time.Now,
+		pending:    map[string]*pendingEntry{},
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	if g.maxLines <= 0 {
+		g.maxLines = DefaultMultilineMaxLines
+	}
+	if g.flushAfter <= 0 {
+		g.flushAfter = DefaultMultilineFlushAfter
+	}
+	var err error
+	if config.Start != "" {
+		if g.start, err = regexp.Compile(config.Start); err != nil {
+			return nil, fmt.Errorf("invalid multiline start, %v", err)
+		}
+	} else {
+		continuation := config.Continuation
+		if continuation == "" {
+			continuation = DefaultContinuation
+		}
+		if g.continuation, err = regexp.Compile(continuation); err != nil {
+			return nil, fmt.Errorf("invalid multiline continuation, %v", err)
+		}
+	}
+	go g.run()
+	return g, nil
+}
+
+// Add appends line to the entry pending for the stream of source or starts a new entry with it.
+// The entry keeps the source of its first line. Empty lines only ever continue an entry.
+func (g *LineGrouper) Add(source LogSource, line string) {
+	g.mu.Lock()
+	var complete []AppLog
+	defer func() { g.emitAll(complete) }()
+	now := g.now()
+	stream := source.Stream
+	entry := g.pending[stream]
+	if entry != nil && (entry.source.Pid != source.Pid || !g.continues(entry, line)) {
+		complete = append(complete, g.takeEntry(stream))
+		entry = nil
+	}
+	if entry == nil {
+		if strings.TrimSpace(line) == "" {
+			return
+		}
+		entry = &pendingEntry{source: source, crash: crashStartReg.MatchString(line)}
+		g.pending[stream] = entry
+	}
+	entry.lines = append(entry.lines, line)
+	entry.size += len(line) + 1
+	entry.last = now
+	if len(entry.lines) >= g.maxLines || entry.size >= MaxRawLogLength {
+		complete = append(complete, g.takeEntry(stream))
+	}
+}
+
+func (g *LineGrouper) continues(entry *pendingEntry, line string) bool {
+	switch {
+	case entry.crash || strings.TrimSpace(line) == "":
+		return true
+	case g.start != nil:
+		return !g.start.MatchString(line)
+	}
+	return g.continuation.MatchString(line)
+}
+
+// Flush emits the pending entries of all streams.
+func (g *LineGrouper) Flush() {
+	g.mu.Lock()
+	var complete []AppLog
+	for stream := range g.pending {
+		complete = append(complete, g.takeEntry(stream))
+	}
+	g.emitAll(complete)
+}
+
+// Close stops the LineGrouper and emits the pending entries. No lines may be added afterwards.
+func (g *LineGrouper) Close() {
+	close(g.done)
+	<-g.stopped
+	g.Flush()
+}
+
+func (g *LineGrouper) run() {
+	defer close(g.stopped)
+	ticker := time.NewTicker(g.flushAfter / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.flushIdle()
+		case <-g.done:
+			return
+		}
+	}
+}
+
+// flushIdle emits the entries which got no line for flushAfter.
+func (g *LineGrouper) flushIdle() {
+	g.mu.Lock()
+	var complete []AppLog
+	now := g.now()
+	for stream, entry := range g.pending {
+		if now.Sub(entry.last) >= g.flushAfter {
+			complete = append(complete, g.takeEntry(stream))
+		}
+	}
+	g.emitAll(complete)
+}
+
+// emitAll unlocks mu and hands entries to emit. Entries are emitted in the order they were taken,
+// as emitting is locked before mu is unlocked.
+func (g *LineGrouper) emitAll(entries []AppLog) {
+	if len(entries) == 0 {
+		g.mu.Unlock()
+		return
+	}
+	g.emitting.Lock()
+	defer g.emitting.Unlock()
+	g.mu.Unlock()
+	for _, entry := range entries {
+		g.emit(entry)
+	}
+}
+
+// takeEntry removes the entry pending for stream and returns it as AppLog.
+func (g *LineGrouper) takeEntry(stream string) AppLog {
+	entry := g.pending[stream]
+	delete(g.pending, stream)
+	lines := entry.lines
+	for len(lines) > 1 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	raw := strings.Join(lines, "\n")
+	if len(raw) > MaxRawLogLength {
+		raw = raw[:MaxRawLogLength]
+	}
+	return AppLog{Raw: raw, LogSource: entry.source}
+}
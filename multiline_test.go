@@ -0,0 +1,174 @@
+package mond
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type appLogRecorder struct {
+	mu   sync.Mutex
+	logs []AppLog
+}
+
+func (r *appLogRecorder) emit(log AppLog) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = append(r.logs, log)
+}
+
+func (r *appLogRecorder) raws() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var raws []string
+	for _, log := range r.logs {
+		raws = append(raws, log.Raw)
+	}
+	return raws
+}
+
+func newTestGrouper(t *testing.T, config MultilineConfig) (*LineGrouper, *appLogRecorder) {
+	t.Helper()
+	config.FlushAfter = Duration(time.Hour)
+	recorder := &appLogRecorder{}
+	grouper, err := NewLineGrouper(config, recorder.emit)
+	assertNoError(t, err)
+	return grouper, recorder
+}
+
+func TestLineGrouper(t *testing.T) {
+	t.Run("groups indented lines and causes with the line before", func(t *testing.T) {
+		grouper, recorder := newTestGrouper(t, MultilineConfig{})
+		for _, line := range []string{
+			"INFO started",
+			"ERROR request failed",
+			"java.lang.IllegalStateException: boom",
+			"\tat com.example.App.main(App.java:5)",
+			"Caused by: java.io.IOException: closed",
+			"\t... 3 more",
+			"INFO done",
+		} {
+			grouper.Add(LogSource{Stream: StreamStdout}, line)
+		}
+		grouper.Close()
+
+		assertStringArray(t, recorder.raws(), []string{
+			"INFO started",
+			"ERROR request failed\njava.lang.IllegalStateException: boom\n\tat com.example.App.main(App.java:5)\nCaused by: java.io.IOException: closed\n\t... 3 more",
+			"INFO done",
+		})
+	})
+
+	t.Run("keeps the streams apart", func(t *testing.T) {
+		grouper, recorder := newTestGrouper(t, MultilineConfig{})
+		grouper.Add(LogSource{Stream: StreamStderr}, "ERROR failed")
+		grouper.Add(LogSource{Stream: StreamStdout}, "INFO served")
+		grouper.Add(LogSource{Stream: StreamStderr}, "  at main")
+		grouper.Close()
+
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		if len(recorder.logs) != 2 {
+			t.Fatalf("got %v want 2 entries", recorder.logs)
+		}
+		for _, log := range recorder.logs {
+			if log.Stream == StreamStderr && log.Raw != "ERROR failed\n  at main" || log.Stream == StreamStdout && log.Raw != "INFO served" {
+				t.Errorf("got %+v", log)
+			}
+		}
+	})
+
+	t.Run("takes every line after a go panic", func(t *testing.T) {
+		grouper, recorder := newTestGrouper(t, MultilineConfig{})
+		for _, line := range []string{"panic: boom", "", "goroutine 1 [running]:", "main.main()", "\t/app/main.go:5 +0x1d", "exit status 2", ""} {
+			grouper.Add(LogSource{Stream: StreamStderr}, line)
+		}
+		grouper.Close()
+
+		assertStringArray(t, recorder.raws(), []string{"panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:5 +0x1d\nexit status 2"})
+	})
+
+	t.Run("starts entries only with lines matching start", func(t *testing.T) {
+		grouper, recorder := newTestGrouper(t, MultilineConfig{Start: `^\d{4}-\d\d-\d\d `})
+		for _, line := range []string{"2021-07-02 a", "b", "c", "2021-07-02 d"} {
+			grouper.Add(LogSource{Stream: StreamStdout}, line)
+		}
+		grouper.Close()
+
+		assertStringArray(t, recorder.raws(), []string{"2021-07-02 a\nb\nc", "2021-07-02 d"})
+	})
+
+	t.Run("continues lines matching the continuation", func(t *testing.T) {
+		grouper, recorder := newTestGrouper(t, MultilineConfig{Continuation: `^\|`, MaxLines: 2})
+		for _, line := range []string{"a", "| b", "| c", "  d"} {
+			grouper.Add(LogSource{Stream: StreamStdout}, line)
+		}
+		grouper.Close()
+
+		assertStringArray(t, recorder.raws(), []string{"a\n| b", "| c", "  d"})
+	})
+
+	t.Run("emits entries which got no line for a while", func(t *testing.T) {
+		grouper, recorder := newTestGrouper(t, MultilineConfig{})
+		defer grouper.Close()
+		now := time.Unix(100, 0)
+		grouper.now = func() time.Time { return now }
+
+		grouper.Add(LogSource{Stream: StreamStdout, Pid: 7, Captured: 100000}, "ERROR failed")
+		grouper.Add(LogSource{Stream: StreamStdout, Pid: 7, Captured: 100001}, "  at main")
+		grouper.flushIdle()
+		if len(recorder.raws()) != 0 {
+			t.Fatalf("got %v want nothing yet", recorder.raws())
+		}
+
+		now = now.Add(time.Hour)
+		grouper.flushIdle()
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		want := LogSource{Stream: StreamStdout, Pid: 7, Captured: 100000}
+		if len(recorder.logs) != 1 || recorder.logs[0].LogSource != want {
+			t.Errorf("got %+v want the entry with the source of its first line", recorder.logs)
+		}
+	})
+
+	t.Run("starts a new entry for a restarted process", func(t *testing.T) {
+		grouper, recorder := newTestGrouper(t, MultilineConfig{})
+		grouper.Add(LogSource{Stream: StreamStderr, Pid: 1}, "panic: boom")
+		grouper.Add(LogSource{Stream: StreamStderr, Pid: 2}, "INFO started")
+		grouper.Close()
+
+		assertStringArray(t, recorder.raws(), []string{"panic: boom", "INFO started"})
+	})
+
+	t.Run("takes lines while an entry is emitted", func(t *testing.T) {
+		emitting, release := make(chan struct{}), make(chan struct{})
+		grouper, err := NewLineGrouper(MultilineConfig{FlushAfter: Duration(time.Hour)}, func(AppLog) {
+			close(emitting)
+			<-release
+		})
+		assertNoError(t, err)
+		grouper.Add(LogSource{Stream: StreamStdout}, "INFO one")
+		go grouper.Add(LogSource{Stream: StreamStdout}, "INFO two")
+		<-emitting
+
+		added := make(chan struct{})
+		go func() {
+			grouper.Add(LogSource{Stream: StreamStderr}, "ERROR failed")
+			close(added)
+		}()
+		select {
+		case <-added:
+		case <-time.After(time.Second):
+			t.Error("adding a line waited for emit")
+		}
+		close(release)
+	})
+
+	t.Run("rejects invalid expressions", func(t *testing.T) {
+		for _, config := range []MultilineConfig{{Start: "("}, {Continuation: "["}} {
+			if _, err := NewLineGrouper(config, func(AppLog) {}); err == nil {
+				t.Errorf("expected an error for %+v", config)
+			}
+		}
+	})
+}
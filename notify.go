@@ -0,0 +1,530 @@
+package mond
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Channel types registered by default.
+const (
+	NotifierWebhook = "webhook"
+	NotifierSlack   = "slack"
+	NotifierEmail   = "email"
+)
+
+// Kinds of Notification.
+const (
+	NotifyAlert  = "alert"
+	NotifyHealth = "health"
+)
+
+const DefaultNotifyRetries = 3
+const DefaultNotifyRetryDelay = Duration(10 * time.Second)
+const maxNotifyRetryDelay = 5 * time.Minute
+
+// MaxDeliveries is how many deliveries are kept for the dashboard.
+const MaxDeliveries = 100
+
+const DefaultNotifyTemplate = "[{{.State}}] {{.App}}: {{.Message}}"
+const DefaultEmailSubject = "[mond] {{.App}} {{.State}}"
+
+// SignatureHeader carries the hex HMAC-SHA256 of the body of a webhook, keyed with its secret.
+const SignatureHeader = "X-Mond-Signature"
+
+// NotifyConfig declares where notifications are sent. A delivery which fails is tried again up to
+// Retries times, -1 for never, waiting from RetryDelay on, twice as long each time.
+type NotifyConfig struct {
+	Retries    int             `json:"retries,omitempty"`
+	RetryDelay Duration        `json:"retryDelay,omitempty"`
+	Channels   []ChannelConfig `json:"channels,omitempty"`
+}
+
+func (c NotifyConfig) IsEnabled() bool {
+	return len(c.Channels) > 0
+}
+
+// ChannelConfig configures a channel of the given Type, it gets the notifications of Apps or of
+// all apps if there are none. Template renders the text of a notification. Url is where webhook
+// and slack channels post to, the email settings are used by email channels.
+type ChannelConfig struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Apps     []string `json:"apps,omitempty"`
+	Template string   `json:"template,omitempty"`
+	Url      string   `json:"url,omitempty"`
+	Secret   string   `json:"secret,omitempty"`
+	SmtpAddr string   `json:"smtpAddr,omitempty"`
+	User     string   `json:"user,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+	Subject  string   `json:"subject,omitempty"`
+}
+
+// Notification tells about an alert which started firing or got resolved, or about an app going
+// DOWN or coming back UP. State is the state of the alert or the new health status, Target the
+// target whose report changed it.
+type Notification struct {
+	Kind      string `json:"kind"`
+	App       string `json:"app"`
+	Target    string `json:"target,omitempty"`
+	Rule      string `json:"rule,omitempty"`
+	State     string `json:"state"`
+	Message   string `json:"message"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Notifier delivers notifications to a channel.
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// NotifierFactory creates the Notifier of a channel.
+type NotifierFactory func(config ChannelConfig) (Notifier, error)
+
+var (
+	notifiersMu sync.RWMutex
+	notifiers   = map[string]NotifierFactory{
+		NotifierWebhook: newWebhookNotifier,
+		NotifierSlack:   newSlackNotifier,
+		NotifierEmail:   newEmailNotifier,
+	}
+)
+
+// RegisterNotifier makes factory create the channels of type name, replacing an earlier one.
+func RegisterNotifier(name string, factory NotifierFactory) {
+	notifiersMu.Lock()
+	defer notifiersMu.Unlock()
+	notifiers[name] = factory
+}
+
+// NewNotifier creates the Notifier of a channel.
+func NewNotifier(config ChannelConfig) (Notifier, error) {
+	notifiersMu.RLock()
+	factory, ok := notifiers[config.Type]
+	notifiersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("channel %s has unknown type %q", config.Name, config.Type)
+	}
+	return factory(config)
+}
+
+func parseNotifyTemplate(name, text, fallback string) (*template.Template, error) {
+	if text == "" {
+		text = fallback
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("problem parsing template of channel %s, %v", name, err)
+	}
+	return tmpl, nil
+}
+
+func render(tmpl *template.Template, n Notification) (string, error) {
+	var b strings.Builder
+	err := tmpl.Execute(&b, n)
+	if err != nil {
+		return "", fmt.Errorf("problem rendering notification, %v", err)
+	}
+	return b.String(), nil
+}
+
+// WebhookNotifier posts a notification as JSON together with its rendered Text. With a Secret the
+// body is signed in the SignatureHeader.
+type WebhookNotifier struct {
+	Url    string
+	Secret string
+	text   *template.Template
+}
+
+type webhookPayload struct {
+	Notification
+	Text string `json:"text"`
+}
+
+func newWebhookNotifier(config ChannelConfig) (Notifier, error) {
+	if config.Url == "" {
+		return nil, fmt.Errorf("channel %s needs a url", config.Name)
+	}
+	text, err := parseNotifyTemplate(config.Name, config.Template, DefaultNotifyTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookNotifier{Url: config.Url, Secret: config.Secret, text: text}, nil
+}
+
+func (w *WebhookNotifier) Notify(n Notification) error {
+	text, err := render(w.text, n)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(webhookPayload{Notification: n, Text: text})
+	if err != nil {
+		return fmt.Errorf("problem encoding notification, %v", err)
+	}
+	header := http.Header{}
+	if w.Secret != "" {
+		header.Set(SignatureHeader, "sha256="+Sign(w.Secret, body))
+	}
+	return postNotification(w.Url, header, body)
+}
+
+// Sign returns the hex HMAC-SHA256 of body keyed with secret, as sent by webhooks.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SlackNotifier posts the rendered text to an incoming webhook of Slack or Mattermost.
+type SlackNotifier struct {
+	Url  string
+	text *template.Template
+}
+
+func newSlackNotifier(config ChannelConfig) (Notifier, error) {
+	if config.Url == "" {
+		return nil, fmt.Errorf("channel %s needs a url", config.Name)
+	}
+	text, err := parseNotifyTemplate(config.Name, config.Template, DefaultNotifyTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &SlackNotifier{Url: config.Url, text: text}, nil
+}
+
+func (s *SlackNotifier) Notify(n Notification) error {
+	text, err := render(s.text, n)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return fmt.Errorf("problem encoding notification, %v", err)
+	}
+	return postNotification(s.Url, http.Header{}, body)
+}
+
+func postNotification(url string, header http.Header, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("problem creating request, %v", err)
+	}
+	req.Header = header
+	req.Header.Set("content-type", jsonContentType)
+	client := http.Client{Timeout: time.Duration(DefaultCheckTimeout)}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not notify: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("got response code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// EmailNotifier sends the rendered text as plain text mail through an SMTP server. It logs in
+// only if a User is set.
+type EmailNotifier struct {
+	Addr     string
+	User     string
+	Password string
+	From     string
+	To       []string
+	subject  *template.Template
+	text     *template.Template
+}
+
+func newEmailNotifier(config ChannelConfig) (Notifier, error) {
+	if config.SmtpAddr == "" || config.From == "" || len(config.To) == 0 {
+		return nil, fmt.Errorf("channel %s needs a smtpAddr, from and to", config.Name)
+	}
+	subject, err := parseNotifyTemplate(config.Name, config.Subject, DefaultEmailSubject)
+	if err != nil {
+		return nil, err
+	}
+	text, err := parseNotifyTemplate(config.Name, config.Template, DefaultNotifyTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &EmailNotifier{
+		Addr:     config.SmtpAddr,
+		User:     config.User,
+		Password: config.Password,
+		From:     config.From,
+		To:       config.To,
+		subject:  subject,
+		text:     text,
+	}, nil
+}
+
+func (e *EmailNotifier) Notify(n Notification) error {
+	subject, err := render(e.subject, n)
+	if err != nil {
+		return err
+	}
+	text, err := render(e.text, n)
+	if err != nil {
+		return err
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.NewReplacer("\r", "", "\n", " ").Replace(subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Unix(n.Timestamp, 0).Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(text, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	var auth smtp.Auth
+	if e.User != "" {
+		host := e.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", e.User, e.Password, host)
+	}
+	err = smtp.SendMail(e.Addr, auth, e.From, e.To, []byte(msg.String()))
+	if err != nil {
+		return fmt.Errorf("could not send mail: %v", err)
+	}
+	return nil
+}
+
+// Delivery is the outcome of sending a notification to a channel. A notification suppressed by a
+// silence is not sent and names the silence in SilencedBy.
+type Delivery struct {
+	Channel      string `json:"channel"`
+	Notification `json:"notification"`
+	Attempts     int    `json:"attempts"`
+	Error        string `json:"error,omitempty"`
+	Delivered    int64  `json:"delivered,omitempty"`
+	SilencedBy   string `json:"silencedBy,omitempty"`
+}
+
+func (d Delivery) GetFormattedTime() string {
+	return time.Unix(d.Notification.Timestamp, 0).Format("02.01.2006 15:04:05")
+}
+
+type notifyChannel struct {
+	name     string
+	apps     []string
+	notifier Notifier
+}
+
+func (c notifyChannel) routes(app string) bool {
+	if len(c.apps) == 0 {
+		return true
+	}
+	for _, a := range c.apps {
+		if strings.EqualFold(a, app) {
+			return true
+		}
+	}
+	return false
+}
+
+// Dispatcher sends notifications to the channels routed to their app and keeps a log of the
+// latest deliveries.
+type Dispatcher struct {
+	channels   []notifyChannel
+	retries    int
+	retryDelay time.Duration
+	after      func(time.Duration) <-chan time.Time
+	silences   *Silences
+	wg         sync.WaitGroup
+
+	mu         sync.Mutex
+	deliveries []Delivery
+}
+
+// NewDispatcher creates the notifiers of all channels.
+func NewDispatcher(config NotifyConfig) (*Dispatcher, error) {
+	d := &Dispatcher{
+		retries:    config.Retries,
+		retryDelay: time.Duration(config.RetryDelay),
+		after:      time.After,
+	}
+	if d.retries == 0 {
+		d.retries = DefaultNotifyRetries
+	}
+	if d.retries < 0 {
+		d.retries = 0
+	}
+	if d.retryDelay <= 0 {
+		d.retryDelay = time.Duration(DefaultNotifyRetryDelay)
+	}
+	for _, c := range config.Channels {
+		if c.Name == "" {
+			return nil, fmt.Errorf("notification channel of type %q has no name", c.Type)
+		}
+		notifier, err := NewNotifier(c)
+		if err != nil {
+			return nil, err
+		}
+		d.channels = append(d.channels, notifyChannel{name: c.Name, apps: c.Apps, notifier: notifier})
+	}
+	return d, nil
+}
+
+// SetSilences suppresses the notifications matching one of the silences.
+func (d *Dispatcher) SetSilences(silences *Silences) {
+	d.silences = silences
+}
+
+// Notify sends n to every channel routed to its app in the background, unless it is silenced.
+func (d *Dispatcher) Notify(n Notification) {
+	for _, c := range d.channels {
+		if !c.routes(n.App) {
+			continue
+		}
+		if d.silences != nil {
+			if silence, ok := d.silences.Silencing(n, time.Now()); ok {
+				d.logDelivery(Delivery{Channel: c.name, Notification: n, SilencedBy: silence.Id})
+				continue
+			}
+		}
+		d.wg.Add(1)
+		go func(c notifyChannel) {
+			defer d.wg.Done()
+			d.deliver(c, n)
+		}(c)
+	}
+}
+
+// NotifyAlert tells about an alert which started firing or got resolved.
+func (d *Dispatcher) NotifyAlert(alert Alert) {
+	timestamp := alert.FiredAt
+	if alert.State == AlertResolved {
+		timestamp = alert.ResolvedAt
+	}
+	d.Notify(Notification{Kind: NotifyAlert, App: alert.App, Rule: alert.Rule, State: alert.State, Message: alert.Message, Timestamp: timestamp})
+}
+
+// NotifyHealth tells about an app going DOWN or STALE and coming back UP after that, other changes
+// of the health are left out.
+func (d *Dispatcher) NotifyHealth(app string, from, to HealthCheck) {
+	down := isHealthFailing(to) && !isHealthFailing(from) && from.Status != ""
+	recovered := to.Status == "UP" && isHealthFailing(from)
+	if !down && !recovered {
+		return
+	}
+	message := fmt.Sprintf("%s is %s, was %s", app, to.Status, from.Status)
+	if to.Reason != "" {
+		message += ": " + to.Reason
+	}
+	d.Notify(Notification{Kind: NotifyHealth, App: app, Target: to.Target, State: to.Status, Message: message, Timestamp: time.Now().Unix()})
+}
+
+// isHealthFailing reports whether the app is DOWN or did not report its health in time.
+func isHealthFailing(check HealthCheck) bool {
+	return check.Status == "DOWN" || check.Status == HealthStale
+}
+
+// Wait blocks until all notifications are delivered or given up.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// Deliveries returns the latest deliveries, the most recent last.
+func (d *Dispatcher) Deliveries() []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Delivery{}, d.deliveries...)
+}
+
+func (d *Dispatcher) deliver(c notifyChannel, n Notification) {
+	delivery := Delivery{Channel: c.name, Notification: n}
+	for {
+		delivery.Attempts++
+		err := c.notifier.Notify(n)
+		if err == nil {
+			delivery.Error = ""
+			delivery.Delivered = time.Now().Unix()
+			break
+		}
+		delivery.Error = err.Error()
+		if delivery.Attempts > d.retries {
+			fmt.Printf("WARN: could not notify %s about %s, %v\n", c.name, n.App, err)
+			break
+		}
+		<-d.after(Backoff(delivery.Attempts, d.retryDelay, maxNotifyRetryDelay))
+	}
+	d.logDelivery(delivery)
+}
+
+func (d *Dispatcher) logDelivery(delivery Delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deliveries = append(d.deliveries, delivery)
+	if len(d.deliveries) > MaxDeliveries {
+		d.deliveries = append([]Delivery{}, d.deliveries[len(d.deliveries)-MaxDeliveries:]...)
+	}
+}
+
+// healthWatcher reports changes of the health status recorded in the store it wraps. Calls are
+// serialized, so that concurrent reports see each other's status.
+type healthWatcher struct {
+	AccessLogStore
+	changed func(name string, from, to HealthCheck)
+	mu      sync.Mutex
+}
+
+// WatchHealth returns store, calling changed whenever a health report or going stale changes the
+// status of an app.
+// The new health carries the target and the reason of the report. The first report of an app is
+// not a change.
+func WatchHealth(store AccessLogStore, changed func(name string, from, to HealthCheck)) AccessLogStore {
+	return &healthWatcher{AccessLogStore: store, changed: changed}
+}
+
+func (w *healthWatcher) RecordHealth(name string, check HealthCheck) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	from, reported := w.currentHealth(name)
+	w.AccessLogStore.RecordHealth(name, check)
+	to := w.AccessLogStore.GetHealth(name)
+	to.Target = check.Target
+	if to.Reason == "" {
+		to.Reason = check.Reason
+	}
+	if reported && from.Status != to.Status {
+		w.changed(name, from, to)
+	}
+}
+
+func (w *healthWatcher) MarkHealthStale(name string, at int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	from, reported := w.currentHealth(name)
+	w.AccessLogStore.MarkHealthStale(name, at)
+	to := w.AccessLogStore.GetHealth(name)
+	if reported && from.Status != to.Status {
+		w.changed(name, from, to)
+	}
+}
+
+// currentHealth returns the health of the app and whether it ever reported one, as stores hand
+// out a placeholder for unknown apps.
+func (w *healthWatcher) currentHealth(name string) (HealthCheck, bool) {
+	app := w.AccessLogStore.GetApp(name)
+	if app == nil {
+		return HealthCheck{}, false
+	}
+	health := app.CurrentHealth()
+	return health, health.Status != ""
+}
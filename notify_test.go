@@ -0,0 +1,348 @@
+package mond
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNotifiers(t *testing.T) {
+	n := Notification{Kind: NotifyAlert, App: "appa", Rule: "down", State: AlertFiring, Message: "appa is DOWN", Timestamp: 1600000000}
+
+	t.Run("webhook posts the signed notification", func(t *testing.T) {
+		var body []byte
+		var signature string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ = ioutil.ReadAll(r.Body)
+			signature = r.Header.Get(SignatureHeader)
+		}))
+		defer server.Close()
+
+		notifier, err := NewNotifier(ChannelConfig{Name: "hook", Type: NotifierWebhook, Url: server.URL, Secret: "s3cret", Template: "{{.Rule}} {{.State}}"})
+		assertNoError(t, err)
+		assertNoError(t, notifier.Notify(n))
+
+		if signature != "sha256="+Sign("s3cret", body) {
+			t.Errorf("got signature %q for %s", signature, body)
+		}
+		var got webhookPayload
+		json.Unmarshal(body, &got)
+		if got.Notification != n || got.Text != "down firing" {
+			t.Errorf("got %+v want %+v with text", got, n)
+		}
+	})
+
+	t.Run("slack posts the rendered text", func(t *testing.T) {
+		var got map[string]string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&got)
+		}))
+		defer server.Close()
+
+		notifier, err := NewNotifier(ChannelConfig{Name: "chat", Type: NotifierSlack, Url: server.URL})
+		assertNoError(t, err)
+		assertNoError(t, notifier.Notify(n))
+
+		if got["text"] != "[firing] appa: appa is DOWN" {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("fails on error responses", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		notifier, _ := NewNotifier(ChannelConfig{Name: "chat", Type: NotifierSlack, Url: server.URL})
+		if err := notifier.Notify(n); err == nil || !strings.Contains(err.Error(), "502") {
+			t.Errorf("got %v want an error with the response code", err)
+		}
+	})
+
+	t.Run("email sends a mail through smtp", func(t *testing.T) {
+		smtpServer := newSmtpStandIn(t)
+		defer smtpServer.Close()
+
+		notifier, err := NewNotifier(ChannelConfig{Name: "mail", Type: NotifierEmail, SmtpAddr: smtpServer.Addr(), From: "mond@example.com", To: []string{"ops@example.com", "dev@example.com"}})
+		assertNoError(t, err)
+		assertNoError(t, notifier.Notify(n))
+
+		mail := smtpServer.Mail()
+		for _, want := range []string{"MAIL FROM:<mond@example.com>", "RCPT TO:<ops@example.com>", "RCPT TO:<dev@example.com>", "Subject: [mond] appa firing", "[firing] appa: appa is DOWN"} {
+			if !strings.Contains(mail, want) {
+				t.Errorf("expected %q in %s", want, mail)
+			}
+		}
+	})
+
+	t.Run("rejects incomplete channels", func(t *testing.T) {
+		cases := []struct {
+			config ChannelConfig
+			err    string
+		}{
+			{ChannelConfig{Name: "x", Type: "pager"}, "unknown type"},
+			{ChannelConfig{Name: "x", Type: NotifierWebhook}, "needs a url"},
+			{ChannelConfig{Name: "x", Type: NotifierEmail, SmtpAddr: "localhost:25"}, "needs a smtpAddr, from and to"},
+			{ChannelConfig{Name: "x", Type: NotifierSlack, Url: "http://x", Template: "{{.Nope"}, "problem parsing template"},
+		}
+		for _, c := range cases {
+			_, err := NewNotifier(c.config)
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("got %v want %q", err, c.err)
+			}
+		}
+	})
+}
+
+func TestDispatcher(t *testing.T) {
+	t.Run("routes notifications by app and logs the deliveries", func(t *testing.T) {
+		all, appa := &stubNotifier{}, &stubNotifier{}
+		RegisterNotifier("stub-all", func(ChannelConfig) (Notifier, error) { return all, nil })
+		RegisterNotifier("stub-appa", func(ChannelConfig) (Notifier, error) { return appa, nil })
+		dispatcher, err := NewDispatcher(NotifyConfig{Channels: []ChannelConfig{
+			{Name: "all", Type: "stub-all"},
+			{Name: "appa", Type: "stub-appa", Apps: []string{"AppA"}},
+		}})
+		assertNoError(t, err)
+
+		dispatcher.NotifyAlert(Alert{Rule: "down", App: "appa", State: AlertFiring, FiredAt: 10})
+		dispatcher.NotifyAlert(Alert{Rule: "down", App: "appb", State: AlertResolved, ResolvedAt: 20})
+		dispatcher.Wait()
+
+		if len(all.received()) != 2 || len(appa.received()) != 1 || appa.received()[0].App != "appa" {
+			t.Errorf("got %v for all and %v for appa", all.received(), appa.received())
+		}
+		deliveries := dispatcher.Deliveries()
+		if len(deliveries) != 3 {
+			t.Fatalf("got deliveries %v want 3", deliveries)
+		}
+		for _, d := range deliveries {
+			if d.Attempts != 1 || d.Delivered == 0 || d.Error != "" {
+				t.Errorf("got %+v want a delivery at the first attempt", d)
+			}
+		}
+	})
+
+	t.Run("retries failed deliveries", func(t *testing.T) {
+		flaky := &stubNotifier{failures: 2}
+		broken := &stubNotifier{failures: 100}
+		RegisterNotifier("stub-flaky", func(ChannelConfig) (Notifier, error) { return flaky, nil })
+		RegisterNotifier("stub-broken", func(ChannelConfig) (Notifier, error) { return broken, nil })
+		dispatcher, err := NewDispatcher(NotifyConfig{Retries: 2, Channels: []ChannelConfig{
+			{Name: "flaky", Type: "stub-flaky"},
+			{Name: "broken", Type: "stub-broken"},
+		}})
+		assertNoError(t, err)
+		dispatcher.after = immediately
+
+		dispatcher.Notify(Notification{App: "appa", State: "DOWN"})
+		dispatcher.Wait()
+
+		deliveries := dispatcher.Deliveries()
+		if len(deliveries) != 2 {
+			t.Fatalf("got deliveries %v want 2", deliveries)
+		}
+		for _, d := range deliveries {
+			switch d.Channel {
+			case "flaky":
+				if d.Attempts != 3 || d.Delivered == 0 {
+					t.Errorf("got %+v want delivered at the third attempt", d)
+				}
+			case "broken":
+				if d.Attempts != 3 || d.Delivered != 0 || d.Error != "unavailable" {
+					t.Errorf("got %+v want given up after 3 attempts", d)
+				}
+			}
+		}
+	})
+
+	t.Run("logs silenced notifications without sending them", func(t *testing.T) {
+		stub := &stubNotifier{}
+		RegisterNotifier("stub-silenced", func(ChannelConfig) (Notifier, error) { return stub, nil })
+		dispatcher, _ := NewDispatcher(NotifyConfig{Channels: []ChannelConfig{{Name: "ops", Type: "stub-silenced"}}})
+		silences, _ := LoadSilences("")
+		silence, _ := silences.Add(Silence{App: "appa", Target: "api", End: time.Now().Unix() + 60}, time.Now())
+		dispatcher.SetSilences(silences)
+
+		dispatcher.Notify(Notification{Kind: NotifyHealth, App: "appa", Target: "api", State: "DOWN"})
+		dispatcher.Notify(Notification{Kind: NotifyHealth, App: "appa", Target: "db", State: "DOWN"})
+		dispatcher.Wait()
+
+		if got := stub.received(); len(got) != 1 || got[0].Target != "db" {
+			t.Errorf("got %v want only the notification about db", got)
+		}
+		var silenced []Delivery
+		for _, d := range dispatcher.Deliveries() {
+			if d.SilencedBy != "" {
+				silenced = append(silenced, d)
+			}
+		}
+		if len(silenced) != 1 || silenced[0].SilencedBy != silence.Id || silenced[0].Attempts != 0 {
+			t.Errorf("got silenced deliveries %+v want the one about api", silenced)
+		}
+	})
+
+	t.Run("tells about apps going down and coming back", func(t *testing.T) {
+		stub := &stubNotifier{}
+		RegisterNotifier("stub-health", func(ChannelConfig) (Notifier, error) { return stub, nil })
+		dispatcher, _ := NewDispatcher(NotifyConfig{Channels: []ChannelConfig{{Name: "health", Type: "stub-health"}}})
+		store := WatchHealth(&StubLogStore{}, dispatcher.NotifyHealth)
+
+		store.RecordHealth("appa", HealthCheck{Status: "UP"})
+		store.RecordHealth("appa", HealthCheck{Status: "UP"})
+		store.RecordHealth("appa", HealthCheck{Status: "DOWN", Reason: "connection refused"})
+		store.RecordHealth("appa", HealthCheck{Status: "DOWN"})
+		store.RecordHealth("appa", HealthCheck{Status: "UP"})
+		store.RecordHealth("appa", HealthCheck{Status: HealthDegraded})
+		dispatcher.Wait()
+
+		got := stub.received()
+		if len(got) != 2 {
+			t.Fatalf("got %v want a DOWN and an UP notification", got)
+		}
+		sort.Slice(got, func(i, j int) bool { return got[i].State < got[j].State })
+		if got[0].Kind != NotifyHealth || got[0].State != "DOWN" || got[0].Message != "appa is DOWN, was UP: connection refused" {
+			t.Errorf("got %+v", got[0])
+		}
+		if got[1].State != "UP" || got[1].Message != "appa is UP, was DOWN" {
+			t.Errorf("got %+v want UP", got[1])
+		}
+	})
+
+	t.Run("tells about apps going stale and reporting again", func(t *testing.T) {
+		stub := &stubNotifier{}
+		RegisterNotifier("stub-stale", func(ChannelConfig) (Notifier, error) { return stub, nil })
+		dispatcher, _ := NewDispatcher(NotifyConfig{Channels: []ChannelConfig{{Name: "stale", Type: "stub-stale"}}})
+		store := WatchHealth(&StubLogStore{}, dispatcher.NotifyHealth)
+
+		store.RecordHealth("appa", HealthCheck{Status: "UP"})
+		store.MarkHealthStale("appa", 42)
+		store.RecordHealth("appa", HealthCheck{Status: "UP"})
+		dispatcher.Wait()
+
+		got := stub.received()
+		if len(got) != 2 {
+			t.Fatalf("got %v want a STALE and an UP notification", got)
+		}
+		sort.Slice(got, func(i, j int) bool { return got[i].State < got[j].State })
+		if got[0].State != HealthStale || got[0].Message != "appa is STALE, was UP" {
+			t.Errorf("got %+v want STALE", got[0])
+		}
+		if got[1].State != "UP" || got[1].Message != "appa is UP, was STALE" {
+			t.Errorf("got %+v want UP", got[1])
+		}
+	})
+
+	t.Run("does not tell about the first report of an app", func(t *testing.T) {
+		database, clean := createTempFile(t, "[]")
+		defer clean()
+		fileStore, err := NewFileSystemAppsStore(database)
+		assertNoError(t, err)
+		var changes []string
+		store := WatchHealth(fileStore, func(name string, from, to HealthCheck) {
+			changes = append(changes, from.Status+"->"+to.Status)
+		})
+
+		store.RecordHealth("brandnew", HealthCheck{Status: "UP"})
+		store.RecordHealth("brandnew", HealthCheck{Status: "DOWN"})
+
+		assertStringArray(t, changes, []string{"UP->DOWN"})
+	})
+}
+
+type stubNotifier struct {
+	mu            sync.Mutex
+	failures      int
+	notifications []Notification
+}
+
+func (s *stubNotifier) Notify(n Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errUnavailable
+	}
+	s.notifications = append(s.notifications, n)
+	return nil
+}
+
+func (s *stubNotifier) received() []Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Notification{}, s.notifications...)
+}
+
+var errUnavailable = errors.New("unavailable")
+
+// smtpStandIn accepts a single mail like an SMTP server would and keeps the whole conversation.
+type smtpStandIn struct {
+	listener net.Listener
+	done     chan struct{}
+	mail     strings.Builder
+}
+
+func newSmtpStandIn(t testing.TB) *smtpStandIn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assertNoError(t, err)
+	s := &smtpStandIn{listener: listener, done: make(chan struct{})}
+	go s.serve()
+	return s
+}
+
+func (s *smtpStandIn) serve() {
+	defer close(s.done)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ready")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		s.mail.WriteString(line + "\n")
+		switch {
+		case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+			text.PrintfLine("250 localhost")
+		case line == "DATA":
+			text.PrintfLine("354 go ahead")
+			data, _ := ioutil.ReadAll(text.DotReader())
+			s.mail.Write(data)
+			text.PrintfLine("250 queued")
+		case line == "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+}
+
+func (s *smtpStandIn) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Mail waits for the conversation to end and returns it.
+func (s *smtpStandIn) Mail() string {
+	<-s.done
+	return s.mail.String()
+}
+
+func (s *smtpStandIn) Close() {
+	s.listener.Close()
+}
@@ -0,0 +1,88 @@
+package mond
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultProbingInterval = Duration(time.Minute)
+
+// ProbingConfig lists the targets the apiserver checks itself, by the name of the app they
+// belong to. Their health is recorded like the reports of mond-client, so apps which do not run
+// the client can be monitored, too. Interval applies to targets which do not set their own.
+type ProbingConfig struct {
+	Interval Duration                  `json:"interval,omitempty"`
+	Apps     map[string][]HealthTarget `json:"apps,omitempty"`
+}
+
+func (c ProbingConfig) IsEnabled() bool {
+	return len(c.Apps) > 0
+}
+
+// lowerAppNames keys the targets by lower case app names, the names the API records health under.
+func (c *ProbingConfig) lowerAppNames() {
+	apps := make(map[string][]HealthTarget, len(c.Apps))
+	for name, targets := range c.Apps {
+		lower := strings.ToLower(name)
+		apps[lower] = append(apps[lower], targets...)
+	}
+	c.Apps = apps
+}
+
+// IntervalOf returns how often target is probed.
+func (c ProbingConfig) IntervalOf(target HealthTarget) time.Duration {
+	if target.Interval > 0 {
+		return time.Duration(target.Interval)
+	}
+	if c.Interval > 0 {
+		return time.Duration(c.Interval)
+	}
+	return time.Duration(DefaultProbingInterval)
+}
+
+// Probe checks target and records its health for the app.
+func Probe(store AccessLogStore, app string, target HealthTarget) HealthCheck {
+	check := target.checkNamed()
+	store.RecordHealth(app, check)
+	return check
+}
+
+// StartProbing probes every target right away and then once per its interval, until the
+// returned func is called, which waits for running probes. Each target is probed on its own, so
+// a slow one delays nobody else.
+func StartProbing(store AccessLogStore, config ProbingConfig) func() {
+	quit := make(chan struct{})
+	var wg sync.WaitGroup
+	for app, targets := range config.Apps {
+		for _, target := range targets {
+			if target.Url == "" && target.Command == "" {
+				fmt.Printf("WARN: skipping probe of %s without url or command\n", app)
+				continue
+			}
+			wg.Add(1)
+			go func(app string, target HealthTarget) {
+				defer wg.Done()
+				probeEvery(store, app, target, config.IntervalOf(target), quit)
+			}(app, target)
+		}
+	}
+	return func() {
+		close(quit)
+		wg.Wait()
+	}
+}
+
+func probeEvery(store AccessLogStore, app string, target HealthTarget, interval time.Duration, quit chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		Probe(store, app, target)
+		select {
+		case <-ticker.C:
+		case <-quit:
+			return
+		}
+	}
+}
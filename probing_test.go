@@ -0,0 +1,98 @@
+package mond
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProbing(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	t.Run("records the health of the target for the app", func(t *testing.T) {
+		store := &StubLogStore{}
+
+		Probe(store, "site", HealthTarget{Name: "home", Url: up.URL})
+		check := Probe(store, "site", HealthTarget{Name: "api", Url: down.URL})
+
+		if check.Target != "api" || check.Url != down.URL || check.Status != "DOWN" {
+			t.Errorf("got %+v want api DOWN", check)
+		}
+		if got := store.GetHealth("site").Status; got != HealthDegraded {
+			t.Errorf("got %s want %s", got, HealthDegraded)
+		}
+		if targets := store.GetApp("site").Targets; len(targets) != 2 {
+			t.Errorf("got targets %+v want home and api", targets)
+		}
+	})
+
+	t.Run("reports unnamed command targets under their command", func(t *testing.T) {
+		store := &StubLogStore{}
+
+		Probe(store, "worker", HealthTarget{Name: "home", Url: up.URL})
+		check := Probe(store, "worker", HealthTarget{CommandCheck: CommandCheck{Command: "sh -c 'exit 0'"}})
+
+		if check.Target != "sh -c 'exit 0'" {
+			t.Errorf("got target %q want the command", check.Target)
+		}
+		if targets := store.GetApp("worker").Targets; len(targets) != 2 {
+			t.Errorf("got targets %+v want home and the command", targets)
+		}
+	})
+
+	t.Run("probes each target right away and then per its interval", func(t *testing.T) {
+		store := &StubLogStore{}
+		config := ProbingConfig{
+			Interval: Duration(time.Hour),
+			Apps: map[string][]HealthTarget{
+				"fast": {{Name: "home", Url: up.URL, Interval: Duration(10 * time.Millisecond)}},
+				"slow": {{Name: "home", Url: down.URL}},
+			},
+		}
+
+		stop := StartProbing(store, config)
+		time.Sleep(100 * time.Millisecond)
+		stop()
+
+		if count := healthReports(store.GetApp("fast")); count < 3 {
+			t.Errorf("got %d reports for fast want at least 3", count)
+		}
+		if count := healthReports(store.GetApp("slow")); count != 1 {
+			t.Errorf("got %d reports for slow want 1", count)
+		}
+		if got := store.GetHealth("slow").Status; got != "DOWN" {
+			t.Errorf("got %s want DOWN", got)
+		}
+	})
+
+	t.Run("falls back to the default interval", func(t *testing.T) {
+		cases := []struct {
+			config ProbingConfig
+			target HealthTarget
+			want   time.Duration
+		}{
+			{ProbingConfig{}, HealthTarget{}, time.Minute},
+			{ProbingConfig{Interval: Duration(time.Hour)}, HealthTarget{}, time.Hour},
+			{ProbingConfig{Interval: Duration(time.Hour)}, HealthTarget{Interval: Duration(time.Second)}, time.Second},
+		}
+		for _, c := range cases {
+			if got := c.config.IntervalOf(c.target); got != c.want {
+				t.Errorf("got %v want %v for %+v", got, c.want, c.target)
+			}
+		}
+	})
+}
+
+func healthReports(app *App) int {
+	count := 0
+	for _, r := range app.HealthHistory {
+		count += r.Count
+	}
+	return count
+}
@@ -0,0 +1,95 @@
+package mond
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Types of a ProcessEvent.
+const (
+	ProcessStarted = "start"
+	ProcessExited  = "exit"
+	ProcessCrashed = "crash"
+)
+
+// MaxProcessEvents is the number of process events kept per app.
+const MaxProcessEvents = 50
+
+// ProcessEvent is reported by mond-client whenever the process it supervises starts or ends.
+type ProcessEvent struct {
+	Type      string `json:"type"`
+	Timestamp int64  `json:"timestamp"`
+	Pid       int    `json:"pid,omitempty"`
+	ExitCode  int    `json:"exitCode"`
+	Signal    string `json:"signal,omitempty"`
+	Uptime    int64  `json:"uptime,omitempty"`
+	Restarts  int    `json:"restarts"`
+	Message   string `json:"message,omitempty"`
+}
+
+func NewProcessEvent(rdr io.Reader) (*ProcessEvent, error) {
+	event := new(ProcessEvent)
+	err := json.NewDecoder(rdr).Decode(&event)
+
+	if err != nil {
+		err = fmt.Errorf("problem parsing process event, %v", err)
+	}
+
+	return event, err
+}
+
+func ReportProcessEvent(reporter WebsiteHealthReporter, url string, event ProcessEvent) (int, error) {
+	eventJson, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("cannot marshal %v", event)
+	}
+	reportResponse, err := reporter(url, string(eventJson))
+	if err != nil {
+		return 0, err
+	}
+	if reportResponse.Body != nil {
+		reportResponse.Body.Close()
+	}
+
+	return reportResponse.StatusCode, nil
+}
+
+func (e *ProcessEvent) GetFormattedTime() string {
+	return time.Unix(e.Timestamp, 0).Format("02.01.2006 15:04:05")
+}
+
+// Describe sums up the event for the dashboard.
+func (e *ProcessEvent) Describe() string {
+	switch e.Type {
+	case ProcessStarted:
+		return fmt.Sprintf("started pid %d, %d restarts", e.Pid, e.Restarts)
+	case ProcessExited:
+		return fmt.Sprintf("exited after %s", time.Duration(e.Uptime)*time.Second)
+	}
+	reason := fmt.Sprintf("exit code %d", e.ExitCode)
+	if e.Signal != "" {
+		reason = "signal " + e.Signal
+	}
+	if e.Message != "" {
+		reason += ", " + e.Message
+	}
+	return fmt.Sprintf("crashed after %s, %s", time.Duration(e.Uptime)*time.Second, reason)
+}
+
+// addEvent appends event, keeping only the latest MaxProcessEvents.
+func (a *App) addEvent(event ProcessEvent) {
+	a.Events = append(a.Events, event)
+	if len(a.Events) > MaxProcessEvents {
+		a.Events = a.Events[len(a.Events)-MaxProcessEvents:]
+	}
+}
+
+// LastEvent returns the latest process event, nil if there is none.
+func (a *App) LastEvent() *ProcessEvent {
+	if len(a.Events) == 0 {
+		return nil
+	}
+	return &a.Events[len(a.Events)-1]
+}
@@ -0,0 +1,128 @@
+package mond
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const DefaultRetentionInterval = Duration(10 * time.Minute)
+
+// RetentionPolicy limits the access logs kept per app, zero values mean unlimited.
+// Logs are kept in the order they arrived, so retention always drops the oldest ones.
+type RetentionPolicy struct {
+	MaxAge   Duration `json:"maxAge,omitempty"`
+	MaxCount int      `json:"maxCount,omitempty"`
+	MaxBytes int64    `json:"maxBytes,omitempty"`
+}
+
+// RetentionConfig holds a default RetentionPolicy and per app overrides of it.
+type RetentionConfig struct {
+	Interval Duration                   `json:"interval,omitempty"`
+	Default  RetentionPolicy            `json:"default"`
+	Apps     map[string]RetentionPolicy `json:"apps,omitempty"`
+}
+
+// lowerAppNames keys the policies by lower case app names, the names the API records logs under.
+func (c *RetentionConfig) lowerAppNames() {
+	apps := make(map[string]RetentionPolicy, len(c.Apps))
+	for name, policy := range c.Apps {
+		apps[strings.ToLower(name)] = policy
+	}
+	c.Apps = apps
+}
+
+// PolicyFor returns the policy of an app, limits not set for the app fall back to the default.
+func (c RetentionConfig) PolicyFor(name string) RetentionPolicy {
+	policy := c.Default
+	override, ok := c.Apps[name]
+	if !ok {
+		return policy
+	}
+	if override.MaxAge != 0 {
+		policy.MaxAge = override.MaxAge
+	}
+	if override.MaxCount != 0 {
+		policy.MaxCount = override.MaxCount
+	}
+	if override.MaxBytes != 0 {
+		policy.MaxBytes = override.MaxBytes
+	}
+	return policy
+}
+
+func (c RetentionConfig) IsEnabled() bool {
+	if c.Default != (RetentionPolicy{}) {
+		return true
+	}
+	for _, p := range c.Apps {
+		if p != (RetentionPolicy{}) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prunable returns how many of the oldest logs exceed the policy.
+func (p RetentionPolicy) Prunable(logs AccessLogs, now time.Time) int {
+	n := 0
+	if p.MaxAge > 0 {
+		cutoff := now.Add(-time.Duration(p.MaxAge)).Unix()
+		for n < len(logs) && logs[n].Unix < cutoff {
+			n++
+		}
+	}
+	if p.MaxCount > 0 && len(logs)-n > p.MaxCount {
+		n = len(logs) - p.MaxCount
+	}
+	if p.MaxBytes > 0 {
+		var size int64
+		for i := len(logs) - 1; i >= n; i-- {
+			size += logs[i].size()
+			if size > p.MaxBytes {
+				n = i + 1
+				break
+			}
+		}
+	}
+	return n
+}
+
+// EnforceRetention prunes the logs of all apps in store and returns the number of pruned logs per app.
+func EnforceRetention(store AccessLogStore, config RetentionConfig, now time.Time) map[string]int {
+	pruned := map[string]int{}
+	for _, app := range store.GetApps() {
+		n := config.PolicyFor(app.Name).Prunable(app.Logs, now)
+		if n > 0 {
+			store.PruneAccessLogs(app.Name, n)
+			pruned[app.Name] = n
+		}
+	}
+	return pruned
+}
+
+// StartRetention enforces the retention config every interval until the returned func is called.
+func StartRetention(store AccessLogStore, config RetentionConfig) func() {
+	interval := config.Interval
+	if interval <= 0 {
+		interval = DefaultRetentionInterval
+	}
+	ticker := time.NewTicker(time.Duration(interval))
+	quit := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case now := <-ticker.C:
+				for name, n := range EnforceRetention(store, config, now) {
+					fmt.Printf("pruned %d logs of %s\n", n, name)
+				}
+			case <-quit:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+	}
+}
@@ -0,0 +1,71 @@
+package mond
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetentionPolicy(t *testing.T) {
+	now := time.Unix(10000, 0)
+	logs := AccessLogs{
+		{Unix: 100, Raw: "a"},
+		{Unix: 200, Raw: "b"},
+		{Unix: 9000, Raw: "c"},
+		{Unix: 9500, Raw: "d"},
+	}
+
+	cases := []struct {
+		name   string
+		policy RetentionPolicy
+		want   int
+	}{
+		{"no limits", RetentionPolicy{}, 0},
+		{"max age", RetentionPolicy{MaxAge: Duration(time.Hour)}, 2},
+		{"max count", RetentionPolicy{MaxCount: 1}, 3},
+		{"max bytes", RetentionPolicy{MaxBytes: logs[0].size() * 2}, 2},
+		{"strictest limit wins", RetentionPolicy{MaxAge: Duration(time.Hour), MaxCount: 3}, 2},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := c.policy.Prunable(logs, now)
+			if got != c.want {
+				t.Errorf("got %d prunable logs want %d", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRetentionConfig(t *testing.T) {
+	config := RetentionConfig{
+		Default: RetentionPolicy{MaxAge: Duration(time.Hour), MaxCount: 10},
+		Apps: map[string]RetentionPolicy{
+			"appa": {MaxCount: 2},
+		},
+	}
+
+	t.Run("app overrides fall back to the default", func(t *testing.T) {
+		got := config.PolicyFor("appa")
+		want := RetentionPolicy{MaxAge: Duration(time.Hour), MaxCount: 2}
+		if got != want {
+			t.Errorf("got %v want %v", got, want)
+		}
+	})
+
+	t.Run("enforces policies on the store", func(t *testing.T) {
+		now := time.Now()
+		store := StubLogStore{AppAccessLogs: Apps{
+			{Name: "appa", Logs: AccessLogs{{Unix: now.Unix()}, {Unix: now.Unix()}, {Unix: now.Unix()}}},
+			{Name: "appb", Logs: AccessLogs{{Unix: now.Unix() - 7200}, {Unix: now.Unix()}}},
+		}}
+
+		pruned := EnforceRetention(&store, config, now)
+
+		if pruned["appa"] != 1 || pruned["appb"] != 1 {
+			t.Errorf("got pruned %v want one log per app", pruned)
+		}
+		app := store.GetApp("appa")
+		if len(app.Logs) != 2 || app.Pruned != 1 {
+			t.Errorf("got %d logs and %d pruned, want 2 and 1", len(app.Logs), app.Pruned)
+		}
+	})
+}
@@ -0,0 +1,649 @@
+package mond
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultMaxSegmentBytes = 8 << 20
+
+const segmentAppDirSuffix = ".app"
+const segmentFileSuffix = ".seg"
+
+// SegmentedAppsStore is an AccessLogStore that appends every record to per app segment files
+// instead of rewriting the whole database. Segments are rotated once they reach maxSegmentBytes
+// and are replayed on startup to rebuild the in memory index. Like FileSystemAppsStore it is
+// safe for concurrent use and hands out snapshots.
+type SegmentedAppsStore struct {
+	mu              sync.RWMutex
+	dir             string
+	maxSegmentBytes int64
+	syncWrites      bool
+	apps            Apps
+	segments        map[string]*segmentWriter
+}
+
+// segmentRecord is a single line of a segment file, exactly one of its pointers is set.
+type segmentRecord struct {
+	Segment *segmentHeader `json:"segment,omitempty"`
+	Log     *AccessLog     `json:"log,omitempty"`
+	Health  *HealthCheck   `json:"health,omitempty"`
+	Prune   *segmentPrune  `json:"prune,omitempty"`
+	Event   *ProcessEvent  `json:"event,omitempty"`
+	Stale   *segmentStale  `json:"stale,omitempty"`
+	AppLog  *AppLog        `json:"appLog,omitempty"`
+	// Received is the server time a health record arrived.
+	Received int64 `json:"received,omitempty"`
+}
+
+// segmentStale marks the app stale from At on.
+type segmentStale struct {
+	At int64 `json:"at"`
+}
+
+// segmentHeader starts every segment and makes it readable without its predecessors.
+// Base is the position of the first log of the segment among all logs ever recorded for the app.
+// A Compacted segment holds all logs which are not pruned and replaces the segments before it.
+type segmentHeader struct {
+	Base      int  `json:"base"`
+	App       App  `json:"app"`
+	Compacted bool `json:"compacted,omitempty"`
+}
+
+// segmentPrune drops all logs positioned before Before.
+type segmentPrune struct {
+	Before int `json:"before"`
+}
+
+// segmentWriter appends to the active segment of an app and remembers the base of every
+// segment still on disk, the active one being the last.
+type segmentWriter struct {
+	dir   string
+	seq   int
+	size  int64
+	file  *os.File
+	sync  bool
+	bases []segmentBase
+}
+
+type segmentBase struct {
+	seq  int
+	base int
+}
+
+// NewSegmentedAppsStore creates a SegmentedAppsStore in dir, replaying all existing segments.
+// If syncWrites is set every append is flushed to disk before it is acknowledged.
+func NewSegmentedAppsStore(dir string, maxSegmentBytes int64, syncWrites bool) (*SegmentedAppsStore, error) {
+	if maxSegmentBytes <= 0 {
+		maxSegmentBytes = DefaultMaxSegmentBytes
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("problem creating segment dir %s, %v", dir, err)
+	}
+
+	s := &SegmentedAppsStore{
+		dir:             dir,
+		maxSegmentBytes: maxSegmentBytes,
+		syncWrites:      syncWrites,
+		segments:        map[string]*segmentWriter{},
+	}
+	err = s.load()
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// SegmentedAppsStoreFromDir creates a SegmentedAppsStore with the default segment size.
+func SegmentedAppsStoreFromDir(dir string, syncWrites bool) (*SegmentedAppsStore, func(), error) {
+	store, err := NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, syncWrites)
+	if err != nil {
+		return nil, nil, fmt.Errorf("problem creating segmented apps store, %v ", err)
+	}
+	closeFunc := func() {
+		store.Close()
+	}
+	return store, closeFunc, nil
+}
+
+// Close closes all open segment files.
+func (s *SegmentedAppsStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var firstErr error
+	for _, w := range s.segments {
+		if err := w.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	s.segments = map[string]*segmentWriter{}
+	return firstErr
+}
+
+func (s *SegmentedAppsStore) load() error {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("problem reading segment dir %s, %v", s.dir, err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasSuffix(e.Name(), segmentAppDirSuffix) {
+			continue
+		}
+		name, err := url.PathUnescape(strings.TrimSuffix(e.Name(), segmentAppDirSuffix))
+		if err != nil {
+			fmt.Printf("skipping segment dir %q, %v\n", e.Name(), err)
+			continue
+		}
+		app, w, err := loadAppSegments(filepath.Join(s.dir, e.Name()), name)
+		if err != nil {
+			return err
+		}
+		w.sync = s.syncWrites
+		s.apps = append(s.apps, app)
+		s.segments[name] = w
+	}
+	return nil
+}
+
+func loadAppSegments(dir, name string) (App, *segmentWriter, error) {
+	app := App{Name: name}
+	seqs, err := listSegments(dir)
+	if err != nil {
+		return app, nil, err
+	}
+	if len(seqs) == 0 {
+		w, err := createSegment(dir, 1, segmentHeader{App: app}, false)
+		return app, w, err
+	}
+
+	var bases []segmentBase
+	for _, seq := range seqs {
+		base, err := replaySegment(segmentPath(dir, seq), &app)
+		if err != nil {
+			return app, nil, err
+		}
+		bases = append(bases, segmentBase{seq: seq, base: base})
+	}
+	if app.upgradeLogs() {
+		w, err := compactSegments(dir, &app, seqs)
+		return app, w, err
+	}
+
+	last := seqs[len(seqs)-1]
+	file, err := os.OpenFile(segmentPath(dir, last), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return app, nil, fmt.Errorf("problem opening segment %s, %v", segmentPath(dir, last), err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return app, nil, fmt.Errorf("problem getting file info from segment %s, %v", file.Name(), err)
+	}
+	return app, &segmentWriter{dir: dir, seq: last, size: info.Size(), file: file, bases: bases}, nil
+}
+
+// compactSegments replaces the segments of app by a single one holding its current state and
+// logs. It is written next to them and renamed into place before they are removed, as it replaces
+// them a crash in between loses nothing.
+func compactSegments(dir string, app *App, seqs []int) (*segmentWriter, error) {
+	w, err := writeCompactedSegment(dir, seqs[len(seqs)-1]+1, app)
+	if err != nil {
+		return nil, fmt.Errorf("problem compacting segments of %s, %v", app.Name, err)
+	}
+	for _, old := range seqs {
+		err := os.Remove(segmentPath(dir, old))
+		if err != nil {
+			fmt.Printf("problem removing compacted segment, %v\n", err)
+		}
+	}
+	return w, nil
+}
+
+// writeCompactedSegment writes the state and logs of app to segment seq at once and opens it for
+// appending.
+func writeCompactedSegment(dir string, seq int, app *App) (*segmentWriter, error) {
+	header := newSegmentHeader(app)
+	header.Base = app.Pruned
+	header.Compacted = true
+	records := []segmentRecord{{Segment: &header}}
+	for i := range app.Logs {
+		records = append(records, segmentRecord{Log: &app.Logs[i]})
+	}
+	buf, err := encodeRecords(records)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("problem creating segment dir %s, %v", dir, err)
+	}
+	path := segmentPath(dir, seq)
+	removeStaleTapeFiles(path)
+	_, err = (&tape{path: path, sync: true}).Write(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("problem opening segment %s, %v", path, err)
+	}
+	return &segmentWriter{dir: dir, seq: seq, size: int64(len(buf)), file: file, bases: []segmentBase{{seq: seq, base: header.Base}}}, nil
+}
+
+// ImportAppsDBFile fills an empty store with the apps of the FileSystemAppsStore database at path,
+// so switching to segments keeps everything recorded so far. A store which already has apps and a
+// missing database are left alone, a failed import is undone. It returns the number of apps imported.
+func (s *SegmentedAppsStore) ImportAppsDBFile(path string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.apps) > 0 {
+		return 0, nil
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("problem opening %s, %v", path, err)
+	}
+	defer file.Close()
+	apps, err := NewApps(file)
+	if err != nil {
+		return 0, fmt.Errorf("problem importing %s, %v", path, err)
+	}
+
+	for i := range apps {
+		app := &apps[i]
+		app.upgradeLogs()
+		w, err := writeCompactedSegment(segmentAppDir(s.dir, app.Name), 1, app)
+		if err != nil {
+			s.dropImported()
+			return 0, fmt.Errorf("problem importing %s, %v", app.Name, err)
+		}
+		w.sync = s.syncWrites
+		s.apps = append(s.apps, *app)
+		s.segments[app.Name] = w
+	}
+	return len(apps), nil
+}
+
+// dropImported removes the apps of a failed import, so it starts over with an empty store.
+func (s *SegmentedAppsStore) dropImported() {
+	for _, app := range s.apps {
+		if w := s.segments[app.Name]; w != nil {
+			w.file.Close()
+		}
+		os.RemoveAll(segmentAppDir(s.dir, app.Name))
+	}
+	s.apps = nil
+	s.segments = map[string]*segmentWriter{}
+}
+
+func listSegments(dir string) ([]int, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("problem reading segment dir %s, %v", dir, err)
+	}
+	var seqs []int
+	for _, e := range entries {
+		var seq int
+		if e.IsDir() || !strings.HasSuffix(e.Name(), segmentFileSuffix) {
+			continue
+		}
+		if _, err := fmt.Sscanf(e.Name(), "%d"+segmentFileSuffix, &seq); err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	return seqs, nil
+}
+
+// replaySegment applies all complete records of a segment to app and returns the base of the segment.
+// A torn last line, left behind by a crash during an append, is cut off so later appends start cleanly.
+func replaySegment(path string, app *App) (int, error) {
+	base := app.Pruned + len(app.Logs)
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return base, fmt.Errorf("problem opening segment %s, %v", path, err)
+	}
+	defer file.Close()
+
+	rdr := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, err := rdr.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				fmt.Printf("truncating torn record at offset %d of segment %s\n", offset, path)
+				return base, file.Truncate(offset)
+			}
+			return base, nil
+		}
+		if err != nil {
+			return base, fmt.Errorf("problem reading segment %s, %v", path, err)
+		}
+		offset += int64(len(line))
+
+		var record segmentRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			fmt.Printf("skipping corrupt record in segment %s, %v\n", path, err)
+			continue
+		}
+		if record.Segment != nil {
+			base = record.Segment.Base
+		}
+		record.applyTo(app)
+	}
+}
+
+func (r segmentRecord) applyTo(app *App) {
+	switch {
+	case r.Segment != nil:
+		logs, pruned := app.Logs, app.Pruned
+		if r.Segment.Compacted {
+			// the segment repeats all logs which were not pruned
+			logs, pruned = nil, r.Segment.Base
+		}
+		*app = r.Segment.App
+		app.Logs, app.Pruned = logs, pruned
+		if app.Pruned+len(app.Logs) < r.Segment.Base {
+			// the segments before were removed once all their logs got pruned
+			app.Pruned = r.Segment.Base - len(app.Logs)
+		}
+	case r.Prune != nil:
+		app.pruneLogs(r.Prune.Before - app.Pruned)
+	case r.Log != nil:
+		app.Logs = append(app.Logs, *r.Log)
+	case r.Health != nil:
+		app.recordHealth(*r.Health, r.Received)
+	case r.Event != nil:
+		app.addEvent(*r.Event)
+	case r.Stale != nil:
+		app.markStale(r.Stale.At)
+	case r.AppLog != nil:
+		app.addAppLogs(*r.AppLog)
+	}
+}
+
+func segmentAppDir(root, name string) string {
+	return filepath.Join(root, url.PathEscape(name)+segmentAppDirSuffix)
+}
+
+func segmentPath(dir string, seq int) string {
+	return filepath.Join(dir, fmt.Sprintf("%08d%s", seq, segmentFileSuffix))
+}
+
+func createSegment(dir string, seq int, header segmentHeader, sync bool) (*segmentWriter, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("problem creating segment dir %s, %v", dir, err)
+	}
+	file, err := os.OpenFile(segmentPath(dir, seq), os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("problem creating segment %s, %v", segmentPath(dir, seq), err)
+	}
+	w := &segmentWriter{dir: dir, seq: seq, file: file, sync: sync, bases: []segmentBase{{seq: seq, base: header.Base}}}
+	err = w.append(segmentRecord{Segment: &header})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if sync {
+		syncDir(dir)
+	}
+	return w, nil
+}
+
+func (w *segmentWriter) append(records ...segmentRecord) error {
+	buf, err := encodeRecords(records)
+	if err != nil {
+		return err
+	}
+	_, err = w.file.Write(buf)
+	if err == nil && w.sync {
+		err = w.file.Sync()
+	}
+	if err != nil {
+		// cut off whatever got written, so the next record does not follow a torn one and the
+		// segment holds no records the caller drops
+		if truncErr := w.file.Truncate(w.size); truncErr != nil {
+			if info, statErr := w.file.Stat(); statErr == nil {
+				w.size = info.Size()
+			}
+			return fmt.Errorf("problem appending to segment %s, %v, and cutting it back, %v", w.file.Name(), err, truncErr)
+		}
+		return fmt.Errorf("problem appending to segment %s, %v", w.file.Name(), err)
+	}
+	w.size += int64(len(buf))
+	return nil
+}
+
+// encodeRecords turns records into the lines of a segment.
+func encodeRecords(records []segmentRecord) ([]byte, error) {
+	var buf []byte
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return nil, fmt.Errorf("problem encoding record, %v", err)
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+	return buf, nil
+}
+
+// appendRecords writes records to the active segment of app, rotating it first if it is full. It
+// reports whether they got written, callers only apply records to the app in memory which are on
+// disk too.
+func (s *SegmentedAppsStore) appendRecords(app *App, records ...segmentRecord) bool {
+	w, err := s.writerFor(app)
+	if err == nil {
+		err = w.append(records...)
+	}
+	if err != nil {
+		fmt.Printf("WARN: dropping %d records of %s, %v\n", len(records), app.Name, err)
+		return false
+	}
+	return true
+}
+
+func (s *SegmentedAppsStore) writerFor(app *App) (*segmentWriter, error) {
+	w := s.segments[app.Name]
+	if w == nil {
+		w, err := createSegment(segmentAppDir(s.dir, app.Name), 1, newSegmentHeader(app), s.syncWrites)
+		if err != nil {
+			return nil, err
+		}
+		s.segments[app.Name] = w
+		return w, nil
+	}
+	if w.size < s.maxSegmentBytes {
+		return w, nil
+	}
+
+	next, err := createSegment(w.dir, w.seq+1, newSegmentHeader(app), s.syncWrites)
+	if err != nil {
+		return nil, err
+	}
+	w.file.Close()
+	next.bases = append(w.bases, next.bases...)
+	s.segments[app.Name] = next
+	return next, nil
+}
+
+// removePruned deletes the inactive segments which only hold logs positioned before pruned.
+func (w *segmentWriter) removePruned(pruned int) {
+	for len(w.bases) > 1 && w.bases[1].base <= pruned {
+		err := os.Remove(segmentPath(w.dir, w.bases[0].seq))
+		if err != nil {
+			fmt.Printf("problem removing pruned segment, %v\n", err)
+			return
+		}
+		w.bases = w.bases[1:]
+	}
+}
+
+func newSegmentHeader(app *App) segmentHeader {
+	state := *app
+	state.Logs = nil
+	return segmentHeader{Base: app.Pruned + len(app.Logs), App: state}
+}
+
+// findOrAdd returns the app called name, adding an empty one if it does not exist yet.
+func (s *SegmentedAppsStore) findOrAdd(name string) *App {
+	app := s.apps.Find(name)
+	if app == nil {
+		s.apps = append(s.apps, App{Name: name})
+		app = &s.apps[len(s.apps)-1]
+	}
+	return app
+}
+
+func (s *SegmentedAppsStore) GetAppNames() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var apps []string
+	for _, v := range s.apps {
+		apps = append(apps, v.Name)
+	}
+	return apps
+}
+
+func (s *SegmentedAppsStore) GetApps() Apps {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.apps.snapshot()
+}
+
+func (s *SegmentedAppsStore) GetApp(name string) *App {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	app := s.apps.Find(name)
+	if app != nil {
+		snapshot := app.snapshot()
+		return &snapshot
+	}
+	return nil
+}
+
+func (s *SegmentedAppsStore) GetAccessLogs(name string) AccessLogs {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	app := s.apps.Find(name)
+	if app != nil {
+		return app.snapshot().Logs
+	}
+	return AccessLogs{}
+}
+
+func (s *SegmentedAppsStore) RecordAccessLog(name string, log AccessLog) {
+	s.RecordAccessLogs(name, AccessLogs{log})
+}
+
+// RecordAccessLogs appends all logs to the active segment with a single write.
+func (s *SegmentedAppsStore) RecordAccessLogs(name string, logs AccessLogs) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	logs = currentLogs(logs)
+	app := s.findOrAdd(name)
+	records := make([]segmentRecord, len(logs))
+	for i := range logs {
+		records[i] = segmentRecord{Log: &logs[i]}
+	}
+	if !s.appendRecords(app, records...) {
+		return
+	}
+	app.Logs = append(app.Logs, logs...)
+}
+
+// PruneAccessLogs records which logs got pruned and removes the segments no longer needed.
+func (s *SegmentedAppsStore) PruneAccessLogs(name string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app := s.apps.Find(name)
+	if app == nil || count <= 0 {
+		return
+	}
+	if count > len(app.Logs) {
+		count = len(app.Logs)
+	}
+	if !s.appendRecords(app, segmentRecord{Prune: &segmentPrune{Before: app.Pruned + count}}) {
+		return
+	}
+	app.pruneLogs(count)
+	if w := s.segments[name]; w != nil {
+		w.removePruned(app.Pruned)
+	}
+}
+
+func (s *SegmentedAppsStore) GetHealth(name string) HealthCheck {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	app := s.apps.Find(name)
+	if app != nil {
+		return app.CurrentHealth()
+	}
+	return UNHEALTHY
+}
+
+func (s *SegmentedAppsStore) RecordHealth(name string, check HealthCheck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app := s.findOrAdd(name)
+	received := time.Now().Unix()
+	if !s.appendRecords(app, segmentRecord{Health: &check, Received: received}) {
+		return
+	}
+	app.recordHealth(check, received)
+}
+
+func (s *SegmentedAppsStore) MarkHealthStale(name string, at int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app := s.apps.Find(name)
+	if app == nil || app.Stale {
+		return
+	}
+	if !s.appendRecords(app, segmentRecord{Stale: &segmentStale{At: at}}) {
+		return
+	}
+	app.markStale(at)
+}
+
+func (s *SegmentedAppsStore) RecordProcessEvent(name string, event ProcessEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app := s.findOrAdd(name)
+	if !s.appendRecords(app, segmentRecord{Event: &event}) {
+		return
+	}
+	app.addEvent(event)
+}
+
+func (s *SegmentedAppsStore) RecordAppLogs(name string, logs []AppLog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app := s.findOrAdd(name)
+	records := make([]segmentRecord, len(logs))
+	for i := range logs {
+		records[i] = segmentRecord{AppLog: &logs[i]}
+	}
+	if !s.appendRecords(app, records...) {
+		return
+	}
+	app.addAppLogs(logs...)
+}
@@ -0,0 +1,308 @@
+package mond
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func createTempDir(t testing.TB) (string, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "segments")
+	if err != nil {
+		t.Fatalf("could not create temp dir %v", err)
+	}
+
+	removeDir := func() {
+		os.RemoveAll(dir)
+	}
+
+	return dir, removeDir
+}
+
+func TestSegmentedAppsStore(t *testing.T) {
+	t.Run("store logs, health and events and read them back after reopening", func(t *testing.T) {
+		dir, cleanDir := createTempDir(t)
+		defer cleanDir()
+
+		store, err := NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, true)
+		assertNoError(t, err)
+		store.RecordAccessLog("App1", AccessLog{Raw: "Test1", Version: AccessLogVersion})
+		store.RecordHealth("App1", HEALTHY)
+		store.RecordProcessEvent("App1", ProcessEvent{Type: ProcessStarted, Pid: 42})
+		store.RecordAppLogs("App1", []AppLog{{Unix: 1, Level: LevelError, Raw: "boom\n\tat Main", LogSource: LogSource{Stream: StreamStderr, Pid: 42, Host: "web-1", Captured: 1500}}})
+		store.RecordAccessLog("App1", AccessLog{Raw: "Test2", Version: AccessLogVersion})
+		store.RecordAccessLog("App2", AccessLog{Raw: "Test3", Version: AccessLogVersion})
+		store.Close()
+
+		store, err = NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, true)
+		assertNoError(t, err)
+		defer store.Close()
+
+		assertAppNamesEquals(t, store.GetAppNames(), []string{"App1", "App2"})
+		assertAccessLogsEquals(t, store.GetAccessLogs("App1"), AccessLogs{
+			{Raw: "Test1", Version: AccessLogVersion},
+			{Raw: "Test2", Version: AccessLogVersion},
+		})
+		assertAccessLogsEquals(t, store.GetAccessLogs("App2"), AccessLogs{{Raw: "Test3", Version: AccessLogVersion}})
+		assertHealthEquals(t, store.GetHealth("App1"), HEALTHY)
+		if events := store.GetApp("App1").Events; len(events) != 1 || events[0].Pid != 42 {
+			t.Errorf("got events %v want the start of pid 42", events)
+		}
+		wantAppLogs := []AppLog{{Unix: 1, Level: LevelError, Raw: "boom\n\tat Main", LogSource: LogSource{Stream: StreamStderr, Pid: 42, Host: "web-1", Captured: 1500}}}
+		if appLogs := store.GetApp("App1").AppLogs; !reflect.DeepEqual(appLogs, wantAppLogs) {
+			t.Errorf("got app logs %v want %v", appLogs, wantAppLogs)
+		}
+	})
+
+	t.Run("keeps the stale state and health transition after reopening", func(t *testing.T) {
+		dir, cleanDir := createTempDir(t)
+		defer cleanDir()
+
+		store, err := NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, true)
+		assertNoError(t, err)
+		store.RecordHealth("App1", HEALTHY)
+		store.MarkHealthStale("App1", 42)
+		store.Close()
+
+		store, err = NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, true)
+		assertNoError(t, err)
+		defer store.Close()
+
+		assertHealthEquals(t, store.GetHealth("App1"), HealthCheck{Status: HealthStale, Timestamp: 42})
+		if app := store.GetApp("App1"); app.HealthReceived == 0 {
+			t.Errorf("expected the receipt time of the health report to be kept, got %+v", app)
+		}
+	})
+
+	t.Run("rotates segments and replays all of them", func(t *testing.T) {
+		dir, cleanDir := createTempDir(t)
+		defer cleanDir()
+
+		store, err := NewSegmentedAppsStore(dir, 64, true)
+		assertNoError(t, err)
+		store.RecordHealth("App1", HEALTHY)
+		var want AccessLogs
+		for i := 0; i < 10; i++ {
+			l := AccessLog{Raw: "some log line which fills a segment", Version: AccessLogVersion}
+			store.RecordAccessLog("App1", l)
+			want = append(want, l)
+		}
+		store.Close()
+
+		segments, _ := filepath.Glob(filepath.Join(segmentAppDir(dir, "App1"), "*"+segmentFileSuffix))
+		if len(segments) < 2 {
+			t.Fatalf("expected several segments, got %v", segments)
+		}
+
+		store, err = NewSegmentedAppsStore(dir, 64, true)
+		assertNoError(t, err)
+		defer store.Close()
+
+		assertAccessLogsEquals(t, store.GetAccessLogs("App1"), want)
+		assertHealthEquals(t, store.GetHealth("App1"), HEALTHY)
+	})
+
+	t.Run("ignores a torn record at the end of a segment", func(t *testing.T) {
+		dir, cleanDir := createTempDir(t)
+		defer cleanDir()
+
+		store, err := NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, true)
+		assertNoError(t, err)
+		store.RecordAccessLog("App1", AccessLog{Raw: "Test1", Version: AccessLogVersion})
+		store.Close()
+
+		segment := segmentPath(segmentAppDir(dir, "App1"), 1)
+		f, _ := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0644)
+		f.Write([]byte(`{"log":{"raw":"Te`))
+		f.Close()
+
+		store, err = NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, true)
+		assertNoError(t, err)
+		store.RecordAccessLog("App1", AccessLog{Raw: "Test2", Version: AccessLogVersion})
+		store.Close()
+
+		store, err = NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, true)
+		assertNoError(t, err)
+		defer store.Close()
+
+		assertAccessLogsEquals(t, store.GetAccessLogs("App1"), AccessLogs{
+			{Raw: "Test1", Version: AccessLogVersion},
+			{Raw: "Test2", Version: AccessLogVersion},
+		})
+	})
+
+	t.Run("removes pruned segments and keeps the pruned logs out after reopening", func(t *testing.T) {
+		dir, cleanDir := createTempDir(t)
+		defer cleanDir()
+
+		store, err := NewSegmentedAppsStore(dir, 64, true)
+		assertNoError(t, err)
+		store.RecordHealth("App1", HEALTHY)
+		for i := 0; i < 10; i++ {
+			store.RecordAccessLog("App1", AccessLog{Raw: fmt.Sprintf("some log line number %d", i), Version: AccessLogVersion})
+		}
+		before, _ := filepath.Glob(filepath.Join(segmentAppDir(dir, "App1"), "*"+segmentFileSuffix))
+
+		store.PruneAccessLogs("App1", 8)
+		store.Close()
+
+		after, _ := filepath.Glob(filepath.Join(segmentAppDir(dir, "App1"), "*"+segmentFileSuffix))
+		if len(after) >= len(before) {
+			t.Errorf("expected pruned segments to be removed, got %d segments before and %d after", len(before), len(after))
+		}
+
+		store, err = NewSegmentedAppsStore(dir, 64, true)
+		assertNoError(t, err)
+		defer store.Close()
+
+		assertAccessLogsEquals(t, store.GetAccessLogs("App1"), AccessLogs{
+			{Raw: "some log line number 8", Version: AccessLogVersion},
+			{Raw: "some log line number 9", Version: AccessLogVersion},
+		})
+		if got := store.GetApp("App1").Pruned; got != 8 {
+			t.Errorf("got %d pruned logs want 8", got)
+		}
+		assertHealthEquals(t, store.GetHealth("App1"), HEALTHY)
+	})
+
+	t.Run("does not compact the segments of logs it recorded itself", func(t *testing.T) {
+		dir, cleanDir := createTempDir(t)
+		defer cleanDir()
+
+		store, err := NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, true)
+		assertNoError(t, err)
+		store.RecordAccessLog(MondAppName, AccessLog{Raw: "https://example.com/dashboard/"})
+		store.Close()
+
+		store, err = NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, true)
+		assertNoError(t, err)
+		defer store.Close()
+
+		if w := store.segments[MondAppName]; w.seq != 1 {
+			t.Errorf("got active segment %d want the first one", w.seq)
+		}
+	})
+
+	t.Run("upgrades older logs once and survives a crash while compacting", func(t *testing.T) {
+		dir, cleanDir := createTempDir(t)
+		defer cleanDir()
+		raw := `10.0.0.1 - - [02/Jul/2021:22:50:59 +0200] "GET /a HTTP/1.1" 200 7280 "-" "curl/7.68.0"`
+
+		store, err := NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, true)
+		assertNoError(t, err)
+		store.RecordHealth("App1", HEALTHY)
+		store.Close()
+		appDir := segmentAppDir(dir, "App1")
+		// a log recorded before logs had a version
+		old, _ := ioutil.ReadFile(segmentPath(appDir, 1))
+		line, _ := json.Marshal(segmentRecord{Log: &AccessLog{Raw: raw}})
+		old = append(old, append(line, '\n')...)
+		ioutil.WriteFile(segmentPath(appDir, 1), old, 0644)
+
+		store, err = NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, true)
+		assertNoError(t, err)
+		store.Close()
+
+		segments, _ := filepath.Glob(filepath.Join(appDir, "*"+segmentFileSuffix))
+		if !reflect.DeepEqual(segments, []string{segmentPath(appDir, 2)}) {
+			t.Fatalf("got segments %v want the compacted one only", segments)
+		}
+		// as if the apiserver crashed before it removed the old segment
+		ioutil.WriteFile(segmentPath(appDir, 1), old, 0644)
+
+		store, err = NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, true)
+		assertNoError(t, err)
+		defer store.Close()
+
+		logs := store.GetAccessLogs("App1")
+		if len(logs) != 1 || logs[0].Version != AccessLogVersion || logs[0].Method != "GET" || logs[0].UserAgent != "curl/7.68.0" {
+			t.Errorf("got %+v want the upgraded log once", logs)
+		}
+		assertHealthEquals(t, store.GetHealth("App1"), HEALTHY)
+		if w := store.segments["App1"]; w.seq != 2 {
+			t.Errorf("got active segment %d want the compacted one", w.seq)
+		}
+	})
+
+	t.Run("imports the apps of a json database into an empty store", func(t *testing.T) {
+		dir, cleanDir := createTempDir(t)
+		defer cleanDir()
+		database, cleanDatabase := createTempFile(t, `[{"app":"App1","health":{"status":"UP","timestamp":1},"pruned":3,"logs":[
+			{"raw":"Test1","version":2},{"raw":"Test2","version":2}
+		]}]`)
+		defer cleanDatabase()
+
+		store, err := NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, true)
+		assertNoError(t, err)
+		imported, err := store.ImportAppsDBFile(database.Name())
+		assertNoError(t, err)
+		if imported != 1 {
+			t.Errorf("got %d imported apps want 1", imported)
+		}
+		store.RecordAccessLog("App1", AccessLog{Raw: "Test3", Version: AccessLogVersion})
+		store.Close()
+
+		store, err = NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, true)
+		assertNoError(t, err)
+		defer store.Close()
+		if imported, _ := store.ImportAppsDBFile(database.Name()); imported != 0 {
+			t.Errorf("got %d apps imported into a store which has apps", imported)
+		}
+
+		assertAccessLogsEquals(t, store.GetAccessLogs("App1"), AccessLogs{
+			{Raw: "Test1", Version: AccessLogVersion},
+			{Raw: "Test2", Version: AccessLogVersion},
+			{Raw: "Test3", Version: AccessLogVersion},
+		})
+		assertHealthEquals(t, store.GetHealth("App1"), HEALTHY)
+		if pruned := store.GetApp("App1").Pruned; pruned != 3 {
+			t.Errorf("got %d pruned logs want 3", pruned)
+		}
+	})
+
+	t.Run("does not keep records in memory which could not be written", func(t *testing.T) {
+		dir, cleanDir := createTempDir(t)
+		defer cleanDir()
+
+		store, err := NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, true)
+		assertNoError(t, err)
+		defer store.Close()
+		store.RecordAccessLog("App1", AccessLog{Raw: "Test1"})
+		store.segments["App1"].file.Close()
+
+		store.RecordAccessLog("App1", AccessLog{Raw: "Test2"})
+		store.RecordHealth("App1", HEALTHY)
+
+		assertAccessLogsEquals(t, store.GetAccessLogs("App1"), AccessLogs{{Raw: "Test1", Version: AccessLogVersion}})
+		if health := store.GetApp("App1").Health; health.Status != "" {
+			t.Errorf("got health %+v want none", health)
+		}
+	})
+
+	t.Run("keeps app names which are no valid file names", func(t *testing.T) {
+		dir, cleanDir := createTempDir(t)
+		defer cleanDir()
+
+		names := []string{"a/b", "..", ""}
+		store, err := NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, true)
+		assertNoError(t, err)
+		for _, n := range names {
+			store.RecordAccessLog(n, AccessLog{Raw: n, Version: AccessLogVersion})
+		}
+		store.Close()
+
+		store, err = NewSegmentedAppsStore(dir, DefaultMaxSegmentBytes, true)
+		assertNoError(t, err)
+		defer store.Close()
+
+		for _, n := range names {
+			assertAccessLogsEquals(t, store.GetAccessLogs(n), AccessLogs{{Raw: n, Version: AccessLogVersion}})
+		}
+	})
+}
@@ -7,7 +7,9 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -19,9 +21,22 @@ const DashboardRawLogsPath = "/dashboard/rawlogs/"
 const DashboardLogsPath = "/dashboard/logs/"
 const DashboardStatsPath = "/dashboard/stats/"
 const DashboardReqsPath = "/dashboard/reqs/"
+const DashboardTailPath = "/dashboard/tail/"
+const DashboardNotificationsPath = "/dashboard/notifications/"
+const DashboardSilencesPath = "/dashboard/silences/"
+const DashboardAppLogsPath = "/dashboard/applogs/"
 const ApiAccessLogsPath = "/logs/"
 const ApiRawLogsPath = "/rawlogs/"
 const ApiHealthPath = "/health/"
+const ApiEventsPath = "/events/"
+const ApiAlertsPath = "/alerts/"
+const ApiResolvedSuffix = "resolved"
+const ApiSilencesPath = "/silences/"
+const ApiAppLogsPath = "/applogs/"
+const ApiStreamSuffix = "/stream"
+const ApiHistorySuffix = "/history"
+const ApiUptimeSuffix = "/uptime"
+const ApiTargetsSuffix = "/targets"
 
 type AccessLogStore interface {
 	GetAppNames() []string
@@ -29,12 +44,25 @@ type AccessLogStore interface {
 	GetApp(name string) *App
 	GetAccessLogs(name string) AccessLogs
 	RecordAccessLog(name string, value AccessLog)
+	RecordAccessLogs(name string, values AccessLogs)
+	PruneAccessLogs(name string, count int)
 	GetHealth(name string) HealthCheck
 	RecordHealth(name string, check HealthCheck)
+	RecordProcessEvent(name string, event ProcessEvent)
+	RecordAppLogs(name string, logs []AppLog)
+	MarkHealthStale(name string, at int64)
 }
 
 type ApiServer struct {
-	store AccessLogStore
+	store      AccessLogStore
+	tail       *LogTail
+	alerter    *Alerter
+	dispatcher *Dispatcher
+	silences   *Silences
+	parsers    *LogParsers
+	// recording is held while logs are recorded and published, and exclusively while a stream
+	// takes its backlog and subscribes, so that no log reaches a stream twice.
+	recording sync.RWMutex
 	http.Handler
 }
 
@@ -45,10 +73,15 @@ type SecurityUserInfo struct {
 
 const jsonContentType = "application/json"
 const textContentType = "text/plain"
+const nextCursorHeader = "X-Next-Cursor"
+const eventStreamContentType = "text/event-stream"
+
+const streamKeepAliveInterval = 15 * time.Second
 
 func NewApiServer(store AccessLogStore, info SecurityUserInfo) *ApiServer {
 	s := new(ApiServer)
 	s.store = store
+	s.tail = NewLogTail()
 
 	router := http.NewServeMux()
 	// Dashboard
@@ -57,6 +90,10 @@ func NewApiServer(store AccessLogStore, info SecurityUserInfo) *ApiServer {
 	router.Handle(DashboardLogsPath, http.HandlerFunc(basicAuth(s.dashboardLogsHandler, info)))
 	router.Handle(DashboardStatsPath, http.HandlerFunc(basicAuth(s.statsHandler, info)))
 	router.Handle(DashboardReqsPath, http.HandlerFunc(basicAuth(s.reqsHandler, info)))
+	router.Handle(DashboardTailPath, http.HandlerFunc(basicAuth(s.tailHandler, info)))
+	router.Handle(DashboardNotificationsPath, http.HandlerFunc(basicAuth(s.notificationsHandler, info)))
+	router.Handle(DashboardSilencesPath, http.HandlerFunc(basicAuth(s.dashboardSilencesHandler, info)))
+	router.Handle(DashboardAppLogsPath, http.HandlerFunc(basicAuth(s.dashboardAppLogsHandler, info)))
 	fs := http.FileServer(http.Dir("asset/"))
 	router.Handle(DashboardAssetsPath, http.StripPrefix(DashboardAssetsPath, fs))
 
@@ -66,6 +103,10 @@ func NewApiServer(store AccessLogStore, info SecurityUserInfo) *ApiServer {
 	router.Handle(ApiAccessLogsPath, http.HandlerFunc(s.logsHandler))
 	router.Handle(ApiRawLogsPath, http.HandlerFunc(s.rawLogsHandler))
 	router.Handle(ApiHealthPath, http.HandlerFunc(s.healthHandler))
+	router.Handle(ApiEventsPath, http.HandlerFunc(s.eventsHandler))
+	router.Handle(ApiAlertsPath, http.HandlerFunc(s.alertsHandler))
+	router.Handle(ApiSilencesPath, http.HandlerFunc(basicAuthUnlessGet(s.silencesHandler, info)))
+	router.Handle(ApiAppLogsPath, http.HandlerFunc(s.appLogsHandler))
 
 	// Root
 	//router.Handle(HomePath, http.FileServer(http.Dir("./html")))
@@ -76,6 +117,27 @@ func NewApiServer(store AccessLogStore, info SecurityUserInfo) *ApiServer {
 	return s
 }
 
+// SetAlerter makes the alerts of alerter available on the API and the dashboard.
+func (s *ApiServer) SetAlerter(alerter *Alerter) {
+	s.alerter = alerter
+}
+
+// SetDispatcher shows the deliveries of dispatcher on the dashboard.
+func (s *ApiServer) SetDispatcher(dispatcher *Dispatcher) {
+	s.dispatcher = dispatcher
+}
+
+// SetSilences makes silences manageable on the API and the dashboard and leaves their
+// maintenance out of the uptime.
+func (s *ApiServer) SetSilences(silences *Silences) {
+	s.silences = silences
+}
+
+// SetLogParsers reads the raw logs of each app with its configured parser.
+func (s *ApiServer) SetLogParsers(parsers *LogParsers) {
+	s.parsers = parsers
+}
+
 func (s *ApiServer) rootHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
@@ -93,12 +155,62 @@ func (s *ApiServer) dashboardHandler(w http.ResponseWriter, r *http.Request) {
 	s.RecordDashboardAccess(r)
 
 	indexTempl := template.Must(template.ParseFiles("html/index.html"))
-	err := indexTempl.Execute(w, apps)
+	err := indexTempl.Execute(w, dashboard{Apps: apps, Alerts: s.activeAlerts(), silences: s.silences, now: time.Now()})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+// dashboard is what the dashboard template shows.
+type dashboard struct {
+	Apps     Apps
+	Alerts   []Alert
+	silences *Silences
+	now      time.Time
+}
+
+// UptimesOf returns the uptimes of an app without its maintenance.
+func (d dashboard) UptimesOf(app App) []Uptime {
+	return app.UptimesExcluding(d.now, maintenanceOf(d.silences, app.Name, d.now))
+}
+
+// InMaintenance reports whether the whole app is silenced right now.
+func (d dashboard) InMaintenance(name string) bool {
+	return inPeriods(d.now.Unix(), maintenanceOf(d.silences, name, d.now))
+}
+
+// IsSilenced reports whether the notifications of an alert are suppressed right now.
+func (d dashboard) IsSilenced(alert Alert) bool {
+	if d.silences == nil {
+		return false
+	}
+	_, ok := d.silences.Silencing(Notification{App: alert.App, Rule: alert.Rule}, d.now)
+	return ok
+}
+
+// maintenanceOf returns the maintenance of an app as far back as the health history reaches.
+func maintenanceOf(silences *Silences, name string, now time.Time) []Period {
+	return maintenanceBetween(silences, name, now.Add(-HealthHistoryMaxAge).Unix(), now.Unix())
+}
+
+func maintenanceBetween(silences *Silences, name string, from, to int64) []Period {
+	if silences == nil {
+		return nil
+	}
+	return silences.MaintenanceOf(name, from, to)
+}
+
+// AlertsOf returns the active alerts of an app.
+func (d dashboard) AlertsOf(name string) []Alert {
+	var alerts []Alert
+	for _, a := range d.Alerts {
+		if a.App == name {
+			alerts = append(alerts, a)
+		}
+	}
+	return alerts
+}
+
 func (s *ApiServer) statsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "", http.StatusMethodNotAllowed)
@@ -152,18 +264,234 @@ func (s *ApiServer) dashboardLogsHandler(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "", http.StatusNotFound)
 		return
 	}
+	values := r.URL.Query()
+	if values.Get("sort") == "" {
+		values.Set("sort", "desc")
+	}
+	if values.Get("limit") == "" {
+		values.Set("limit", fmt.Sprint(DefaultDashboardLogsLimit))
+	}
+	query, err := ParseLogQuery(values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	s.RecordDashboardAccess(r)
 
+	page := logsPage{App: app.Name, Query: values}
+	page.Logs, page.Next = query.Apply(app)
 	indexTempl := template.Must(template.ParseFiles("html/logs.html"))
-	err := indexTempl.Execute(w, app.GetLogsSorted())
+	err = indexTempl.Execute(w, page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// logsPage is rendered by html/logs.html.
+type logsPage struct {
+	App   string
+	Query url.Values
+	Logs  AccessLogs
+	Next  string
+}
+
+// NextPage returns the link to the next page of logs, empty on the last page.
+func (p logsPage) NextPage() string {
+	if p.Next == "" {
+		return ""
+	}
+	values := url.Values{}
+	for k, v := range p.Query {
+		values[k] = v
+	}
+	values.Set("cursor", p.Next)
+	return "?" + values.Encode()
+}
+
+// RawQuery returns the current filters to be reused by links to other views.
+func (p logsPage) RawQuery() string {
+	values := url.Values{}
+	for k, v := range p.Query {
+		values[k] = v
+	}
+	values.Del("cursor")
+	return "?" + values.Encode()
+}
+
+func (s *ApiServer) tailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+	appName := strings.ToLower(strings.TrimPrefix(r.URL.Path, DashboardTailPath))
+	app := s.store.GetApp(appName)
+	if app == nil {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+	s.RecordDashboardAccess(r)
+
+	indexTempl := template.Must(template.ParseFiles("html/tail.html"))
+	err := indexTempl.Execute(w, logsPage{App: app.Name, Query: r.URL.Query()})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// dashboardAppLogsHandler shows the application logs of an app, the most recent first.
+func (s *ApiServer) dashboardAppLogsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+	appName := strings.ToLower(strings.TrimPrefix(r.URL.Path, DashboardAppLogsPath))
+	app := s.store.GetApp(appName)
+	if app == nil {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+	values := r.URL.Query()
+	if values.Get("sort") == "" {
+		values.Set("sort", "desc")
+	}
+	if values.Get("limit") == "" {
+		values.Set("limit", fmt.Sprint(DefaultDashboardAppLogsLimit))
+	}
+	query, err := ParseAppLogQuery(values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.RecordDashboardAccess(r)
+
+	page := appLogsPage{App: app.Name, Query: values, Levels: appLogLevels, Logs: query.Apply(app)}
+	indexTempl := template.Must(template.ParseFiles("html/applogs.html"))
+	err = indexTempl.Execute(w, page)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+// appLogsPage is rendered by html/applogs.html.
+type appLogsPage struct {
+	App    string
+	Query  url.Values
+	Levels []string
+	Logs   []AppLog
+}
+
+// notificationsHandler shows the latest deliveries of notifications, the most recent first.
+func (s *ApiServer) notificationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+	s.RecordDashboardAccess(r)
+
+	var deliveries []Delivery
+	if s.dispatcher != nil {
+		deliveries = s.dispatcher.Deliveries()
+	}
+	for i, j := 0, len(deliveries)-1; i < j; i, j = i+1, j-1 {
+		deliveries[i], deliveries[j] = deliveries[j], deliveries[i]
+	}
+	indexTempl := template.Must(template.ParseFiles("html/notifications.html"))
+	err := indexTempl.Execute(w, deliveries)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// dashboardSilencesHandler lists the silences and handles the forms adding and expiring them.
+func (s *ApiServer) dashboardSilencesHandler(w http.ResponseWriter, r *http.Request) {
+	if s.silences == nil {
+		http.Error(w, "silences are not enabled", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		s.RecordDashboardAccess(r)
+		indexTempl := template.Must(template.ParseFiles("html/silences.html"))
+		err := indexTempl.Execute(w, s.silences.List(time.Now()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	case http.MethodPost:
+		if id := r.FormValue("expire"); id != "" {
+			s.silences.Expire(id, time.Now())
+		} else {
+			silence, err := parseSilenceForm(r, time.Now())
+			if err == nil {
+				_, err = s.silences.Add(silence, time.Now())
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		http.Redirect(w, r, DashboardSilencesPath, http.StatusSeeOther)
+	default:
+		http.Error(w, "", http.StatusMethodNotAllowed)
+	}
+}
+
+// parseSilenceForm reads a silence from the dashboard form. It ends at end or lasts for duration,
+// a recurring one repeats on days at a time of day for length.
+func parseSilenceForm(r *http.Request, now time.Time) (Silence, error) {
+	silence := Silence{
+		App:     strings.ToLower(r.FormValue("app")),
+		Target:  r.FormValue("target"),
+		Rule:    r.FormValue("rule"),
+		Comment: r.FormValue("comment"),
+	}
+	var err error
+	if silence.Start, err = parseQueryTime(r.FormValue("start")); err != nil {
+		return silence, fmt.Errorf("invalid start, %v", err)
+	}
+	if silence.Start == 0 {
+		silence.Start = now.Unix()
+	}
+	if silence.End, err = parseQueryTime(r.FormValue("end")); err != nil {
+		return silence, fmt.Errorf("invalid end, %v", err)
+	}
+	if duration := r.FormValue("duration"); duration != "" {
+		d, err := ParseDuration(duration)
+		if err != nil {
+			return silence, fmt.Errorf("invalid duration, %v", err)
+		}
+		silence.End = silence.Start + int64(time.Duration(d)/time.Second)
+	}
+	if at := r.FormValue("at"); at != "" {
+		length, err := ParseDuration(r.FormValue("length"))
+		if err != nil {
+			return silence, fmt.Errorf("invalid length, %v", err)
+		}
+		silence.Recurrence = &Recurrence{Days: r.Form["days"], At: at, Duration: length}
+	}
+	return silence, nil
+}
+
+// recordAccessLog stores log and passes it on to everyone tailing the logs of the app.
+func (s *ApiServer) recordAccessLog(name string, log AccessLog) {
+	s.recording.RLock()
+	defer s.recording.RUnlock()
+	s.store.RecordAccessLog(name, log)
+	s.tail.Publish(name, log)
+}
+
+// recordAccessLogs stores logs with a single write and passes them on like recordAccessLog.
+func (s *ApiServer) recordAccessLogs(name string, logs AccessLogs) {
+	s.recording.RLock()
+	defer s.recording.RUnlock()
+	s.store.RecordAccessLogs(name, logs)
+	for _, l := range logs {
+		s.tail.Publish(name, l)
+	}
+}
+
 func (s *ApiServer) RecordDashboardAccess(r *http.Request) {
 	user,_, ok := r.BasicAuth()
-	s.store.RecordAccessLog(MondAppName, AccessLog{
+	s.recordAccessLog(MondAppName, AccessLog{
 		Timestamp: 0,
 		Unix:      time.Now().Unix(),
 		Ip:        user,
@@ -178,9 +506,13 @@ func (s *ApiServer) logsHandler(w http.ResponseWriter, r *http.Request) {
 	appName := strings.ToLower(strings.TrimPrefix(r.URL.Path, ApiAccessLogsPath))
 	switch r.Method {
 	case http.MethodPost:
-		s.processLog(w, appName, r.Body)
+		s.processLogs(w, r, appName)
 	case http.MethodGet:
-		s.showLogs(w, appName)
+		if strings.HasSuffix(appName, ApiStreamSuffix) {
+			s.streamLogs(w, r, strings.TrimSuffix(appName, ApiStreamSuffix))
+			return
+		}
+		s.showLogs(w, r, appName)
 	}
 }
 
@@ -194,7 +526,7 @@ func (s *ApiServer) rawLogsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	switch r.Method {
 	case http.MethodGet:
-		s.showRawLogs(w, appName)
+		s.showRawLogs(w, r, appName)
 	}
 }
 
@@ -204,29 +536,152 @@ func (s *ApiServer) healthHandler(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		s.processHealth(w, name, r.Body)
 	case http.MethodGet:
-		s.showHealth(w, name)
+		switch {
+		case strings.HasSuffix(name, ApiHistorySuffix):
+			s.showHealthHistory(w, r, strings.TrimSuffix(name, ApiHistorySuffix))
+		case strings.HasSuffix(name, ApiUptimeSuffix):
+			s.showUptime(w, strings.TrimSuffix(name, ApiUptimeSuffix))
+		case strings.HasSuffix(name, ApiTargetsSuffix):
+			s.showTargets(w, strings.TrimSuffix(name, ApiTargetsSuffix))
+		default:
+			s.showHealth(w, name)
+		}
 	}
 }
 
-func (s *ApiServer) showLogs(w http.ResponseWriter, name string) {
-	logs := s.store.GetAccessLogs(name)
-
-	if len(logs) < 1 {
+// queryLogs applies the query parameters of r to the logs of an app. If there is a next page its
+// cursor is set as X-Next-Cursor header. Apps without any logs are reported as not found.
+func (s *ApiServer) queryLogs(w http.ResponseWriter, r *http.Request, name string) (AccessLogs, bool) {
+	query, err := ParseLogQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	app := s.store.GetApp(name)
+	logs, next := query.Apply(app)
+	if next != "" {
+		w.Header().Set(nextCursorHeader, next)
+	}
+	if app == nil || len(app.Logs) < 1 {
 		w.WriteHeader(http.StatusNotFound)
 	}
+	return logs, true
+}
+
+func (s *ApiServer) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.ToLower(strings.TrimPrefix(r.URL.Path, ApiEventsPath))
+	switch r.Method {
+	case http.MethodPost:
+		s.processEvent(w, name, r.Body)
+	case http.MethodGet:
+		s.showEvents(w, name)
+	}
+}
 
+func (s *ApiServer) appLogsHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.ToLower(strings.TrimPrefix(r.URL.Path, ApiAppLogsPath))
+	switch r.Method {
+	case http.MethodPost:
+		s.processAppLogs(w, r, name)
+	case http.MethodGet:
+		s.showAppLogs(w, r, name)
+	default:
+		http.Error(w, "", http.StatusMethodNotAllowed)
+	}
+}
+
+// alertsHandler returns the pending and firing alerts, or the latest resolved ones, optionally
+// of a single app.
+func (s *ApiServer) alertsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+	var alerts []Alert
+	switch strings.TrimPrefix(r.URL.Path, ApiAlertsPath) {
+	case "":
+		alerts = s.activeAlerts()
+	case ApiResolvedSuffix:
+		if s.alerter != nil {
+			alerts = s.alerter.Resolved()
+		}
+	default:
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+	filtered := []Alert{}
+	app := strings.ToLower(r.URL.Query().Get("app"))
+	for _, a := range alerts {
+		if app == "" || a.App == app {
+			filtered = append(filtered, a)
+		}
+	}
 	w.Header().Set("content-type", jsonContentType)
-	json.NewEncoder(w).Encode(&logs)
+	json.NewEncoder(w).Encode(&filtered)
 }
 
-func (s *ApiServer) showRawLogs(w http.ResponseWriter, name string) {
-	logs := s.store.GetAccessLogs(name)
+// silencesHandler lists the silences which did not end yet on GET, adds one on POST and ends
+// one on DELETE of /silences/{id}.
+func (s *ApiServer) silencesHandler(w http.ResponseWriter, r *http.Request) {
+	if s.silences == nil {
+		http.Error(w, "silences are not enabled", http.StatusNotFound)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, ApiSilencesPath)
+	switch r.Method {
+	case http.MethodGet:
+		silences := s.silences.List(time.Now())
+		w.Header().Set("content-type", jsonContentType)
+		json.NewEncoder(w).Encode(&silences)
+	case http.MethodPost:
+		var silence Silence
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&silence); err != nil {
+			http.Error(w, fmt.Sprintf("can't read body, %v", err), http.StatusBadRequest)
+			return
+		}
+		silence, err := s.silences.Add(silence, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("content-type", jsonContentType)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(&silence)
+	case http.MethodDelete:
+		if !s.silences.Expire(id, time.Now()) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "", http.StatusMethodNotAllowed)
+	}
+}
 
-	if len(logs) < 1 {
-		w.WriteHeader(http.StatusNotFound)
+func (s *ApiServer) activeAlerts() []Alert {
+	if s.alerter == nil {
+		return nil
+	}
+	return s.alerter.Active()
+}
+
+func (s *ApiServer) showLogs(w http.ResponseWriter, r *http.Request, name string) {
+	w.Header().Set("content-type", jsonContentType)
+	logs, ok := s.queryLogs(w, r, name)
+	if !ok {
+		return
 	}
+	json.NewEncoder(w).Encode(&logs)
+}
 
+func (s *ApiServer) showRawLogs(w http.ResponseWriter, r *http.Request, name string) {
 	w.Header().Set("content-type", textContentType)
+	logs, ok := s.queryLogs(w, r, name)
+	if !ok {
+		return
+	}
 	for _, l := range logs {
 		_, err := fmt.Fprintf(w, "%s\n", l.Raw)
 		if err != nil {
@@ -236,14 +691,143 @@ func (s *ApiServer) showRawLogs(w http.ResponseWriter, name string) {
 
 }
 
-func (s *ApiServer) processLog(w http.ResponseWriter, name string, body io.ReadCloser) {
-	bodyContent, err := ioutil.ReadAll(body)
+// processLogs records a batch of logs at once and reports which of them were accepted.
+func (s *ApiServer) processLogs(w http.ResponseWriter, r *http.Request, name string) {
+	bodyContent, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxBatchBytes))
 	if err != nil {
 		http.Error(w, "can't read body", http.StatusBadRequest)
 		return
 	}
-	s.store.RecordAccessLog(name, ParseRawLog(string(bodyContent)))
+	logs, result, err := ParseLogBatch(bodyContent, r.Header.Get("content-type"), r.URL.Query().Get("format"), s.parsers.For(name))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("content-type", jsonContentType)
+	if len(logs) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&result)
+		return
+	}
+	s.recordAccessLogs(name, logs)
 	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(&result)
+}
+
+// streamLogs pushes the logs of an app as they are recorded, over a websocket if the client asks
+// for an upgrade and as server sent events otherwise. The query filters the logs, if it has a
+// limit that many of the latest matching logs are sent first.
+func (s *ApiServer) streamLogs(w http.ResponseWriter, r *http.Request, name string) {
+	query, err := ParseLogQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sub, backlog := s.subscribeLogs(name, query)
+	defer s.tail.Unsubscribe(sub)
+
+	if isWebSocketUpgrade(r) {
+		s.streamLogsWebSocket(w, r, sub, backlog)
+		return
+	}
+	s.streamLogsEvents(w, r, sub, backlog)
+}
+
+// subscribeLogs subscribes to the logs of an app and returns the backlog the query asks for, the
+// oldest first. No log is recorded in between, so none is in both.
+func (s *ApiServer) subscribeLogs(name string, query LogQuery) (*TailSubscription, AccessLogs) {
+	s.recording.Lock()
+	defer s.recording.Unlock()
+	sub := s.tail.Subscribe(name, query, DefaultTailBuffer)
+	var backlog AccessLogs
+	if query.Limit > 0 {
+		query.Desc = true
+		latest, _ := query.Apply(s.store.GetApp(name))
+		for i := len(latest) - 1; i >= 0; i-- {
+			backlog = append(backlog, latest[i])
+		}
+	}
+	return sub, backlog
+}
+
+func (s *ApiServer) streamLogsEvents(w http.ResponseWriter, r *http.Request, sub *TailSubscription, backlog AccessLogs) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("content-type", eventStreamContentType)
+	w.Header().Set("cache-control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	writeLog := func(log AccessLog) error {
+		data, _ := json.Marshal(log)
+		_, err := fmt.Fprintf(w, "data: %s\n\n", data)
+		return err
+	}
+	for _, l := range backlog {
+		writeLog(l)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case l := <-sub.Logs:
+			if dropped := sub.Dropped(); dropped > 0 {
+				fmt.Fprintf(w, "event: dropped\ndata: %d\n\n", dropped)
+			}
+			err = writeLog(l)
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// tailMessage is sent for every log or every gap of dropped logs over a websocket.
+type tailMessage struct {
+	Log     *AccessLog `json:"log,omitempty"`
+	Dropped int64      `json:"dropped,omitempty"`
+}
+
+func (s *ApiServer) streamLogsWebSocket(w http.ResponseWriter, r *http.Request, sub *TailSubscription, backlog AccessLogs) {
+	ws, err := acceptWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	send := func(m tailMessage) error {
+		data, _ := json.Marshal(m)
+		return ws.WriteText(data)
+	}
+	for i := range backlog {
+		if send(tailMessage{Log: &backlog[i]}) != nil {
+			return
+		}
+	}
+	for {
+		select {
+		case <-ws.Closed():
+			return
+		case l := <-sub.Logs:
+			if dropped := sub.Dropped(); dropped > 0 {
+				send(tailMessage{Dropped: dropped})
+			}
+			if send(tailMessage{Log: &l}) != nil {
+				return
+			}
+		}
+	}
 }
 
 func (s *ApiServer) showHealth(w http.ResponseWriter, name string) {
@@ -252,6 +836,55 @@ func (s *ApiServer) showHealth(w http.ResponseWriter, name string) {
 	json.NewEncoder(w).Encode(&health)
 }
 
+// showHealthHistory returns the health records of an app between the from and to parameters.
+func (s *ApiServer) showHealthHistory(w http.ResponseWriter, r *http.Request, name string) {
+	from, err := parseQueryTime(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid from, %v", err), http.StatusBadRequest)
+		return
+	}
+	to, err := parseQueryTime(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid to, %v", err), http.StatusBadRequest)
+		return
+	}
+	app := s.store.GetApp(name)
+	if app == nil {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+	records := app.HealthHistoryBetween(from, to)
+	if len(records) > 0 {
+		last := records[len(records)-1].To
+		records = MarkMaintenance(records, maintenanceBetween(s.silences, name, records[0].From, last))
+	}
+	w.Header().Set("content-type", jsonContentType)
+	json.NewEncoder(w).Encode(&records)
+}
+
+func (s *ApiServer) showUptime(w http.ResponseWriter, name string) {
+	app := s.store.GetApp(name)
+	if app == nil {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+	now := time.Now()
+	uptimes := app.UptimesExcluding(now, maintenanceOf(s.silences, name, now))
+	w.Header().Set("content-type", jsonContentType)
+	json.NewEncoder(w).Encode(&uptimes)
+}
+
+// showTargets returns the latest health of every target of an app.
+func (s *ApiServer) showTargets(w http.ResponseWriter, name string) {
+	targets := []HealthCheck{}
+	app := s.store.GetApp(name)
+	if app != nil {
+		targets = append(targets, app.Targets...)
+	}
+	w.Header().Set("content-type", jsonContentType)
+	json.NewEncoder(w).Encode(&targets)
+}
+
 func (s *ApiServer) processHealth(w http.ResponseWriter, name string, body io.ReadCloser) {
 	parsedCheck, err := NewHealthCheck(body)
 	if err != nil {
@@ -262,8 +895,76 @@ func (s *ApiServer) processHealth(w http.ResponseWriter, name string, body io.Re
 	w.WriteHeader(http.StatusAccepted)
 }
 
+func (s *ApiServer) showEvents(w http.ResponseWriter, name string) {
+	events := []ProcessEvent{}
+	app := s.store.GetApp(name)
+	if app != nil {
+		events = append(events, app.Events...)
+	}
+	w.Header().Set("content-type", jsonContentType)
+	json.NewEncoder(w).Encode(&events)
+}
+
+func (s *ApiServer) processEvent(w http.ResponseWriter, name string, body io.ReadCloser) {
+	event, err := NewProcessEvent(body)
+	if err != nil {
+		http.Error(w, "can't read body", http.StatusBadRequest)
+		return
+	}
+	s.store.RecordProcessEvent(name, *event)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *ApiServer) showAppLogs(w http.ResponseWriter, r *http.Request, name string) {
+	query, err := ParseAppLogQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logs := query.Apply(s.store.GetApp(name))
+	w.Header().Set("content-type", jsonContentType)
+	json.NewEncoder(w).Encode(&logs)
+}
+
+// processAppLogs records a batch of application logs and reports which of them were accepted.
+func (s *ApiServer) processAppLogs(w http.ResponseWriter, r *http.Request, name string) {
+	bodyContent, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxBatchBytes))
+	if err != nil {
+		http.Error(w, "can't read body", http.StatusBadRequest)
+		return
+	}
+	logs, result, err := ParseAppLogBatch(bodyContent, r.Header.Get("content-type"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("content-type", jsonContentType)
+	if len(logs) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&result)
+		return
+	}
+	s.store.RecordAppLogs(name, logs)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(&result)
+}
+
 type handler func(w http.ResponseWriter, r *http.Request)
 
+// basicAuthUnlessGet lets GET requests pass and asks the others, which change something, for the
+// credentials like basicAuth.
+func basicAuthUnlessGet(pass handler, securityInfo SecurityUserInfo) handler {
+	authorized := basicAuth(pass, securityInfo)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			pass(w, r)
+			return
+		}
+		authorized(w, r)
+	}
+}
+
 func basicAuth(pass handler, securityInfo SecurityUserInfo) handler {
 	return func(w http.ResponseWriter, r *http.Request) {
 		u, p, ok := r.BasicAuth()
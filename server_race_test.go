@@ -0,0 +1,82 @@
+package mond
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentIngestAndDashboard is meant to be run with the race detector, go test -race.
+func TestConcurrentIngestAndDashboard(t *testing.T) {
+	const writers = 8
+	const readers = 8
+	const requests = 10
+
+	stores := map[string]func(t *testing.T) (AccessLogStore, func()){
+		"stub store": func(t *testing.T) (AccessLogStore, func()) {
+			return &StubLogStore{}, func() {}
+		},
+		"file system store": func(t *testing.T) (AccessLogStore, func()) {
+			database, cleanDatabase := createTempFile(t, `[]`)
+			store, err := NewFileSystemAppsStore(database)
+			assertNoError(t, err)
+			store.tape.sync = false
+			return store, cleanDatabase
+		},
+		"segmented store": func(t *testing.T) (AccessLogStore, func()) {
+			dir, cleanDir := createTempDir(t)
+			store, err := NewSegmentedAppsStore(dir, 4096, false)
+			assertNoError(t, err)
+			return store, func() {
+				store.Close()
+				cleanDir()
+			}
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store, clean := newStore(t)
+			defer clean()
+			server := NewApiServer(store, testInfo)
+			server.ServeHTTP(httptest.NewRecorder(), newPostLogRequest("appa"))
+
+			var wg sync.WaitGroup
+			for i := 0; i < writers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < requests; j++ {
+						server.ServeHTTP(httptest.NewRecorder(), newPostLogRequest("appa"))
+						server.ServeHTTP(httptest.NewRecorder(), newPostHealthRequest("appa"))
+					}
+				}()
+			}
+			for i := 0; i < readers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < requests; j++ {
+						for _, path := range []string{DashboardPath, DashboardLogsPath + "appa", DashboardStatsPath + "appa", DashboardReqsPath + "appa"} {
+							request, _ := http.NewRequest(http.MethodGet, path, nil)
+							request.SetBasicAuth(testInfo.Username, testInfo.Password)
+							response := httptest.NewRecorder()
+							server.ServeHTTP(response, request)
+							assertStatus(t, response.Code, http.StatusOK)
+						}
+						server.ServeHTTP(httptest.NewRecorder(), newGetLogsRequest("appa"))
+					}
+				}()
+			}
+			wg.Wait()
+
+			got := len(store.GetAccessLogs("appa"))
+			want := writers*requests + 1
+			if got != want {
+				t.Errorf("got %d logs want %d", got, want)
+			}
+			assertHealthEquals(t, store.GetHealth("appa"), HEALTHY)
+		})
+	}
+}
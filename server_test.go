@@ -8,7 +8,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 const SampleLogA1 = "Log a1"
@@ -35,7 +37,7 @@ func TestGETHome(t *testing.T) {
 		request, _ := http.NewRequest(http.MethodGet, DashboardPath, nil)
 		request.SetBasicAuth(testInfo.Username, testInfo.Password)
 		response := httptest.NewRecorder()
-		emptyStore := StubLogStore{[]App{{
+		emptyStore := StubLogStore{AppAccessLogs: []App{{
 			Name:   "Test",
 			Health: HealthCheck{},
 			Logs:   nil,
@@ -50,7 +52,7 @@ func TestGETHome(t *testing.T) {
 		request, _ := http.NewRequest(http.MethodGet, DashboardPath, nil)
 		request.SetBasicAuth("some", "other")
 		response := httptest.NewRecorder()
-		emptyStore := StubLogStore{[]App{}}
+		emptyStore := StubLogStore{AppAccessLogs: []App{}}
 		server := NewApiServer(&emptyStore, testInfo)
 		server.ServeHTTP(response, request)
 
@@ -61,12 +63,48 @@ func TestGETHome(t *testing.T) {
 		request, _ := http.NewRequest(http.MethodGet, DashboardPath, nil)
 		request.SetBasicAuth(testInfo.Username, testInfo.Password)
 		response := httptest.NewRecorder()
-		emptyStore := StubLogStore{[]App{}}
+		emptyStore := StubLogStore{AppAccessLogs: []App{}}
 		server := NewApiServer(&emptyStore, testInfo)
 		server.ServeHTTP(response, request)
 
 		assertStatus(t, response.Code, http.StatusNotFound)
 	})
+
+	t.Run("shows the active alerts", func(t *testing.T) {
+		store := StubLogStore{}
+		store.RecordHealth("appa", HealthCheck{Status: "DOWN", Timestamp: 1})
+		alerter := NewAlerter([]AlertRule{{Name: "appa-down", Type: AlertHealth}})
+		alerter.Evaluate(store.GetApps(), time.Now())
+		server := NewApiServer(&store, testInfo)
+		server.SetAlerter(alerter)
+		request, _ := http.NewRequest(http.MethodGet, DashboardPath, nil)
+		request.SetBasicAuth(testInfo.Username, testInfo.Password)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		if body := response.Body.String(); strings.Count(body, "appa-down") != 2 || !strings.Contains(body, "appa is DOWN") {
+			t.Errorf("expected the alert in the banner and on the card, got %s", body)
+		}
+	})
+
+	t.Run("shows the deliveries of notifications", func(t *testing.T) {
+		RegisterNotifier("stub-dashboard", func(ChannelConfig) (Notifier, error) { return &stubNotifier{failures: 1}, nil })
+		dispatcher, _ := NewDispatcher(NotifyConfig{Retries: -1, Channels: []ChannelConfig{{Name: "ops", Type: "stub-dashboard"}}})
+		dispatcher.Notify(Notification{App: "appa", State: "DOWN", Message: "appa is DOWN"})
+		dispatcher.Wait()
+		server := NewApiServer(&StubLogStore{}, testInfo)
+		server.SetDispatcher(dispatcher)
+		request, _ := http.NewRequest(http.MethodGet, DashboardNotificationsPath, nil)
+		request.SetBasicAuth(testInfo.Username, testInfo.Password)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		if body := response.Body.String(); !strings.Contains(body, "ops") || !strings.Contains(body, "failed: unavailable") {
+			t.Errorf("expected the failed delivery, got %s", body)
+		}
+	})
 }
 
 func TestGETLogsAndHealth(t *testing.T) {
@@ -79,9 +117,9 @@ func TestGETLogsAndHealth(t *testing.T) {
 		{Raw: "log b2"},
 	}
 	store := StubLogStore{
-		[]App{
-			{"appa", HEALTHY, wantedLogsAppA},
-			{"appb", UNHEALTHY, wantedLogsAppB},
+		AppAccessLogs: []App{
+			{Name: "appa", Health: HEALTHY, Logs: wantedLogsAppA},
+			{Name: "appb", Health: UNHEALTHY, Logs: wantedLogsAppB},
 		},
 	}
 	server := NewApiServer(&store, testInfo)
@@ -154,6 +192,66 @@ func TestGETLogsAndHealth(t *testing.T) {
 	})
 }
 
+func TestQueryLogs(t *testing.T) {
+	store := StubLogStore{
+		AppAccessLogs: []App{
+			{Name: "appa", Logs: AccessLogs{
+				{Raw: "log a1", Status: "200"},
+				{Raw: "log a2", Status: "500"},
+				{Raw: "log a3", Status: "502"},
+			}},
+		},
+	}
+	server := NewApiServer(&store, testInfo)
+
+	t.Run("returns a page of filtered logs with the next cursor", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, ApiAccessLogsPath+"appa?status=5xx&limit=1", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertAccessLogsEquals(t, decodeBodyToAccessLogs(t, response.Body), AccessLogs{{Raw: "log a2", Status: "500"}})
+		if got := response.Header().Get(nextCursorHeader); got != "2" {
+			t.Errorf("got next cursor %q want %q", got, "2")
+		}
+	})
+
+	t.Run("returns filtered raw logs", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, ApiRawLogsPath+"appa?q=A3", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		if got := response.Body.String(); got != "log a3\n" {
+			t.Errorf("got %q want %q", got, "log a3\n")
+		}
+	})
+
+	t.Run("rejects invalid filters", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, ApiAccessLogsPath+"appa?sort=sideways", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+	})
+
+	t.Run("renders the filtered dashboard logs", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, DashboardLogsPath+"appa?status=500", nil)
+		request.SetBasicAuth(testInfo.Username, testInfo.Password)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		if !strings.Contains(response.Body.String(), "500") || strings.Contains(response.Body.String(), "502") {
+			t.Errorf("dashboard logs not filtered, got %s", response.Body.String())
+		}
+	})
+}
+
 func TestStoreLogs(t *testing.T) {
 
 	t.Run("it records and analyzes logs on POST", func(t *testing.T) {
@@ -174,6 +272,55 @@ func TestStoreLogs(t *testing.T) {
 		}
 	})
 
+	t.Run("it records a batch of logs on POST", func(t *testing.T) {
+		store := StubLogStore{}
+		server := NewApiServer(&store, testInfo)
+		body := `{"raw":"a"}` + "\n" + `broken` + "\n" + `{"raw":"c"}`
+		request, _ := http.NewRequest(http.MethodPost, ApiAccessLogsPath+"App1", strings.NewReader(body))
+		request.Header.Set("content-type", ndjsonContentType)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusAccepted)
+		var result BatchResult
+		json.NewDecoder(response.Body).Decode(&result)
+		assertBatchResult(t, result, 2, 1)
+		if got := len(store.GetAccessLogs("app1")); got != 2 {
+			t.Errorf("got %d logs want 2", got)
+		}
+	})
+
+	t.Run("it reads raw logs in the format of the app", func(t *testing.T) {
+		store := StubLogStore{}
+		server := NewApiServer(&store, testInfo)
+		parsers, err := NewLogParsers(LogsConfig{Apps: map[string]LogFormatConfig{"app1": {Format: LogFormatLogfmt, Fields: map[string]string{FieldPath: "route"}}}})
+		assertNoError(t, err)
+		server.SetLogParsers(parsers)
+		request, _ := http.NewRequest(http.MethodPost, ApiAccessLogsPath+"App1", strings.NewReader("route=/a status=503"))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusAccepted)
+		logs := store.GetAccessLogs("app1")
+		if len(logs) != 1 {
+			t.Fatalf("got %d logs want 1", len(logs))
+		}
+		assertAccessLogEquals(t, logs[0], AccessLog{Path: "/a", Status: "503", Raw: "route=/a status=503"})
+	})
+
+	t.Run("it rejects a batch without logs", func(t *testing.T) {
+		store := StubLogStore{}
+		server := NewApiServer(&store, testInfo)
+		request, _ := http.NewRequest(http.MethodPost, ApiAccessLogsPath+"App1", strings.NewReader("\n\n"))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+		if len(store.AppAccessLogs) != 0 {
+			t.Errorf("expected no logs to be recorded, got %v", store.AppAccessLogs)
+		}
+	})
+
 	t.Run("it records health on POST", func(t *testing.T) {
 		store := StubLogStore{}
 		server := NewApiServer(&store, testInfo)
@@ -189,6 +336,179 @@ func TestStoreLogs(t *testing.T) {
 		}
 		assertHealthEquals(t, store.AppAccessLogs[0].Health, HEALTHY)
 	})
+
+	t.Run("it records process events on POST and returns them on GET", func(t *testing.T) {
+		store := StubLogStore{}
+		server := NewApiServer(&store, testInfo)
+		body := `{"type":"crash","timestamp":1,"exitCode":2,"uptime":5,"restarts":1}`
+		request, _ := http.NewRequest(http.MethodPost, ApiEventsPath+"AppA", strings.NewReader(body))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusAccepted)
+
+		request, _ = http.NewRequest(http.MethodGet, ApiEventsPath+"appa", nil)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertContentType(t, response, jsonContentType)
+		var events []ProcessEvent
+		json.NewDecoder(response.Body).Decode(&events)
+		want := []ProcessEvent{{Type: ProcessCrashed, Timestamp: 1, ExitCode: 2, Uptime: 5, Restarts: 1}}
+		if !reflect.DeepEqual(events, want) {
+			t.Errorf("got %v want %v", events, want)
+		}
+	})
+
+	t.Run("it records app logs on POST and filters them on GET", func(t *testing.T) {
+		store := StubLogStore{}
+		server := NewApiServer(&store, testInfo)
+		body := `{"unix":1,"stream":"stderr","raw":"2021-07-02 22:50:59 ERROR [main] com.example.App - failed\n\tat com.example.App.main(App.java:5)"}
+{"unix":2,"stream":"stdout","raw":"{\"level\":\"info\",\"msg\":\"started\"}"}`
+		request, _ := http.NewRequest(http.MethodPost, ApiAppLogsPath+"AppA", strings.NewReader(body))
+		request.Header.Set("content-type", ndjsonContentType)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusAccepted)
+		var result BatchResult
+		json.NewDecoder(response.Body).Decode(&result)
+		assertBatchResult(t, result, 2, 0)
+
+		request, _ = http.NewRequest(http.MethodGet, ApiAppLogsPath+"appa?level=warn", nil)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertContentType(t, response, jsonContentType)
+		var logs []AppLog
+		json.NewDecoder(response.Body).Decode(&logs)
+		if len(logs) != 1 || logs[0].Level != LevelError || logs[0].Logger != "com.example.App" || logs[0].Message != "failed" {
+			t.Errorf("got %+v want the error of com.example.App", logs)
+		}
+
+		request, _ = http.NewRequest(http.MethodDelete, ApiAppLogsPath+"appa", nil)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusMethodNotAllowed)
+	})
+
+	t.Run("it adds, lists and expires silences", func(t *testing.T) {
+		silences, _ := LoadSilences("")
+		server := NewApiServer(&StubLogStore{}, testInfo)
+		server.SetSilences(silences)
+		body := `{"app":"appa","rule":"down","end":4102444800,"comment":"deploy"}`
+		request, _ := http.NewRequest(http.MethodPost, ApiSilencesPath, strings.NewReader(body))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+
+		request, _ = http.NewRequest(http.MethodPost, ApiSilencesPath, strings.NewReader(body))
+		request.SetBasicAuth(testInfo.Username, testInfo.Password)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusCreated)
+		var added Silence
+		json.NewDecoder(response.Body).Decode(&added)
+		if added.Id == "" || added.Start == 0 || added.Comment != "deploy" {
+			t.Errorf("got %+v want the silence with id and start", added)
+		}
+
+		request, _ = http.NewRequest(http.MethodGet, ApiSilencesPath, nil)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		var listed []Silence
+		json.NewDecoder(response.Body).Decode(&listed)
+		if !reflect.DeepEqual(listed, []Silence{added}) {
+			t.Errorf("got %+v want %+v", listed, added)
+		}
+
+		request, _ = http.NewRequest(http.MethodDelete, ApiSilencesPath+added.Id, nil)
+		request.SetBasicAuth(testInfo.Username, testInfo.Password)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusNoContent)
+		if got := silences.List(time.Now()); len(got) != 0 {
+			t.Errorf("got %v want the silence expired", got)
+		}
+
+		request, _ = http.NewRequest(http.MethodPost, ApiSilencesPath, strings.NewReader(`{"app":"appa"}`))
+		request.SetBasicAuth(testInfo.Username, testInfo.Password)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+	})
+
+	t.Run("it adds maintenance windows from the dashboard", func(t *testing.T) {
+		silences, _ := LoadSilences("")
+		server := NewApiServer(&StubLogStore{}, testInfo)
+		server.SetSilences(silences)
+		form := "app=AppA&at=02:00&length=30m&days=sat&days=sun&comment=backup"
+		request, _ := http.NewRequest(http.MethodPost, DashboardSilencesPath, strings.NewReader(form))
+		request.Header.Set("content-type", "application/x-www-form-urlencoded")
+		request.SetBasicAuth(testInfo.Username, testInfo.Password)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusSeeOther)
+		listed := silences.List(time.Now())
+		want := &Recurrence{Days: []string{"sat", "sun"}, At: "02:00", Duration: Duration(30 * time.Minute)}
+		if len(listed) != 1 || listed[0].App != "appa" || !reflect.DeepEqual(listed[0].Recurrence, want) {
+			t.Fatalf("got %+v want the maintenance window of appa", listed)
+		}
+
+		request, _ = http.NewRequest(http.MethodGet, DashboardSilencesPath, nil)
+		request.SetBasicAuth(testInfo.Username, testInfo.Password)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		if body := response.Body.String(); !strings.Contains(body, "sat,sun at 02:00 for 30m0s") || !strings.Contains(body, listed[0].Id) {
+			t.Errorf("expected the maintenance window to be listed, got %s", body)
+		}
+	})
+
+	t.Run("it returns active and resolved alerts on GET", func(t *testing.T) {
+		store := StubLogStore{}
+		store.RecordHealth("appa", HealthCheck{Status: "DOWN", Timestamp: 1})
+		store.RecordHealth("appb", HealthCheck{Status: "DOWN", Timestamp: 1})
+		alerter := NewAlerter([]AlertRule{{Name: "down", Type: AlertHealth}})
+		alerter.Evaluate(store.GetApps(), time.Unix(100, 0))
+		store.RecordHealth("appb", HEALTHY)
+		alerter.Evaluate(store.GetApps(), time.Unix(200, 0))
+		server := NewApiServer(&store, testInfo)
+		server.SetAlerter(alerter)
+
+		request, _ := http.NewRequest(http.MethodGet, ApiAlertsPath, nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertContentType(t, response, jsonContentType)
+		var alerts []Alert
+		json.NewDecoder(response.Body).Decode(&alerts)
+		want := []Alert{{Rule: "down", App: "appa", State: AlertFiring, Message: "appa is DOWN", Since: 100, FiredAt: 100}}
+		if !reflect.DeepEqual(alerts, want) {
+			t.Errorf("got %v want %v", alerts, want)
+		}
+
+		request, _ = http.NewRequest(http.MethodGet, ApiAlertsPath+ApiResolvedSuffix+"?app=AppB", nil)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		alerts = nil
+		json.NewDecoder(response.Body).Decode(&alerts)
+		if len(alerts) != 1 || alerts[0].App != "appb" || alerts[0].ResolvedAt != 200 {
+			t.Errorf("got %v want the resolved alert of appb", alerts)
+		}
+	})
 }
 
 func decodeBodyToStringArray(t testing.TB, body io.Reader) (logs []string) {
@@ -0,0 +1,345 @@
+package mond
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SilenceMaxAge is how long silences are kept after they ended.
+const SilenceMaxAge = 30 * 24 * time.Hour
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Silence suppresses the notifications of an App, a health Target of it or an alert Rule from
+// Start until End. Empty fields match everything, so a silence for a target leaves out alerts and
+// one for a rule leaves out health notifications. A silence with a Recurrence is a maintenance
+// window, active at every recurrence between Start and End, which may then be 0 for no end.
+// Silences of whole apps, without Target and Rule, are left out of their uptime.
+type Silence struct {
+	Id         string      `json:"id"`
+	App        string      `json:"app,omitempty"`
+	Target     string      `json:"target,omitempty"`
+	Rule       string      `json:"rule,omitempty"`
+	Start      int64       `json:"start"`
+	End        int64       `json:"end,omitempty"`
+	Comment    string      `json:"comment,omitempty"`
+	Recurrence *Recurrence `json:"recurrence,omitempty"`
+}
+
+// Recurrence repeats a silence on Days, every day if there are none, starting At a local time
+// like "02:30" for Duration.
+type Recurrence struct {
+	Days     []string `json:"days,omitempty"`
+	At       string   `json:"at"`
+	Duration Duration `json:"duration"`
+}
+
+// Period is a span of time in unix seconds.
+type Period struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+func (s Silence) Validate() error {
+	if s.Recurrence == nil {
+		if s.End <= s.Start {
+			return fmt.Errorf("silence needs an end after its start")
+		}
+		return nil
+	}
+	if s.End != 0 && s.End <= s.Start {
+		return fmt.Errorf("silence needs an end after its start")
+	}
+	if _, err := time.Parse("15:04", s.Recurrence.At); err != nil {
+		return fmt.Errorf("invalid recurrence time %q, want it like 02:30", s.Recurrence.At)
+	}
+	if s.Recurrence.Duration <= 0 {
+		return fmt.Errorf("recurrence needs a duration")
+	}
+	for _, day := range s.Recurrence.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid day %q, want one of mon, tue, wed, thu, fri, sat or sun", day)
+		}
+	}
+	return nil
+}
+
+// Describe tells when the silence is in effect, like "mon,sat at 02:00 for 30m0s from 01.02.2021 00:00".
+func (s Silence) Describe() string {
+	const layout = "02.01.2006 15:04"
+	if s.Recurrence == nil {
+		return time.Unix(s.Start, 0).Format(layout) + " - " + time.Unix(s.End, 0).Format(layout)
+	}
+	days := "daily"
+	if len(s.Recurrence.Days) > 0 {
+		days = strings.Join(s.Recurrence.Days, ",")
+	}
+	description := fmt.Sprintf("%s at %s for %s from %s", days, s.Recurrence.At, s.Recurrence.Duration, time.Unix(s.Start, 0).Format(layout))
+	if s.End != 0 {
+		description += " until " + time.Unix(s.End, 0).Format(layout)
+	}
+	return description
+}
+
+// IsMaintenance reports whether the silence covers whole apps.
+func (s Silence) IsMaintenance() bool {
+	return s.Target == "" && s.Rule == ""
+}
+
+func (s Silence) matches(n Notification) bool {
+	if s.App != "" && !strings.EqualFold(s.App, n.App) {
+		return false
+	}
+	if s.Target != "" && s.Target != n.Target {
+		return false
+	}
+	return s.Rule == "" || s.Rule == n.Rule
+}
+
+// ActiveAt reports whether the silence is in effect at t.
+func (s Silence) ActiveAt(t time.Time) bool {
+	return len(s.Periods(t.Unix(), t.Unix())) > 0
+}
+
+// Periods returns the spans the silence is in effect between from and to.
+func (s Silence) Periods(from, to int64) []Period {
+	start, end := s.Start, s.End
+	if end == 0 {
+		end = to
+	}
+	if from > start {
+		start = from
+	}
+	if to < end {
+		end = to
+	}
+	if s.Recurrence == nil {
+		if start > end {
+			return nil
+		}
+		return []Period{{From: start, To: end}}
+	}
+
+	var periods []Period
+	at, _ := time.Parse("15:04", s.Recurrence.At)
+	length := time.Duration(s.Recurrence.Duration)
+	first := time.Unix(start, 0).Add(-length)
+	day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.Local)
+	for ; day.Unix() <= end; day = day.AddDate(0, 0, 1) {
+		if !s.Recurrence.on(day.Weekday()) {
+			continue
+		}
+		p := Period{From: time.Date(day.Year(), day.Month(), day.Day(), at.Hour(), at.Minute(), 0, 0, time.Local).Unix()}
+		p.To = p.From + int64(length/time.Second)
+		if p.To < start || p.From > end {
+			continue
+		}
+		if p.From < start {
+			p.From = start
+		}
+		if p.To > end {
+			p.To = end
+		}
+		periods = append(periods, p)
+	}
+	return periods
+}
+
+func (r Recurrence) on(day time.Weekday) bool {
+	if len(r.Days) == 0 {
+		return true
+	}
+	for _, d := range r.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Silences keeps silences in a JSON file, or in memory only if it has no path.
+type Silences struct {
+	mu       sync.RWMutex
+	path     string
+	silences []Silence
+}
+
+// LoadSilences reads the silences stored at path, a missing file holds none.
+func LoadSilences(path string) (*Silences, error) {
+	s := &Silences{path: path}
+	if path == "" {
+		return s, nil
+	}
+	removeStaleTapeFiles(path)
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("problem reading silences %s, %v", path, err)
+	}
+	err = json.Unmarshal(b, &s.silences)
+	if err != nil {
+		return nil, fmt.Errorf("problem parsing silences %s, %v", path, err)
+	}
+	return s, nil
+}
+
+// Add stores a new silence starting now if it has no start and returns it with its id.
+func (s *Silences) Add(silence Silence, now time.Time) (Silence, error) {
+	if silence.Start == 0 {
+		silence.Start = now.Unix()
+	}
+	err := silence.Validate()
+	if err != nil {
+		return silence, err
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	silence.Id = hex.EncodeToString(id)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.silences = append(s.silences, silence)
+	s.save(now)
+	return silence, nil
+}
+
+// Expire ends the silence with the given id now, one which did not start yet is dropped. It
+// reports false if there is none.
+func (s *Silences) Expire(id string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, silence := range s.silences {
+		if silence.Id != id {
+			continue
+		}
+		if silence.Start >= now.Unix() {
+			s.silences = append(s.silences[:i:i], s.silences[i+1:]...)
+		} else if silence.End == 0 || silence.End > now.Unix() {
+			s.silences[i].End = now.Unix()
+		}
+		s.save(now)
+		return true
+	}
+	return false
+}
+
+// List returns the silences which did not end yet, the earliest first.
+func (s *Silences) List(now time.Time) []Silence {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	silences := []Silence{}
+	for _, silence := range s.silences {
+		if silence.End == 0 || silence.End > now.Unix() {
+			silences = append(silences, silence)
+		}
+	}
+	sort.SliceStable(silences, func(i, j int) bool {
+		return silences[i].Start < silences[j].Start
+	})
+	return silences
+}
+
+// Silencing returns the silence which suppresses n at the given time.
+func (s *Silences) Silencing(n Notification, now time.Time) (Silence, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, silence := range s.silences {
+		if silence.matches(n) && silence.ActiveAt(now) {
+			return silence, true
+		}
+	}
+	return Silence{}, false
+}
+
+// MaintenanceOf returns the sorted, merged periods between from and to in which the whole app
+// was silenced.
+func (s *Silences) MaintenanceOf(app string, from, to int64) []Period {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var periods []Period
+	for _, silence := range s.silences {
+		if silence.IsMaintenance() && silence.matches(Notification{App: app}) {
+			periods = append(periods, silence.Periods(from, to)...)
+		}
+	}
+	return mergePeriods(periods)
+}
+
+// save replaces the file with the silences, dropping those which ended long ago. It must be called
+// with the lock held.
+func (s *Silences) save(now time.Time) {
+	cutoff := now.Add(-SilenceMaxAge).Unix()
+	kept := make([]Silence, 0, len(s.silences))
+	for _, silence := range s.silences {
+		if silence.End == 0 || silence.End > cutoff {
+			kept = append(kept, silence)
+		}
+	}
+	s.silences = kept
+	if s.path == "" {
+		return
+	}
+	b, _ := json.Marshal(s.silences)
+	_, err := (&tape{path: s.path, sync: true}).Write(b)
+	if err != nil {
+		fmt.Printf("WARN: problem writing silences %s, %v\n", s.path, err)
+	}
+}
+
+func mergePeriods(periods []Period) []Period {
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].From < periods[j].From
+	})
+	var merged []Period
+	for _, p := range periods {
+		last := len(merged) - 1
+		if last >= 0 && p.From <= merged[last].To {
+			if p.To > merged[last].To {
+				merged[last].To = p.To
+			}
+			continue
+		}
+		merged = append(merged, p)
+	}
+	return merged
+}
+
+// overlap returns how many seconds of from..to lie within the sorted periods.
+func overlap(from, to int64, periods []Period) int64 {
+	var total int64
+	for _, p := range periods {
+		start, end := p.From, p.To
+		if start < from {
+			start = from
+		}
+		if end > to {
+			end = to
+		}
+		if end > start {
+			total += end - start
+		}
+	}
+	return total
+}
+
+func inPeriods(t int64, periods []Period) bool {
+	for _, p := range periods {
+		if p.From <= t && t <= p.To {
+			return true
+		}
+	}
+	return false
+}
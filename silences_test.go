@@ -0,0 +1,173 @@
+package mond
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSilence(t *testing.T) {
+	at := func(day, hour, minute int) int64 {
+		return time.Date(2021, time.March, day, hour, minute, 0, 0, time.Local).Unix()
+	}
+
+	t.Run("validates one-off and recurring silences", func(t *testing.T) {
+		cases := []struct {
+			silence Silence
+			err     string
+		}{
+			{Silence{Start: 10, End: 20}, ""},
+			{Silence{Start: 10, Recurrence: &Recurrence{At: "02:30", Duration: Duration(time.Hour), Days: []string{"Sat", "sun"}}}, ""},
+			{Silence{Start: 10}, "needs an end"},
+			{Silence{Start: 10, End: 5, Recurrence: &Recurrence{At: "02:30", Duration: Duration(time.Hour)}}, "needs an end"},
+			{Silence{Start: 10, Recurrence: &Recurrence{At: "2pm", Duration: Duration(time.Hour)}}, "invalid recurrence time"},
+			{Silence{Start: 10, Recurrence: &Recurrence{At: "02:30"}}, "needs a duration"},
+			{Silence{Start: 10, Recurrence: &Recurrence{At: "02:30", Duration: Duration(time.Hour), Days: []string{"someday"}}}, "invalid day"},
+		}
+		for _, c := range cases {
+			err := c.silence.Validate()
+			if (c.err == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), c.err)) {
+				t.Errorf("got %v want %q for %+v", err, c.err, c.silence)
+			}
+		}
+	})
+
+	t.Run("matches apps, targets and rules", func(t *testing.T) {
+		cases := []struct {
+			silence      Silence
+			notification Notification
+			want         bool
+		}{
+			{Silence{}, Notification{App: "a", Rule: "down"}, true},
+			{Silence{App: "A"}, Notification{App: "a"}, true},
+			{Silence{App: "b"}, Notification{App: "a"}, false},
+			{Silence{App: "a", Target: "api"}, Notification{App: "a", Target: "api"}, true},
+			{Silence{App: "a", Target: "api"}, Notification{App: "a", Target: "db"}, false},
+			{Silence{App: "a", Target: "api"}, Notification{App: "a", Rule: "down"}, false},
+			{Silence{Rule: "down"}, Notification{App: "a", Rule: "down"}, true},
+			{Silence{Rule: "down"}, Notification{App: "a", Target: "api"}, false},
+		}
+		for _, c := range cases {
+			if got := c.silence.matches(c.notification); got != c.want {
+				t.Errorf("got %v want %v for %+v and %+v", got, c.want, c.silence, c.notification)
+			}
+		}
+	})
+
+	t.Run("recurs on the given days within its bounds", func(t *testing.T) {
+		// 1 March 2021 is a Monday
+		silence := Silence{Start: at(2, 0, 0), End: at(9, 0, 0), Recurrence: &Recurrence{Days: []string{"tue", "fri"}, At: "23:30", Duration: Duration(time.Hour)}}
+
+		got := silence.Periods(at(1, 0, 0), at(31, 0, 0))
+
+		want := []Period{
+			{From: at(2, 23, 30), To: at(3, 0, 30)},
+			{From: at(5, 23, 30), To: at(6, 0, 30)},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v want %v", got, want)
+		}
+		if !silence.ActiveAt(time.Unix(at(3, 0, 10), 0)) || silence.ActiveAt(time.Unix(at(3, 1, 0), 0)) {
+			t.Error("expected the silence to be active past midnight only for its duration")
+		}
+		if silence.ActiveAt(time.Unix(at(9, 23, 45), 0)) {
+			t.Error("expected the silence to end with its end")
+		}
+	})
+
+	t.Run("describes when it is in effect", func(t *testing.T) {
+		silence := Silence{Start: at(1, 0, 0), Recurrence: &Recurrence{Days: []string{"sat"}, At: "02:00", Duration: Duration(time.Hour)}}
+
+		if got, want := silence.Describe(), "sat at 02:00 for 1h0m0s from 01.03.2021 00:00"; got != want {
+			t.Errorf("got %q want %q", got, want)
+		}
+	})
+}
+
+func TestSilences(t *testing.T) {
+	now := time.Unix(100000, 0)
+
+	t.Run("silences matching notifications while active", func(t *testing.T) {
+		silences, _ := LoadSilences("")
+		active, err := silences.Add(Silence{App: "a", End: now.Unix() + 60, Comment: "deploy"}, now)
+		assertNoError(t, err)
+		silences.Add(Silence{App: "b", Start: now.Unix() + 60, End: now.Unix() + 120}, now)
+
+		if got, ok := silences.Silencing(Notification{App: "a"}, now); !ok || got.Id != active.Id || got.Start != now.Unix() {
+			t.Errorf("got %+v %v want the silence of a", got, ok)
+		}
+		if _, ok := silences.Silencing(Notification{App: "b"}, now); ok {
+			t.Error("expected the silence of b not to have started")
+		}
+		if _, ok := silences.Silencing(Notification{App: "a"}, now.Add(2*time.Minute)); ok {
+			t.Error("expected the silence of a to have ended")
+		}
+	})
+
+	t.Run("expires silences", func(t *testing.T) {
+		silences, _ := LoadSilences("")
+		started, _ := silences.Add(Silence{App: "a", End: now.Unix() + 60}, now.Add(-time.Minute))
+		planned, _ := silences.Add(Silence{App: "b", Start: now.Unix() + 60, End: now.Unix() + 120}, now)
+
+		if !silences.Expire(started.Id, now) || !silences.Expire(planned.Id, now) || silences.Expire("nope", now) {
+			t.Fatal("expected to find the silences and only them")
+		}
+
+		if got := silences.List(now); len(got) != 0 {
+			t.Errorf("got %v want no silences left", got)
+		}
+		if got := silences.MaintenanceOf("a", 0, now.Unix()); !reflect.DeepEqual(got, []Period{{From: now.Unix() - 60, To: now.Unix()}}) {
+			t.Errorf("got maintenance %v want it to end now", got)
+		}
+	})
+
+	t.Run("merges the maintenance of whole apps", func(t *testing.T) {
+		silences, _ := LoadSilences("")
+		silences.Add(Silence{Start: 100, End: 200}, now)
+		silences.Add(Silence{App: "a", Start: 150, End: 300}, now)
+		silences.Add(Silence{App: "a", Start: 400, End: 500}, now)
+		silences.Add(Silence{App: "a", Target: "api", Start: 600, End: 700}, now)
+		silences.Add(Silence{App: "b", Start: 800, End: 900}, now)
+
+		got := silences.MaintenanceOf("a", 0, 450)
+
+		want := []Period{{From: 100, To: 300}, {From: 400, To: 450}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v want %v", got, want)
+		}
+	})
+
+	t.Run("keeps silences in a file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "silences")
+		assertNoError(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "silences.json")
+
+		silences, err := LoadSilences(path)
+		assertNoError(t, err)
+		added, _ := silences.Add(Silence{App: "a", End: now.Unix() + 60, Recurrence: &Recurrence{At: "02:00", Duration: Duration(time.Hour)}}, now)
+
+		reopened, err := LoadSilences(path)
+		assertNoError(t, err)
+		if got := reopened.List(now); !reflect.DeepEqual(got, []Silence{added}) {
+			t.Errorf("got %+v want %+v", got, added)
+		}
+	})
+
+	t.Run("drops silences which ended long ago", func(t *testing.T) {
+		silences, _ := LoadSilences("")
+		old, _ := silences.Add(Silence{App: "a", Start: 10, End: 20}, now)
+
+		later := now.Add(SilenceMaxAge)
+		_, err := silences.Add(Silence{App: "b", End: later.Unix() + 60}, later)
+		assertNoError(t, err)
+
+		if len(silences.MaintenanceOf("a", 0, now.Unix())) != 0 {
+			t.Errorf("expected %+v to be dropped", old)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package mond
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const spoolFileSuffix = ".batch"
+
+// Spool keeps batches of log lines on disk, one file per batch, until they can be delivered.
+// When it grows beyond maxBytes the oldest batches are dropped.
+type Spool struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	files    []spoolFile
+	size     int64
+	seq      int
+}
+
+type spoolFile struct {
+	name string
+	size int64
+}
+
+// OpenSpool opens the spool in dir, creating it if needed and keeping the batches found there.
+func OpenSpool(dir string, maxBytes int64) (*Spool, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("problem creating spool dir %s, %v", dir, err)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("problem reading spool dir %s, %v", dir, err)
+	}
+
+	s := &Spool{dir: dir, maxBytes: maxBytes}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), spoolFileSuffix) {
+			continue
+		}
+		s.files = append(s.files, spoolFile{name: e.Name(), size: e.Size()})
+		s.size += e.Size()
+	}
+	sort.Slice(s.files, func(i, j int) bool {
+		return s.files[i].name < s.files[j].name
+	})
+	return s, nil
+}
+
+// Put writes batch as newest file of the spool.
+func (s *Spool) Put(batch []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	name := fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), s.seq%1000000, spoolFileSuffix)
+	content := []byte(strings.Join(batch, "\n"))
+	_, err := (&tape{path: filepath.Join(s.dir, name), sync: true}).Write(content)
+	if err != nil {
+		return fmt.Errorf("problem spooling batch, %v", err)
+	}
+	s.files = append(s.files, spoolFile{name: name, size: int64(len(content))})
+	s.size += int64(len(content))
+
+	for s.size > s.maxBytes && len(s.files) > 1 {
+		oldest := s.files[0]
+		fmt.Printf("WARN: spool full, dropping batch %s\n", oldest.name)
+		s.remove(oldest.name)
+	}
+	return nil
+}
+
+// Oldest returns the oldest batch in the spool, ok is false if the spool is empty.
+func (s *Spool) Oldest() (name string, batch []string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.files) > 0 {
+		name = s.files[0].name
+		content, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			fmt.Printf("WARN: dropping unreadable spooled batch %s, %v\n", name, err)
+			s.remove(name)
+			continue
+		}
+		return name, strings.Split(string(content), "\n"), true
+	}
+	return "", nil, false
+}
+
+// Remove deletes a delivered batch.
+func (s *Spool) Remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(name)
+}
+
+func (s *Spool) remove(name string) {
+	for i, f := range s.files {
+		if f.name == name {
+			os.Remove(filepath.Join(s.dir, name))
+			s.size -= f.size
+			s.files = append(s.files[:i], s.files[i+1:]...)
+			return
+		}
+	}
+}
+
+// Len returns the number of spooled batches.
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.files)
+}
@@ -0,0 +1,61 @@
+package mond
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSpool(t *testing.T) {
+	t.Run("returns batches oldest first", func(t *testing.T) {
+		dir, cleanDir := createTempDir(t)
+		defer cleanDir()
+		spool, err := OpenSpool(dir, 1024)
+		assertNoError(t, err)
+
+		spool.Put([]string{"a", "b"})
+		spool.Put([]string{"c"})
+
+		name, batch, ok := spool.Oldest()
+		if !ok {
+			t.Fatal("expected a batch")
+		}
+		assertStringArray(t, batch, []string{"a", "b"})
+		spool.Remove(name)
+
+		_, batch, _ = spool.Oldest()
+		assertStringArray(t, batch, []string{"c"})
+	})
+
+	t.Run("drops the oldest batches when full", func(t *testing.T) {
+		dir, cleanDir := createTempDir(t)
+		defer cleanDir()
+		spool, err := OpenSpool(dir, 25)
+		assertNoError(t, err)
+
+		for _, l := range []string{"first", "second", "third"} {
+			spool.Put([]string{strings.Repeat(l, 2)})
+		}
+
+		if spool.Len() != 2 {
+			t.Errorf("got %d batches want 2", spool.Len())
+		}
+		_, batch, _ := spool.Oldest()
+		assertStringArray(t, batch, []string{"secondsecond"})
+	})
+
+	t.Run("reopens existing batches", func(t *testing.T) {
+		dir, cleanDir := createTempDir(t)
+		defer cleanDir()
+		spool, _ := OpenSpool(dir, 1024)
+		spool.Put([]string{"a"})
+
+		spool, err := OpenSpool(dir, 1024)
+		assertNoError(t, err)
+
+		_, batch, ok := spool.Oldest()
+		if !ok {
+			t.Fatal("expected a batch")
+		}
+		assertStringArray(t, batch, []string{"a"})
+	})
+}
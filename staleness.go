@@ -0,0 +1,150 @@
+package mond
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// HealthStale is the status of an app which did not report its health in time.
+const HealthStale = "STALE"
+
+const DefaultStaleAfter = Duration(3 * time.Minute)
+const DefaultStalenessInterval = Duration(30 * time.Second)
+
+// StalenessConfig sets how long an app may go without a health report before it counts as stale.
+type StalenessConfig struct {
+	Interval Duration            `json:"interval,omitempty"`
+	Default  Duration            `json:"default,omitempty"`
+	Apps     map[string]Duration `json:"apps,omitempty"`
+}
+
+// lowerAppNames keys the intervals by lower case app names, the names the API records health under.
+func (c *StalenessConfig) lowerAppNames() {
+	apps := make(map[string]Duration, len(c.Apps))
+	for name, d := range c.Apps {
+		apps[strings.ToLower(name)] = d
+	}
+	c.Apps = apps
+}
+
+// StaleAfter returns the expected reporting interval of an app.
+func (c StalenessConfig) StaleAfter(name string) Duration {
+	if d, ok := c.Apps[name]; ok && d > 0 {
+		return d
+	}
+	if c.Default > 0 {
+		return c.Default
+	}
+	return DefaultStaleAfter
+}
+
+// FindStale returns the apps whose last health report is older than their expected interval.
+// Reports are never expected before started, so apps are not stale just because the apiserver was down.
+// Apps which never reported their health, like those only sending logs, are not expected to.
+func FindStale(apps Apps, config StalenessConfig, started, now time.Time) []string {
+	var stale []string
+	for _, app := range apps {
+		if app.Stale || app.Name == MondAppName {
+			continue
+		}
+		last := app.LastHealthReport()
+		if last == 0 && len(app.Targets) == 0 {
+			continue
+		}
+		if last < started.Unix() {
+			last = started.Unix()
+		}
+		if now.Sub(time.Unix(last, 0)) > time.Duration(config.StaleAfter(app.Name)) {
+			stale = append(stale, app.Name)
+		}
+	}
+	return stale
+}
+
+// EnforceStaleness marks all apps found by FindStale as stale and returns their names.
+func EnforceStaleness(store AccessLogStore, config StalenessConfig, started, now time.Time) []string {
+	stale := FindStale(store.GetApps(), config, started, now)
+	for _, name := range stale {
+		store.MarkHealthStale(name, now.Unix())
+	}
+	return stale
+}
+
+// StartStaleness looks for stale apps every interval until the returned func is called.
+func StartStaleness(store AccessLogStore, config StalenessConfig) func() {
+	interval := config.Interval
+	if interval <= 0 {
+		interval = DefaultStalenessInterval
+	}
+	started := time.Now()
+	ticker := time.NewTicker(time.Duration(interval))
+	quit := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case now := <-ticker.C:
+				for _, name := range EnforceStaleness(store, config, started, now) {
+					fmt.Printf("WARN: no health report from %s for %s\n", name, time.Duration(config.StaleAfter(name)))
+				}
+			case <-quit:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+	}
+}
+
+// CurrentHealth returns the last reported health, or STALE since the time the app went stale.
+func (a *App) CurrentHealth() HealthCheck {
+	if a.Stale {
+		return HealthCheck{Status: HealthStale, Timestamp: a.HealthSince}
+	}
+	return a.Health
+}
+
+// LastHealthReport returns when the apiserver received the last health report, falling back to
+// the time the client put into it for apps recorded before receipt times were kept.
+func (a *App) LastHealthReport() int64 {
+	if a.HealthReceived != 0 {
+		return a.HealthReceived
+	}
+	return a.Health.Timestamp
+}
+
+// GetHealthSinceFormatted returns when the app switched to its current health status.
+func (a *App) GetHealthSinceFormatted() string {
+	return time.Unix(a.HealthSince, 0).Format("02.01.2006 15:04:05")
+}
+
+// recordHealth stores a health report received at the given time. A report for a target updates
+// that target and the app's health is derived from all of them. A report without target is the
+// health of the whole app and replaces the targets.
+func (a *App) recordHealth(check HealthCheck, received int64) {
+	previous := a.CurrentHealth().Status
+	if check.Target == "" {
+		a.Targets = nil
+	} else {
+		a.Targets = withTarget(a.Targets, check)
+		check = deriveHealth(a.Targets)
+	}
+	a.Health = check
+	a.HealthReceived = received
+	a.Stale = false
+	if a.HealthSince == 0 || previous != check.Status {
+		a.HealthSince = received
+	}
+	a.addHealthRecord(check.Status, received)
+}
+
+func (a *App) markStale(at int64) {
+	if a.Stale {
+		return
+	}
+	a.Stale = true
+	a.HealthSince = at
+	a.addHealthRecord(HealthStale, at)
+}
@@ -0,0 +1,82 @@
+package mond
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStaleness(t *testing.T) {
+	started := time.Unix(1000, 0)
+	config := StalenessConfig{Default: Duration(time.Minute), Apps: map[string]Duration{"slow": Duration(time.Hour)}}
+
+	t.Run("finds apps which missed their reporting interval", func(t *testing.T) {
+		apps := Apps{
+			{Name: "fresh", HealthReceived: 1950},
+			{Name: "late", HealthReceived: 1900},
+			{Name: "slow", HealthReceived: 1900},
+			{Name: "old", Health: HealthCheck{Status: "UP", Timestamp: 1800}},
+			{Name: "already", HealthReceived: 1000, Stale: true},
+			{Name: MondAppName, HealthReceived: 1000},
+		}
+
+		got := FindStale(apps, config, started, time.Unix(2000, 0))
+
+		want := []string{"late", "old"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v want %v", got, want)
+		}
+	})
+
+	t.Run("waits a full interval after the start before calling an app stale", func(t *testing.T) {
+		apps := Apps{{Name: "app", HealthReceived: 10}}
+
+		if got := FindStale(apps, config, started, started.Add(30*time.Second)); len(got) != 0 {
+			t.Errorf("got %v want no stale apps", got)
+		}
+	})
+
+	t.Run("does not expect health reports from apps which only send logs", func(t *testing.T) {
+		apps := Apps{{Name: "logsonly", Logs: AccessLogs{{Raw: "GET /"}}}}
+
+		if got := FindStale(apps, config, started, time.Unix(2000, 0)); len(got) != 0 {
+			t.Errorf("got %v want no stale apps", got)
+		}
+	})
+
+	t.Run("flips the health to STALE until the next report arrives", func(t *testing.T) {
+		store := &StubLogStore{}
+		store.RecordHealth("app", HEALTHY)
+		now := time.Now().Add(2 * time.Minute)
+
+		stale := EnforceStaleness(store, config, time.Unix(0, 0), now)
+
+		if len(stale) != 1 {
+			t.Fatalf("got stale apps %v want app", stale)
+		}
+		assertHealthEquals(t, store.GetHealth("app"), HealthCheck{Status: HealthStale, Timestamp: now.Unix()})
+		if since := store.GetApp("app").HealthSince; since != now.Unix() {
+			t.Errorf("got health since %d want %d", since, now.Unix())
+		}
+
+		store.RecordHealth("app", HEALTHY)
+
+		assertHealthEquals(t, store.GetHealth("app"), HEALTHY)
+		if app := store.GetApp("app"); app.Stale || app.HealthSince == now.Unix() {
+			t.Errorf("expected the report to end the stale state, got %+v", app)
+		}
+	})
+
+	t.Run("keeps the transition time while the status does not change", func(t *testing.T) {
+		app := App{Name: "app"}
+		app.recordHealth(HEALTHY, 10)
+		app.recordHealth(HEALTHY, 20)
+		if app.HealthSince != 10 {
+			t.Errorf("got health since %d want 10", app.HealthSince)
+		}
+		app.recordHealth(UNHEALTHY, 30)
+		if app.HealthSince != 30 {
+			t.Errorf("got health since %d want 30", app.HealthSince)
+		}
+	})
+}
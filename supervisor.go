@@ -0,0 +1,257 @@
+package mond
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultMaxRestarts       = 10
+	DefaultRestartMinBackoff = time.Second
+	DefaultRestartMaxBackoff = time.Minute
+	DefaultStableAfter       = 5 * time.Minute
+	DefaultStopGracePeriod   = 10 * time.Second
+)
+
+// Streams a supervised process writes to.
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// SupervisorConfig sets how a Supervisor restarts and stops its process. A negative MaxRestarts
+// restarts forever. Once a process ran for StableAfter its restarts are counted from zero again.
+// A stopped process which is still running after StopGracePeriod is killed.
+type SupervisorConfig struct {
+	MaxRestarts     int
+	MinBackoff      time.Duration
+	MaxBackoff      time.Duration
+	StableAfter     time.Duration
+	StopGracePeriod time.Duration
+}
+
+func (c *SupervisorConfig) setDefaults() {
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = DefaultRestartMinBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DefaultRestartMaxBackoff
+	}
+	if c.StableAfter <= 0 {
+		c.StableAfter = DefaultStableAfter
+	}
+	if c.StopGracePeriod <= 0 {
+		c.StopGracePeriod = DefaultStopGracePeriod
+	}
+}
+
+// Supervisor runs a process, passes on every line it writes and restarts it when it crashes.
+// The process runs in a process group of its own, so signals reach it only through the Supervisor.
+type Supervisor struct {
+	newCommand func() *exec.Cmd
+	config     SupervisorConfig
+	output     func(source LogSource, line string)
+	events     func(ProcessEvent)
+	running    int32
+	after      func(time.Duration) <-chan time.Time
+
+	mu       sync.Mutex
+	process  *os.Process
+	stopping bool
+	stopped  chan struct{}
+}
+
+// NewSupervisor creates a Supervisor starting processes made by newCommand. Every line written by
+// the process is handed to output, along with its stream, the pid and the time it was read, and
+// every start and exit to events.
+func NewSupervisor(newCommand func() *exec.Cmd, config SupervisorConfig, output func(source LogSource, line string), events func(ProcessEvent)) *Supervisor {
+	config.setDefaults()
+	return &Supervisor{
+		newCommand: newCommand,
+		config:     config,
+		output:     output,
+		events:     events,
+		after:      time.After,
+		stopped:    make(chan struct{}),
+	}
+}
+
+// Running reports whether the process is running right now.
+func (s *Supervisor) Running() bool {
+	return atomic.LoadInt32(&s.running) == 1
+}
+
+// Signal forwards sig to the process group of the running process.
+func (s *Supervisor) Signal(sig os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signal(sig)
+}
+
+// Stop forwards sig to the running process and keeps it from being restarted. If the process is
+// still running after the grace period, its process group is killed.
+func (s *Supervisor) Stop(sig os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopping {
+		s.stopping = true
+		close(s.stopped)
+	}
+	s.signal(sig)
+
+	process := s.process
+	if process == nil {
+		return
+	}
+	go func() {
+		<-s.after(s.config.StopGracePeriod)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.process == process {
+			fmt.Printf("WARN: process %d did not stop within %s, killing it\n", process.Pid, s.config.StopGracePeriod)
+			s.signal(os.Kill)
+		}
+	}()
+}
+
+func (s *Supervisor) signal(sig os.Signal) {
+	if s.process == nil {
+		return
+	}
+	if !signalGroup(s.process, sig) {
+		s.process.Signal(sig)
+	}
+}
+
+// Run starts the process and restarts it after every crash until it exits cleanly, the restart
+// limit is reached or it is stopped. It returns the last exit event.
+func (s *Supervisor) Run() ProcessEvent {
+	restarts := 0
+	for {
+		exit := s.runOnce(restarts)
+		if exit.Type == ProcessExited || s.isStopping() {
+			return exit
+		}
+		if time.Duration(exit.Uptime)*time.Second >= s.config.StableAfter {
+			restarts = 0
+		}
+		if s.config.MaxRestarts >= 0 && restarts >= s.config.MaxRestarts {
+			return exit
+		}
+		restarts++
+		select {
+		case <-s.after(Backoff(restarts, s.config.MinBackoff, s.config.MaxBackoff)):
+		case <-s.stopped:
+			return exit
+		}
+	}
+}
+
+func (s *Supervisor) isStopping() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopping
+}
+
+// start starts cmd in a process group of its own, unless the Supervisor is stopping.
+func (s *Supervisor) start(cmd *exec.Cmd) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopping {
+		return fmt.Errorf("supervisor is stopping")
+	}
+	startInGroup(cmd)
+	err := cmd.Start()
+	if err == nil {
+		s.process = cmd.Process
+	}
+	return err
+}
+
+// runOnce runs the process until it ends and all of its output is read. A process killed by a
+// signal gets exit code 128 plus the signal number, like in a shell. A process ending after Stop
+// did not crash, whatever its exit code.
+func (s *Supervisor) runOnce(restarts int) ProcessEvent {
+	cmd := s.newCommand()
+	started := time.Now()
+	exit := ProcessEvent{Type: ProcessCrashed, Restarts: restarts, ExitCode: -1}
+
+	stdout, err := cmd.StdoutPipe()
+	if err == nil {
+		var stderr io.ReadCloser
+		stderr, err = cmd.StderrPipe()
+		if err == nil {
+			err = s.start(cmd)
+		}
+		if err == nil {
+			atomic.StoreInt32(&s.running, 1)
+			s.emit(ProcessEvent{Type: ProcessStarted, Pid: cmd.Process.Pid, Restarts: restarts})
+
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go s.readLines(LogSource{Stream: StreamStdout, Pid: cmd.Process.Pid}, stdout, &wg)
+			go s.readLines(LogSource{Stream: StreamStderr, Pid: cmd.Process.Pid}, stderr, &wg)
+			wg.Wait()
+			err = cmd.Wait()
+			atomic.StoreInt32(&s.running, 0)
+			s.mu.Lock()
+			s.process = nil
+			s.mu.Unlock()
+		}
+	}
+
+	exit.Uptime = int64(time.Since(started) / time.Second)
+	if cmd.ProcessState != nil {
+		exit.Pid = cmd.ProcessState.Pid()
+		exit.ExitCode = cmd.ProcessState.ExitCode()
+		if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			exit.Signal = status.Signal().String()
+			exit.ExitCode = 128 + int(status.Signal())
+		}
+		if cmd.ProcessState.Success() {
+			exit.Type = ProcessExited
+		} else if s.isStopping() {
+			exit.Type = ProcessExited
+			exit.Message = "stopped"
+		}
+	} else if err != nil {
+		exit.Message = err.Error()
+	}
+	s.emit(exit)
+	return exit
+}
+
+// readLines passes on every line of rdr, lines longer than MaxRawLogLength are cut.
+func (s *Supervisor) readLines(source LogSource, rdr io.Reader, wg *sync.WaitGroup) {
+	defer wg.Done()
+	buffered := bufio.NewReader(rdr)
+	for {
+		line, err := buffered.ReadString('\n')
+		if len(line) > 0 {
+			line = strings.TrimRight(line, "\r\n")
+			if len(line) > MaxRawLogLength {
+				line = line[:MaxRawLogLength]
+			}
+			source.Captured = captureTime(time.Now())
+			s.output(source, line)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Supervisor) emit(event ProcessEvent) {
+	event.Timestamp = time.Now().Unix()
+	if s.events != nil {
+		s.events(event)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build windows || plan9 || js
+// +build windows plan9 js
+
+package mond
+
+import (
+	"os"
+	"os/exec"
+)
+
+// startInGroup leaves cmd as it is, process groups are a unix thing.
+func startInGroup(cmd *exec.Cmd) {}
+
+// signalGroup signals process alone and reports whether that worked. A kill ends it for sure.
+func signalGroup(process *os.Process, sig os.Signal) bool {
+	if sig == os.Kill {
+		return process.Kill() == nil
+	}
+	return process.Signal(sig) == nil
+}
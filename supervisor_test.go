@@ -0,0 +1,217 @@
+package mond
+
+import (
+	"os/exec"
+	"reflect"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSupervisor(t *testing.T) {
+
+	t.Run("it passes on the lines of both streams", func(t *testing.T) {
+		recorder := &supervisorRecorder{}
+		supervisor := newTestSupervisor("echo out; echo err 1>&2", 0, recorder)
+
+		exit := supervisor.Run()
+
+		if exit.Type != ProcessExited || exit.ExitCode != 0 {
+			t.Errorf("got exit %+v want a clean exit", exit)
+		}
+		want := map[string][]string{StreamStdout: {"out"}, StreamStderr: {"err"}}
+		if !reflect.DeepEqual(recorder.lines, want) {
+			t.Errorf("got lines %v want %v", recorder.lines, want)
+		}
+		assertEventTypes(t, recorder.events, ProcessStarted, ProcessExited)
+		if supervisor.Running() {
+			t.Error("expected the process not to be running after Run returned")
+		}
+	})
+
+	t.Run("it tells the pid and capture time of every line", func(t *testing.T) {
+		recorder := &supervisorRecorder{}
+		supervisor := newTestSupervisor("echo out; echo err 1>&2", 0, recorder)
+		before := captureTime(time.Now())
+
+		supervisor.Run()
+
+		pid := recorder.events[0].Pid
+		if len(recorder.sources) != 2 {
+			t.Fatalf("got sources %v want 2", recorder.sources)
+		}
+		for _, source := range recorder.sources {
+			if source.Pid != pid || source.Captured < before || source.Captured > captureTime(time.Now()) {
+				t.Errorf("got source %+v want pid %d captured after %d", source, pid, before)
+			}
+		}
+	})
+
+	t.Run("it restarts a crashed process until the limit is reached", func(t *testing.T) {
+		recorder := &supervisorRecorder{}
+		supervisor := newTestSupervisor("exit 3", 2, recorder)
+
+		exit := supervisor.Run()
+
+		if exit.Type != ProcessCrashed || exit.ExitCode != 3 || exit.Restarts != 2 {
+			t.Errorf("got exit %+v want a crash with code 3 after 2 restarts", exit)
+		}
+		assertEventTypes(t, recorder.events,
+			ProcessStarted, ProcessCrashed, ProcessStarted, ProcessCrashed, ProcessStarted, ProcessCrashed)
+	})
+
+	t.Run("it stops restarting once the process exits cleanly", func(t *testing.T) {
+		dir, cleanup := createTempDir(t)
+		defer cleanup()
+		recorder := &supervisorRecorder{}
+		supervisor := newTestSupervisor("if [ -e "+dir+"/ran ]; then exit 0; fi; touch "+dir+"/ran; exit 1", -1, recorder)
+
+		exit := supervisor.Run()
+
+		if exit.Type != ProcessExited || exit.Restarts != 1 {
+			t.Errorf("got exit %+v want a clean exit after 1 restart", exit)
+		}
+		assertEventTypes(t, recorder.events, ProcessStarted, ProcessCrashed, ProcessStarted, ProcessExited)
+	})
+
+	t.Run("it reports the signal which killed the process", func(t *testing.T) {
+		recorder := &supervisorRecorder{}
+		supervisor := newTestSupervisor("kill -9 $$", 0, recorder)
+
+		exit := supervisor.Run()
+
+		if exit.Type != ProcessCrashed || exit.Signal != "killed" || exit.ExitCode != 137 {
+			t.Errorf("got exit %+v want a crash by signal killed with code 137", exit)
+		}
+	})
+
+	t.Run("it reports a process which cannot be started", func(t *testing.T) {
+		recorder := &supervisorRecorder{}
+		supervisor := NewSupervisor(func() *exec.Cmd {
+			return exec.Command("/does/not/exist")
+		}, SupervisorConfig{MaxRestarts: 0}, recorder.output, recorder.event)
+		supervisor.after = immediately
+
+		exit := supervisor.Run()
+
+		if exit.Type != ProcessCrashed || exit.Message == "" {
+			t.Errorf("got exit %+v want a crash with a message", exit)
+		}
+		assertEventTypes(t, recorder.events, ProcessCrashed)
+	})
+
+	t.Run("it forwards the stop signal and does not restart the process", func(t *testing.T) {
+		recorder := &supervisorRecorder{}
+		supervisor := newTestSupervisor("trap 'echo term; exit 0' TERM; echo ready; while true; do sleep 0.1; done", -1, recorder)
+		supervisor.after = time.After
+
+		exits := make(chan ProcessEvent)
+		go func() { exits <- supervisor.Run() }()
+		recorder.waitForLine(t, "ready")
+		supervisor.Stop(syscall.SIGTERM)
+		exit := <-exits
+
+		if exit.Type != ProcessExited || exit.ExitCode != 0 {
+			t.Errorf("got exit %+v want a clean exit", exit)
+		}
+		recorder.waitForLine(t, "term")
+		assertEventTypes(t, recorder.events, ProcessStarted, ProcessExited)
+	})
+
+	t.Run("it kills a process which ignores the stop signal after the grace period", func(t *testing.T) {
+		recorder := &supervisorRecorder{}
+		supervisor := newTestSupervisor("trap '' TERM; echo ready; while true; do sleep 0.1; done", -1, recorder)
+
+		exits := make(chan ProcessEvent)
+		go func() { exits <- supervisor.Run() }()
+		recorder.waitForLine(t, "ready")
+		supervisor.Stop(syscall.SIGTERM)
+		exit := <-exits
+
+		if exit.Type != ProcessExited || exit.Signal != "killed" || exit.ExitCode != 137 {
+			t.Errorf("got exit %+v want a stopped process killed with code 137", exit)
+		}
+	})
+}
+
+func TestProcessEventsAreCapped(t *testing.T) {
+	app := App{Name: "app"}
+	for i := 0; i < MaxProcessEvents+5; i++ {
+		app.addEvent(ProcessEvent{Type: ProcessStarted, Restarts: i})
+	}
+	if len(app.Events) != MaxProcessEvents {
+		t.Fatalf("got %d events want %d", len(app.Events), MaxProcessEvents)
+	}
+	if got := app.LastEvent().Restarts; got != MaxProcessEvents+4 {
+		t.Errorf("got last event with %d restarts want %d", got, MaxProcessEvents+4)
+	}
+}
+
+func immediately(time.Duration) <-chan time.Time {
+	c := make(chan time.Time, 1)
+	c <- time.Now()
+	return c
+}
+
+type supervisorRecorder struct {
+	mu      sync.Mutex
+	lines   map[string][]string
+	sources []LogSource
+	events  []ProcessEvent
+}
+
+func (r *supervisorRecorder) output(source LogSource, line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lines == nil {
+		r.lines = map[string][]string{}
+	}
+	r.lines[source.Stream] = append(r.lines[source.Stream], line)
+	r.sources = append(r.sources, source)
+}
+
+func (r *supervisorRecorder) event(e ProcessEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+// waitForLine waits until the process wrote line to any stream.
+func (r *supervisorRecorder) waitForLine(t testing.TB, line string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		for _, lines := range r.lines {
+			for _, l := range lines {
+				if l == line {
+					r.mu.Unlock()
+					return
+				}
+			}
+		}
+		r.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("process did not write %q", line)
+}
+
+func newTestSupervisor(script string, maxRestarts int, recorder *supervisorRecorder) *Supervisor {
+	supervisor := NewSupervisor(func() *exec.Cmd {
+		return exec.Command("sh", "-c", script)
+	}, SupervisorConfig{MaxRestarts: maxRestarts}, recorder.output, recorder.event)
+	supervisor.after = immediately
+	return supervisor
+}
+
+func assertEventTypes(t testing.TB, events []ProcessEvent, want ...string) {
+	t.Helper()
+	var got []string
+	for _, e := range events {
+		got = append(got, e.Type)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v want %v", got, want)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package mond
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// startInGroup makes cmd start in a process group of its own, so signals reach its children too.
+func startInGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalGroup sends sig to the process group of process and reports whether that worked.
+func signalGroup(process *os.Process, sig os.Signal) bool {
+	sysSig, ok := sig.(syscall.Signal)
+	// a negative pid addresses the whole process group
+	return ok && syscall.Kill(-process.Pid, sysSig) == nil
+}
@@ -1,13 +1,71 @@
 package mond
 
-import "os"
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
 
+const tapeTempSuffix = ".tmp"
+
+// tape replaces the whole content of the file at path on every write. The content is written to a
+// temporary file next to it which is then renamed over path, so a crash never leaves a half written file.
 type tape struct {
-	file *os.File
+	path string
+	sync bool
 }
 
 func (t *tape) Write(p []byte) (n int, err error) {
-	t.file.Truncate(0)
-	t.file.Seek(0, 0)
-	return t.file.Write(p)
+	dir, base := filepath.Split(t.path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := ioutil.TempFile(dir, base+tapeTempSuffix+"*")
+	if err != nil {
+		return 0, fmt.Errorf("problem creating temp file for %s, %v", t.path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if info, err := os.Stat(t.path); err == nil {
+		tmp.Chmod(info.Mode())
+	}
+
+	n, err = tmp.Write(p)
+	if err == nil && t.sync {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("problem writing temp file for %s, %v", t.path, err)
+	}
+
+	err = os.Rename(tmp.Name(), t.path)
+	if err != nil {
+		return 0, fmt.Errorf("problem replacing %s, %v", t.path, err)
+	}
+	if t.sync {
+		syncDir(dir)
+	}
+	return n, nil
+}
+
+// removeStaleTapeFiles deletes temp files of writes to path which never got renamed.
+func removeStaleTapeFiles(path string) {
+	stale, _ := filepath.Glob(path + tapeTempSuffix + "*")
+	for _, f := range stale {
+		os.Remove(f)
+	}
+}
+
+// syncDir flushes the directory entry of a renamed file to disk.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
 }
@@ -2,24 +2,39 @@ package mond
 
 import (
 	"io/ioutil"
+	"path/filepath"
 	"testing"
 )
 
 func TestTape_Write(t *testing.T) {
-	file, clean := createTempFile(t, "12345")
-	defer clean()
+	t.Run("replaces the file content", func(t *testing.T) {
+		file, clean := createTempFile(t, "12345")
+		defer clean()
 
-	tape := &tape{file}
+		tape := &tape{path: file.Name(), sync: true}
 
-	tape.Write([]byte("abc"))
+		tape.Write([]byte("abc"))
 
-	file.Seek(0, 0)
-	newFileContents, _ := ioutil.ReadAll(file)
+		newFileContents, _ := ioutil.ReadFile(file.Name())
 
-	got := string(newFileContents)
-	want := "abc"
+		got := string(newFileContents)
+		want := "abc"
 
-	if got != want {
-		t.Errorf("got %q want %q", got, want)
-	}
+		if got != want {
+			t.Errorf("got %q want %q", got, want)
+		}
+	})
+
+	t.Run("leaves no temp files behind", func(t *testing.T) {
+		file, clean := createTempFile(t, "12345")
+		defer clean()
+
+		tape := &tape{path: file.Name()}
+		tape.Write([]byte("abc"))
+
+		leftovers, _ := filepath.Glob(file.Name() + tapeTempSuffix + "*")
+		if len(leftovers) != 0 {
+			t.Errorf("expected no temp files, got %v", leftovers)
+		}
+	})
 }
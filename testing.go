@@ -1,43 +1,74 @@
 package mond
 
+import (
+	"sync"
+	"time"
+)
+
+// StubLogStore is an in memory AccessLogStore, safe for concurrent use.
 type StubLogStore struct {
+	mu            sync.RWMutex
 	AppAccessLogs Apps
 }
 
 func (s *StubLogStore) GetApps() Apps {
-	return s.AppAccessLogs
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.AppAccessLogs.snapshot()
 }
 
 
 func (s *StubLogStore) GetApp(name string) *App {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	app := s.AppAccessLogs.Find(name)
 	if app != nil {
-		return app
+		snapshot := app.snapshot()
+		return &snapshot
 	}
 	return nil
 }
 
 func (s *StubLogStore) GetAccessLogs(name string) AccessLogs {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	app := s.AppAccessLogs.Find(name)
 	if app != nil {
-		return app.Logs
+		return app.snapshot().Logs
 	}
 	return AccessLogs{}
 }
 
 func (s *StubLogStore) RecordAccessLog(name string, value AccessLog) {
+	s.RecordAccessLogs(name, AccessLogs{value})
+}
+
+func (s *StubLogStore) RecordAccessLogs(name string, values AccessLogs) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	app := s.AppAccessLogs.Find(name)
 	if app != nil {
-		app.Logs = append(app.Logs, value)
+		app.Logs = append(app.Logs, values...)
 	} else {
 		s.AppAccessLogs = append(s.AppAccessLogs, App{
 			Name: name,
-			Logs: AccessLogs{value},
+			Logs: append(AccessLogs{}, values...),
 		})
 	}
 }
 
+func (s *StubLogStore) PruneAccessLogs(name string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app := s.AppAccessLogs.Find(name)
+	if app != nil {
+		app.pruneLogs(count)
+	}
+}
+
 func (s *StubLogStore) GetAppNames() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	var apps []string
 	for _, v := range s.AppAccessLogs {
 		apps = append(apps, v.Name)
@@ -46,21 +77,53 @@ func (s *StubLogStore) GetAppNames() []string {
 }
 
 func (s *StubLogStore) GetHealth(name string) HealthCheck {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	app := s.AppAccessLogs.Find(name)
 	if app != nil {
-		return app.Health
+		return app.CurrentHealth()
 	}
 	return HealthCheck{}
 }
 
 func (s *StubLogStore) RecordHealth(name string, check HealthCheck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app := s.AppAccessLogs.Find(name)
+	if app == nil {
+		s.AppAccessLogs = append(s.AppAccessLogs, App{Name: name})
+		app = &s.AppAccessLogs[len(s.AppAccessLogs)-1]
+	}
+	app.recordHealth(check, time.Now().Unix())
+}
+
+func (s *StubLogStore) MarkHealthStale(name string, at int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	app := s.AppAccessLogs.Find(name)
 	if app != nil {
-		app.Health = check
-	} else {
-		s.AppAccessLogs = append(s.AppAccessLogs, App{
-			Name:   name,
-			Health: check,
-		})
+		app.markStale(at)
+	}
+}
+
+func (s *StubLogStore) RecordProcessEvent(name string, event ProcessEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app := s.AppAccessLogs.Find(name)
+	if app == nil {
+		s.AppAccessLogs = append(s.AppAccessLogs, App{Name: name})
+		app = &s.AppAccessLogs[len(s.AppAccessLogs)-1]
+	}
+	app.addEvent(event)
+}
+
+func (s *StubLogStore) RecordAppLogs(name string, logs []AppLog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app := s.AppAccessLogs.Find(name)
+	if app == nil {
+		s.AppAccessLogs = append(s.AppAccessLogs, App{Name: name})
+		app = &s.AppAccessLogs[len(s.AppAccessLogs)-1]
 	}
+	app.addAppLogs(logs...)
 }